@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const (
+	queryModeAgent = "agent"
+
+	agentMaxSteps       = 6
+	agentMaxRows        = 50
+	toolResultCharLimit = 4000
+)
+
+// GraphRecords runs a read query and returns its rows. The agent tools and the
+// graph view only touch the graph through it, so tests can stub the database
+// out.
+type GraphRecords func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error)
+
+// AgentTool is a typed graph primitive the agent can call. Args describes the
+// JSON arguments to the model.
+type AgentTool struct {
+	Name        string
+	Args        string
+	Description string
+	Run         func(args map[string]interface{}) (interface{}, error)
+}
+
+// ToolCall is one step of an agent run as returned in QueryResponse.Trace.
+type ToolCall struct {
+	Step       int                    `json:"step"`
+	Tool       string                 `json:"tool"`
+	Args       map[string]interface{} `json:"args,omitempty"`
+	Result     string                 `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// agentAction is what the model replies with at each step: either a tool call
+// or the final answer.
+type agentAction struct {
+	Tool   string                 `json:"tool"`
+	Args   map[string]interface{} `json:"args"`
+	Answer string                 `json:"answer"`
+}
+
+var (
+	relationshipTypePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	cypherWritePattern      = regexp.MustCompile(`(?i)\b(CREATE|MERGE|DELETE|DETACH|SET|REMOVE|DROP|FOREACH|LOAD\s+CSV|CALL)\b`)
+)
+
+// graphTools are the agent's tools over one graph; symmetric lists the
+// relationship types queries must match without an arrow.
+func graphTools(query GraphRecords, symmetric map[string]bool) []AgentTool {
+	return []AgentTool{
+		{
+			Name:        "find_entity",
+			Args:        `{"name": string}`,
+			Description: "Find Character, Hero and Comic nodes whose id matches the name exactly or contains it (case-insensitive). Use this first to get exact ids.",
+			Run: func(args map[string]interface{}) (interface{}, error) {
+				name, err := stringArg(args, "name")
+				if err != nil {
+					return nil, err
+				}
+				return query(`MATCH (n)
+WHERE n.id = $name OR toLower(n.id) CONTAINS toLower($name)
+RETURN n.id AS id, labels(n) AS labels
+ORDER BY n.id = $name DESC, size(n.id)
+LIMIT 10`, map[string]interface{}{"name": name})
+			},
+		},
+		{
+			Name:        "get_neighbors",
+			Args:        `{"id": string, "relationship"?: string, "direction"?: "out" | "in" | "both", "limit"?: int}`,
+			Description: "List the nodes connected to the node with this exact id, optionally only through one relationship type and direction.",
+			Run: func(args map[string]interface{}) (interface{}, error) {
+				id, err := stringArg(args, "id")
+				if err != nil {
+					return nil, err
+				}
+				relationship, _ := args["relationship"].(string)
+				direction, _ := args["direction"].(string)
+				pattern, err := neighborPattern("(n)", relationship, direction, symmetric)
+				if err != nil {
+					return nil, err
+				}
+				limit, err := intArg(args, "limit", 25, 1, agentMaxRows)
+				if err != nil {
+					return nil, err
+				}
+				return query(`MATCH `+pattern+`
+WHERE n.id = $id
+RETURN type(r) AS relationship, CASE WHEN startNode(r) = n THEN 'out' ELSE 'in' END AS direction, m.id AS id, labels(m) AS labels
+LIMIT $limit`, map[string]interface{}{"id": id, "limit": limit})
+			},
+		},
+		{
+			Name:        "shortest_path",
+			Args:        `{"from": string, "to": string, "max_hops"?: int}`,
+			Description: "Find the shortest path between two nodes by exact id, ignoring relationship direction (max_hops defaults to 6).",
+			Run: func(args map[string]interface{}) (interface{}, error) {
+				from, err := stringArg(args, "from")
+				if err != nil {
+					return nil, err
+				}
+				to, err := stringArg(args, "to")
+				if err != nil {
+					return nil, err
+				}
+				maxHops, err := intArg(args, "max_hops", 6, 1, 10)
+				if err != nil {
+					return nil, err
+				}
+				// Path lengths cannot be parameters, so the clamped value is inlined
+				return query(fmt.Sprintf(`MATCH (a {id: $from}), (b {id: $to})
+MATCH p = shortestPath((a)-[*..%d]-(b))
+RETURN [n IN nodes(p) | n.id] AS nodes, [r IN relationships(p) | type(r)] AS relationships`, maxHops),
+					map[string]interface{}{"from": from, "to": to})
+			},
+		},
+		{
+			Name:        "count_appearances",
+			Args:        `{"hero": string}`,
+			Description: "Count the comics a Hero (exact id) appears in.",
+			Run: func(args map[string]interface{}) (interface{}, error) {
+				hero, err := stringArg(args, "hero")
+				if err != nil {
+					return nil, err
+				}
+				return query(`MATCH (h:Hero {id: $hero})
+OPTIONAL MATCH (h)-[:APPEARS_IN]->(c:Comic)
+RETURN h.id AS hero, count(c) AS appearances`, map[string]interface{}{"hero": hero})
+			},
+		},
+		{
+			Name:        "run_cypher",
+			Args:        `{"cypher": string, "params"?: object}`,
+			Description: "Run a read-only Cypher query for anything the other tools cannot answer. Pass values as $parameters in params.",
+			Run: func(args map[string]interface{}) (interface{}, error) {
+				cypherQuery, err := stringArg(args, "cypher")
+				if err != nil {
+					return nil, err
+				}
+				params, _ := args["params"].(map[string]interface{})
+				if cypherWritePattern.MatchString(cypherLiteralPattern.ReplaceAllString(cypherQuery, " ")) {
+					return nil, fmt.Errorf("only read queries are allowed")
+				}
+				if err := validateCypherParams(cypherQuery, params); err != nil {
+					return nil, err
+				}
+				if err := validateRelationshipDirections(cypherQuery, symmetric); err != nil {
+					return nil, err
+				}
+				rows, err := query(cypherQuery, params)
+				if len(rows) > agentMaxRows {
+					rows = rows[:agentMaxRows]
+				}
+				return rows, err
+			},
+		},
+	}
+}
+
+func stringArg(args map[string]interface{}, name string) (string, error) {
+	value, ok := args[name].(string)
+	if !ok || strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("missing string argument %q", name)
+	}
+	return value, nil
+}
+
+// intArg reads an optional integer argument and clamps it to [min, max].
+func intArg(args map[string]interface{}, name string, fallback, min, max int64) (int64, error) {
+	value := fallback
+	switch v := args[name].(type) {
+	case nil:
+	case int64:
+		value = v
+	case float64:
+		value = int64(v)
+	default:
+		return 0, fmt.Errorf("argument %q must be an integer", name)
+	}
+	if value < min {
+		value = min
+	}
+	if value > max {
+		value = max
+	}
+	return value, nil
+}
+
+func formatTools(tools []AgentTool) string {
+	var lines []string
+	for _, tool := range tools {
+		lines = append(lines, fmt.Sprintf("- %s %s: %s", tool.Name, tool.Args, tool.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseAgentAction reads the model's JSON reply, tolerating code fences and
+// prose around the object.
+func parseAgentAction(completion string) (agentAction, error) {
+	completion = stripCodeFences(completion)
+	start, end := strings.Index(completion, "{"), strings.LastIndex(completion, "}")
+	if start < 0 || end < start {
+		return agentAction{}, fmt.Errorf("reply is not a JSON object")
+	}
+
+	var action agentAction
+	decoder := json.NewDecoder(strings.NewReader(completion[start : end+1]))
+	decoder.UseNumber()
+	if err := decoder.Decode(&action); err != nil {
+		return agentAction{}, fmt.Errorf("reply is not valid JSON: %v", err)
+	}
+	for name, value := range action.Args {
+		action.Args[name] = normalizeParamValue(value)
+	}
+	if action.Tool == "" && strings.TrimSpace(action.Answer) == "" {
+		return agentAction{}, fmt.Errorf(`reply needs either "tool" or "answer"`)
+	}
+	return action, nil
+}
+
+func formatToolResult(result interface{}) string {
+	if rows, ok := result.([]map[string]interface{}); ok && len(rows) == 0 {
+		return "no results"
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Sprintf("%v", result)
+	}
+	if len(encoded) > toolResultCharLimit {
+		return string(encoded[:toolResultCharLimit]) + " ... (truncated)"
+	}
+	return string(encoded)
+}
+
+// runAgent lets the model call graph tools until it answers. After maxSteps
+// tool calls it gets one last turn to answer from what it has found.
+func runAgent(llm llms.Model, promptTemplate *PromptTemplate, question, schema string, tools []AgentTool, maxSteps int) (string, []ToolCall, error) {
+	prompt, err := promptTemplate.Render(map[string]interface{}{
+		"Schema":   schema,
+		"Question": question,
+		"Tools":    formatTools(tools),
+		"MaxSteps": maxSteps,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	toolsByName := make(map[string]AgentTool)
+	var names []string
+	for _, tool := range tools {
+		toolsByName[tool.Name] = tool
+		names = append(names, tool.Name)
+	}
+
+	ctx := context.Background()
+	messages := []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)}
+	var trace []ToolCall
+
+	for step := 1; ; step++ {
+		final := step > maxSteps
+		if final {
+			messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman,
+				`The step budget is used up. Reply now with {"answer": "..."} based on what you have found.`))
+		}
+
+		response, err := llm.GenerateContent(ctx, messages)
+		if err != nil {
+			return "", trace, fmt.Errorf("LLM generation failed at step %d: %v", step, err)
+		}
+		if len(response.Choices) == 0 {
+			return "", trace, fmt.Errorf("empty response from LLM at step %d", step)
+		}
+		completion := strings.TrimSpace(response.Choices[0].Content)
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeAI, completion))
+
+		action, parseErr := parseAgentAction(completion)
+		if parseErr == nil && action.Tool == "" {
+			return strings.TrimSpace(action.Answer), trace, nil
+		}
+		if final {
+			return "", trace, fmt.Errorf("no answer after %d tool calls", maxSteps)
+		}
+
+		// Run the tool and feed the result, or the error, back to the model
+		call := ToolCall{Step: step, Tool: action.Tool, Args: action.Args}
+		stepStart := time.Now()
+		var observation string
+		if tool, ok := toolsByName[action.Tool]; parseErr != nil {
+			call.Error = parseErr.Error()
+			observation = fmt.Sprintf(`Error: %s. Reply with a single JSON object: {"tool": ..., "args": {...}} or {"answer": ...}.`, parseErr)
+		} else if !ok {
+			call.Error = fmt.Sprintf("unknown tool %q", action.Tool)
+			observation = fmt.Sprintf("Error: unknown tool %q. Available tools: %s.", action.Tool, strings.Join(names, ", "))
+		} else if result, err := tool.Run(action.Args); err != nil {
+			call.Error = err.Error()
+			observation = fmt.Sprintf("Error from %s: %v", action.Tool, err)
+		} else {
+			call.Result = formatToolResult(result)
+			observation = fmt.Sprintf("Result of %s: %s", action.Tool, call.Result)
+		}
+		call.DurationMs = time.Since(stepStart).Milliseconds()
+		trace = append(trace, call)
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, observation))
+	}
+}
+
+// formatTrace renders the tool calls as readable lines for the results panel.
+func formatTrace(trace []ToolCall) string {
+	var lines []string
+	for _, call := range trace {
+		tool := call.Tool
+		if len(call.Args) > 0 {
+			args, _ := json.Marshal(call.Args)
+			tool += " " + string(args)
+		}
+		outcome := call.Result
+		if call.Error != "" {
+			outcome = "error: " + call.Error
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s → %s", call.Step, tool, outcome))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// runAgentPipeline answers a question with the tool-calling agent instead of
+// a single generated query.
+func runAgentPipeline(question string, ns *GraphNamespace) (response QueryResponse) {
+	start := time.Now()
+	response = QueryResponse{
+		ID:        newHistoryID(),
+		Query:     question,
+		Namespace: ns.Name,
+		Mode:      queryModeAgent,
+		Models:    modelNames,
+	}
+	defer func() {
+		response.Latency.TotalMs = time.Since(start).Milliseconds()
+		response.Timestamp = getCurrentTimestamp()
+	}()
+
+	agentPrompt := promptStore.Select(promptKindAgent, response.ID)
+	response.Prompts = PromptVersions{Agent: agentPrompt.Version}
+
+	answer, trace, err := runAgent(cypherLLM, agentPrompt, question, ns.Schema, graphTools(fetchGraphRecords(ns.Name), ns.Symmetric), agentMaxSteps)
+	response.Trace = trace
+	response.Results = formatTrace(trace)
+	for _, call := range trace {
+		response.Latency.ExecutionMs += call.DurationMs
+	}
+	if err != nil {
+		response.Error = fmt.Sprintf("Agent failed: %v", err)
+		return response
+	}
+	response.Response = answer
+	response.Grounding = checkGrounding(answer, response.Results, question)
+
+	return response
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubGraph answers graph queries from canned rows keyed by a fragment
+// of the Cypher, recording every query and its parameters.
+type stubGraph struct {
+	rows    map[string][]map[string]interface{}
+	queries []string
+	params  []map[string]interface{}
+}
+
+func (g *stubGraph) query(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+	g.queries = append(g.queries, cypherQuery)
+	g.params = append(g.params, params)
+	for fragment, rows := range g.rows {
+		if strings.Contains(cypherQuery, fragment) {
+			return rows, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestGraphTools(t *testing.T) {
+	tests := []struct {
+		tool       string
+		args       map[string]interface{}
+		wantCypher string
+		wantErr    string
+	}{
+		{tool: "find_entity", args: map[string]interface{}{"name": "thor"}, wantCypher: "CONTAINS toLower($name)"},
+		{tool: "find_entity", args: map[string]interface{}{}, wantErr: `missing string argument "name"`},
+		{tool: "get_neighbors", args: map[string]interface{}{"id": "Thor", "relationship": "PARTNERS_WITH", "direction": "out"}, wantCypher: "MATCH (n)-[r:PARTNERS_WITH]->(m)"},
+		{tool: "get_neighbors", args: map[string]interface{}{"id": "Thor", "direction": "in"}, wantCypher: "MATCH (n)<-[r]-(m)"},
+		{tool: "get_neighbors", args: map[string]interface{}{"id": "Thor", "relationship": "KNOWS]-() DETACH DELETE (m"}, wantErr: "invalid relationship type"},
+		{tool: "get_neighbors", args: map[string]interface{}{"id": "Thor", "direction": "sideways"}, wantErr: "direction must be"},
+		{tool: "shortest_path", args: map[string]interface{}{"from": "Thor", "to": "Hulk", "max_hops": int64(50)}, wantCypher: "[*..10]"},
+		{tool: "count_appearances", args: map[string]interface{}{"hero": "THOR/DR. DONALD BLAK"}, wantCypher: "[:APPEARS_IN]->(c:Comic)"},
+		{tool: "run_cypher", args: map[string]interface{}{"cypher": "MATCH (c:Character {id: $name}) RETURN c.id", "params": map[string]interface{}{"name": "Thor"}}, wantCypher: "MATCH (c:Character {id: $name}) RETURN c.id"},
+		{tool: "run_cypher", args: map[string]interface{}{"cypher": "MATCH (c {id: 'Create'}) RETURN c.id"}, wantCypher: "MATCH (c {id: 'Create'}) RETURN c.id"},
+		{tool: "run_cypher", args: map[string]interface{}{"cypher": "MATCH (c) DETACH DELETE c"}, wantErr: "only read queries"},
+		{tool: "run_cypher", args: map[string]interface{}{"cypher": "MATCH (c {id: $name}) RETURN c"}, wantErr: "unbound parameters: $name"},
+	}
+
+	for _, tc := range tests {
+		graph := &stubGraph{}
+		var tool AgentTool
+		for _, candidate := range graphTools(graph.query, nil) {
+			if candidate.Name == tc.tool {
+				tool = candidate
+			}
+		}
+
+		_, err := tool.Run(tc.args)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s(%v): error = %v, want %q", tc.tool, tc.args, err, tc.wantErr)
+			}
+			if len(graph.queries) > 0 {
+				t.Errorf("%s(%v): should not have queried the graph", tc.tool, tc.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s(%v): %v", tc.tool, tc.args, err)
+			continue
+		}
+		if len(graph.queries) != 1 || !strings.Contains(graph.queries[0], tc.wantCypher) {
+			t.Errorf("%s(%v): ran %q, want it to contain %q", tc.tool, tc.args, graph.queries, tc.wantCypher)
+		}
+	}
+}
+
+func TestRunAgent(t *testing.T) {
+	prompts := testPromptStore(t)
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"CONTAINS toLower($name)": {{"id": "Spider-Man", "labels": []interface{}{"Character"}}},
+		"PARTNERS_WITH":           {{"relationship": "PARTNERS_WITH", "direction": "out", "id": "Black Cat", "labels": []interface{}{"Character"}}},
+	}}
+
+	tests := []struct {
+		name        string
+		question    string
+		maxSteps    int
+		completions []string
+		wantTools   []string
+		wantErrors  int
+		wantAnswer  string
+		wantErr     string
+	}{
+		{
+			name:     "multi-hop",
+			question: "Who does spider-man partner with?",
+			maxSteps: 6,
+			completions: []string{
+				`{"tool": "find_entity", "args": {"name": "spider-man"}}`,
+				"```json\n{\"tool\": \"get_neighbors\", \"args\": {\"id\": \"Spider-Man\", \"relationship\": \"PARTNERS_WITH\", \"direction\": \"out\"}}\n```",
+				`{"answer": "Spider-Man partners with Black Cat."}`,
+			},
+			wantTools:  []string{"find_entity", "get_neighbors"},
+			wantAnswer: "Spider-Man partners with Black Cat.",
+		},
+		{
+			name:     "bad replies are reported back to the model",
+			question: "Is Black Cat in the graph?",
+			maxSteps: 6,
+			completions: []string{
+				"Let me think about that.",
+				`{"tool": "lookup", "args": {"name": "Black Cat"}}`,
+				`{"answer": "I could not check that."}`,
+			},
+			wantTools:  []string{"", "lookup"},
+			wantErrors: 2,
+			wantAnswer: "I could not check that.",
+		},
+		{
+			name:     "step budget forces an answer",
+			question: "How are Spider-Man and Black Cat connected?",
+			maxSteps: 1,
+			completions: []string{
+				`{"tool": "find_entity", "args": {"name": "Black Cat"}}`,
+				`{"answer": "Black Cat is in the graph, but I ran out of steps."}`,
+			},
+			wantTools:  []string{"find_entity"},
+			wantAnswer: "Black Cat is in the graph, but I ran out of steps.",
+		},
+		{
+			name:     "no answer after the budget",
+			question: "Who is the strongest Avenger?",
+			maxSteps: 1,
+			completions: []string{
+				`{"tool": "find_entity", "args": {"name": "Avenger"}}`,
+				`{"tool": "find_entity", "args": {"name": "Hulk"}}`,
+			},
+			wantTools: []string{"find_entity"},
+			wantErr:   "no answer after 1 tool calls",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			model := fixtureModel(t, tc.completions...)
+			answer, trace, err := runAgent(model, prompts.Select(promptKindAgent, ""), tc.question, "test schema", graphTools(graph.query, nil), tc.maxSteps)
+
+			var tools []string
+			errors := 0
+			for _, call := range trace {
+				tools = append(tools, call.Tool)
+				if call.Error != "" {
+					errors++
+				}
+			}
+			if !reflect.DeepEqual(tools, tc.wantTools) {
+				t.Errorf("tools = %q, want %q", tools, tc.wantTools)
+			}
+			if errors != tc.wantErrors {
+				t.Errorf("%d failed tool calls, want %d: %+v", errors, tc.wantErrors, trace)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answer != tc.wantAnswer {
+				t.Errorf("answer = %q, want %q", answer, tc.wantAnswer)
+			}
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	qualityReportPath   = "data/quality_report.json"
+	qualityExampleLimit = 20
+	// Nodes this many standard deviations above their label's mean degree
+	// are reported as outliers
+	degreeOutlierSigma = 3
+)
+
+type QualityCheck struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Count       int      `json:"count"`
+	Examples    []string `json:"examples"`
+}
+
+type DataQualityReport struct {
+	GeneratedAt   string         `json:"generated_at"`
+	ImportID      string         `json:"import_id,omitempty"`
+	Nodes         int            `json:"nodes"`
+	Relationships int            `json:"relationships"`
+	Findings      int            `json:"findings"`
+	Checks        []QualityCheck `json:"checks"`
+	Datasets      []DatasetLoad  `json:"datasets"`
+}
+
+type nodeKey struct {
+	Label, ID string
+}
+
+type edgeKey struct {
+	Type           string
+	Source, Target nodeKey
+}
+
+// qualityCollector watches every node and edge the loader writes and
+// reports anomalies that MERGE would otherwise hide.
+type qualityCollector struct {
+	// symmetric types are stored once per pair, so their reciprocal rows
+	// are expected rather than reported
+	symmetric      map[string]bool
+	nodes          map[nodeKey]string
+	degree         map[nodeKey]int
+	edges          map[edgeKey]int
+	duplicateNodes []string
+	orphans        []string
+	selfLoops      []string
+	orphanCount    int
+	edgeRows       int
+}
+
+func newQualityCollector() *qualityCollector {
+	return &qualityCollector{
+		nodes:  make(map[nodeKey]string),
+		degree: make(map[nodeKey]int),
+		edges:  make(map[edgeKey]int),
+	}
+}
+
+func (q *qualityCollector) Node(node ImportNode) error {
+	key := nodeKey{node.Label, node.ID}
+	if _, ok := q.nodes[key]; ok {
+		q.duplicateNodes = append(q.duplicateNodes, fmt.Sprintf("%s %s in %s", node.Label, node.ID, node.File))
+		return nil
+	}
+	q.nodes[key] = node.File
+	return nil
+}
+
+func (q *qualityCollector) Edge(edge ImportEdge) error {
+	q.edgeRows++
+	source := nodeKey{edge.SourceLabel, edge.Source}
+	target := nodeKey{edge.TargetLabel, edge.Target}
+	description := fmt.Sprintf("%s -[%s]-> %s in %s", edge.Source, edge.Type, edge.Target, edge.File)
+
+	_, sourceKnown := q.nodes[source]
+	_, targetKnown := q.nodes[target]
+	if !sourceKnown || !targetKnown {
+		q.orphanCount++
+		if len(q.orphans) < qualityExampleLimit {
+			missing := []string{}
+			if !sourceKnown {
+				missing = append(missing, edge.SourceLabel+" "+edge.Source)
+			}
+			if !targetKnown {
+				missing = append(missing, edge.TargetLabel+" "+edge.Target)
+			}
+			q.orphans = append(q.orphans, description+" (no "+strings.Join(missing, " or ")+")")
+		}
+		return nil
+	}
+
+	if source == target {
+		q.selfLoops = append(q.selfLoops, description)
+	}
+	key := edgeKey{edge.Type, source, target}
+	if q.edges[key] == 0 {
+		q.degree[source]++
+		q.degree[target]++
+	}
+	q.edges[key]++
+	return nil
+}
+
+// Report runs the checks over everything collected.
+func (q *qualityCollector) Report() DataQualityReport {
+	report := DataQualityReport{
+		GeneratedAt:   time.Now().UTC().Format(time.RFC3339),
+		Nodes:         len(q.nodes),
+		Relationships: len(q.edges),
+	}
+	add := func(name, description string, count int, examples []string) {
+		if len(examples) > qualityExampleLimit {
+			examples = examples[:qualityExampleLimit]
+		}
+		if examples == nil {
+			examples = []string{}
+		}
+		report.Checks = append(report.Checks, QualityCheck{Name: name, Description: description, Count: count, Examples: examples})
+		report.Findings += count
+	}
+
+	add("orphan_references", "Edge rows naming a node no file defines; handled by the dataset's dangling policy", q.orphanCount, q.orphans)
+	add("duplicate_nodes", "Node rows repeating a label and id already loaded", len(q.duplicateNodes), q.duplicateNodes)
+
+	var duplicates, reciprocal []string
+	for key, count := range q.edges {
+		if count > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%s -[%s]-> %s (%d rows)", key.Source.ID, key.Type, key.Target.ID, count))
+		}
+		reverse := edgeKey{key.Type, key.Target, key.Source}
+		if key.Source != key.Target && q.edges[reverse] > 0 && key.Source.ID < key.Target.ID && !q.symmetric[key.Type] {
+			reciprocal = append(reciprocal, fmt.Sprintf("%s <-[%s]-> %s", key.Source.ID, key.Type, key.Target.ID))
+		}
+	}
+	sort.Strings(duplicates)
+	sort.Strings(reciprocal)
+	add("duplicate_relationships", "Edge rows repeating a relationship already loaded", len(duplicates), duplicates)
+	add("reciprocal_relationships", "Pairs stored in both directions, A→B and B→A, with the same type", len(reciprocal), reciprocal)
+	add("self_loops", "Relationships from a node to itself", len(q.selfLoops), q.selfLoops)
+
+	// Degree checks per label
+	byLabel := make(map[string][]nodeKey)
+	for key := range q.nodes {
+		byLabel[key.Label] = append(byLabel[key.Label], key)
+	}
+	var isolated []string
+	type outlier struct {
+		key    nodeKey
+		degree int
+		mean   float64
+	}
+	var outliers []outlier
+	for _, keys := range byLabel {
+		var sum, sumSquares float64
+		for _, key := range keys {
+			degree := float64(q.degree[key])
+			sum += degree
+			sumSquares += degree * degree
+			if degree == 0 {
+				isolated = append(isolated, key.Label+" "+key.ID)
+			}
+		}
+		mean := sum / float64(len(keys))
+		stddev := math.Sqrt(math.Max(0, sumSquares/float64(len(keys))-mean*mean))
+		for _, key := range keys {
+			if stddev > 0 && float64(q.degree[key]) > mean+degreeOutlierSigma*stddev {
+				outliers = append(outliers, outlier{key, q.degree[key], mean})
+			}
+		}
+	}
+	sort.Strings(isolated)
+	sort.Slice(outliers, func(i, j int) bool {
+		if outliers[i].degree != outliers[j].degree {
+			return outliers[i].degree > outliers[j].degree
+		}
+		return outliers[i].key.ID < outliers[j].key.ID
+	})
+	outlierExamples := make([]string, len(outliers))
+	for i, o := range outliers {
+		outlierExamples[i] = fmt.Sprintf("%s %s: %d relationships (%s mean %.1f)", o.key.Label, o.key.ID, o.degree, o.key.Label, o.mean)
+	}
+	add("isolated_nodes", "Nodes without any relationship, such as heroes with no comic appearances", len(isolated), isolated)
+	add("degree_outliers", fmt.Sprintf("Nodes more than %d standard deviations above their label's mean degree", degreeOutlierSigma), len(outliers), outlierExamples)
+
+	// Ids that only differ in case, spacing or punctuation
+	collisions := collisionGroups(q.nodes, func(key nodeKey) string {
+		return key.Label + "\x00" + normalizeName(key.ID)
+	}, func(keys []nodeKey) bool { return true })
+	add("name_collisions", "Distinct ids of one label that normalize to the same name", len(collisions), collisions)
+
+	// The same entity loaded by more than one dataset under different labels
+	overlaps := collisionGroups(q.nodes, func(key nodeKey) string {
+		return entityName(key.ID)
+	}, func(keys []nodeKey) bool {
+		for _, key := range keys[1:] {
+			if key.Label != keys[0].Label && q.nodes[key] != q.nodes[keys[0]] {
+				return true
+			}
+		}
+		return false
+	})
+	add("cross_dataset_nodes", "Entities that appear in more than one dataset under different labels", len(overlaps), overlaps)
+	return report
+}
+
+// collisionGroups groups nodes by a normalized key and describes the groups
+// with more than one node that pass the filter.
+func collisionGroups(nodes map[nodeKey]string, normalize func(nodeKey) string, keep func([]nodeKey) bool) []string {
+	groups := make(map[string][]nodeKey)
+	for key := range nodes {
+		if name := normalize(key); name != "" && !strings.HasSuffix(name, "\x00") {
+			groups[name] = append(groups[name], key)
+		}
+	}
+	var described []string
+	for _, keys := range groups {
+		if len(keys) < 2 {
+			continue
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Label != keys[j].Label {
+				return keys[i].Label < keys[j].Label
+			}
+			return keys[i].ID < keys[j].ID
+		})
+		if !keep(keys) {
+			continue
+		}
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = fmt.Sprintf("%s %s (%s)", key.Label, key.ID, nodes[key])
+		}
+		described = append(described, strings.Join(parts, " ≈ "))
+	}
+	sort.Strings(described)
+	return described
+}
+
+var (
+	nonAlphanumericPattern = regexp.MustCompile(`[^a-z0-9]+`)
+	parentheticalPattern   = regexp.MustCompile(`\s*\([^)]*\)`)
+)
+
+// normalizeName lowercases an id and drops everything but letters and digits.
+func normalizeName(id string) string {
+	return nonAlphanumericPattern.ReplaceAllString(strings.ToLower(id), "")
+}
+
+// entityName is the name an entity goes by across datasets: Hero ids such as
+// "SPIDER-MAN/PETER PARKER" and Character ids such as "Vermin (comics)" both
+// reduce to their first name. Very short names are ignored as too ambiguous.
+func entityName(id string) string {
+	name, _, _ := strings.Cut(parentheticalPattern.ReplaceAllString(id, ""), "/")
+	name = normalizeName(name)
+	if len(name) < 4 {
+		return ""
+	}
+	return name
+}
+
+// qualityReportFile is where a namespace's last load report is kept.
+func qualityReportFile(namespace string) string {
+	if namespace == "" {
+		return qualityReportPath
+	}
+	return strings.TrimSuffix(qualityReportPath, ".json") + "." + namespace + ".json"
+}
+
+func saveQualityReport(path string, report DataQualityReport) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// handleQualityReport serves the report from the last data load of the
+// namespace named by ?namespace=.
+func handleQualityReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	namespace := r.URL.Query().Get("namespace")
+	if err := validateNamespace(namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	content, err := os.ReadFile(qualityReportFile(namespace))
+	if os.IsNotExist(err) {
+		http.Error(w, "No data-quality report yet: load the data first", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestQualityReport(t *testing.T) {
+	q := newQualityCollector()
+	partnerships := "dataset/marvel_characters_partnerships/nodes.csv"
+	social := "dataset/marvel_universe_social_network/nodes.csv"
+	for _, node := range []ImportNode{
+		{Label: "Character", ID: "Spider-Man", File: partnerships},
+		{Label: "Character", ID: "Black Cat", File: partnerships},
+		{Label: "Character", ID: "Vermin (comics)", File: partnerships},
+		{Label: "Character", ID: "Black cat", File: partnerships},
+		{Label: "Character", ID: "Spider-Man", File: partnerships},
+		{Label: "Hero", ID: "SPIDER-MAN/PETER PARKER", File: social},
+		{Label: "Hero", ID: "VERMIN", File: social},
+		{Label: "Comic", ID: "ASM 1", File: social},
+	} {
+		q.Node(node)
+	}
+	for _, edge := range []ImportEdge{
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Spider-Man", TargetLabel: "Character", Target: "Black Cat"},
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Black Cat", TargetLabel: "Character", Target: "Spider-Man"},
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Spider-Man", TargetLabel: "Character", Target: "Black Cat"},
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Vermin (comics)", TargetLabel: "Character", Target: "Vermin (comics)"},
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Spider-Man", TargetLabel: "Character", Target: "Mary Jane", File: "edges.csv"},
+		{Type: "APPEARS_IN", SourceLabel: "Hero", Source: "SPIDER-MAN/PETER PARKER", TargetLabel: "Comic", Target: "ASM 1"},
+	} {
+		q.Edge(edge)
+	}
+
+	report := q.Report()
+	counts := map[string]int{}
+	examples := map[string][]string{}
+	for _, check := range report.Checks {
+		counts[check.Name] = check.Count
+		examples[check.Name] = check.Examples
+	}
+	wantCounts := map[string]int{
+		"orphan_references":        1,
+		"duplicate_nodes":          1,
+		"duplicate_relationships":  1,
+		"reciprocal_relationships": 1,
+		"self_loops":               1,
+		"isolated_nodes":           2,
+		"degree_outliers":          0,
+		"name_collisions":          1,
+		"cross_dataset_nodes":      2,
+	}
+	if !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("counts = %v, want %v", counts, wantCounts)
+	}
+	if report.Nodes != 7 || report.Relationships != 4 || report.Findings != 10 {
+		t.Errorf("report totals = %d nodes, %d relationships, %d findings", report.Nodes, report.Relationships, report.Findings)
+	}
+
+	wantExamples := map[string][]string{
+		"orphan_references":        {"Spider-Man -[PARTNERS_WITH]-> Mary Jane in edges.csv (no Character Mary Jane)"},
+		"reciprocal_relationships": {"Black Cat <-[PARTNERS_WITH]-> Spider-Man"},
+		"isolated_nodes":           {"Character Black cat", "Hero VERMIN"},
+		"name_collisions":          {fmt.Sprintf("Character Black Cat (%s) ≈ Character Black cat (%s)", partnerships, partnerships)},
+		"cross_dataset_nodes": {
+			fmt.Sprintf("Character Spider-Man (%s) ≈ Hero SPIDER-MAN/PETER PARKER (%s)", partnerships, social),
+			fmt.Sprintf("Character Vermin (comics) (%s) ≈ Hero VERMIN (%s)", partnerships, social),
+		},
+	}
+	for name, want := range wantExamples {
+		if !reflect.DeepEqual(examples[name], want) {
+			t.Errorf("%s examples = %q, want %q", name, examples[name], want)
+		}
+	}
+}
+
+func TestDegreeOutliers(t *testing.T) {
+	q := newQualityCollector()
+	q.Node(ImportNode{Label: "Comic", ID: "COC 1"})
+	for i := 0; i < 30; i++ {
+		hero := fmt.Sprintf("HERO %02d", i)
+		q.Node(ImportNode{Label: "Hero", ID: hero})
+		q.Edge(ImportEdge{Type: "KNOWS", SourceLabel: "Hero", Source: "HERO 00", TargetLabel: "Hero", Target: hero})
+	}
+
+	for _, check := range q.Report().Checks {
+		if check.Name == "degree_outliers" && (check.Count != 1 || check.Examples[0] != "Hero HERO 00: 31 relationships (Hero mean 2.0)") {
+			t.Errorf("degree outliers = %d %q", check.Count, check.Examples)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+const entityNeighborPageSize = 25
+
+// Labels and relationship types cannot be query parameters, so anything
+// spliced into Cypher must look like an identifier.
+var labelPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+type EntityRef struct {
+	Label string `json:"label"`
+	ID    string `json:"id"`
+}
+
+type RelationshipDegree struct {
+	Out int64 `json:"out"`
+	In  int64 `json:"in"`
+}
+
+type EntityDetail struct {
+	Label       string                        `json:"label"`
+	ID          string                        `json:"id"`
+	Properties  map[string]interface{}        `json:"properties"`
+	Degree      map[string]RelationshipDegree `json:"degree"`
+	TotalDegree int64                         `json:"total_degree"`
+}
+
+type EntityNeighbor struct {
+	Relationship string `json:"relationship"`
+	Direction    string `json:"direction"`
+	Label        string `json:"label"`
+	ID           string `json:"id"`
+}
+
+// neighborPattern builds the pattern from node n to its neighbors m through
+// r, optionally restricted to one relationship type and direction (out, in
+// or both). Types in symmetric are always matched in both directions.
+func neighborPattern(node, relationship, direction string, symmetric map[string]bool) (string, error) {
+	if relationship != "" {
+		if !relationshipTypePattern.MatchString(relationship) {
+			return "", fmt.Errorf("invalid relationship type %q", relationship)
+		}
+		// Symmetric pairs are stored in either direction
+		if symmetric[relationship] {
+			direction = "both"
+		}
+		relationship = ":" + relationship
+	}
+	switch direction {
+	case "", "both":
+		return node + "-[r" + relationship + "]-(m)", nil
+	case "out":
+		return node + "-[r" + relationship + "]->(m)", nil
+	case "in":
+		return node + "<-[r" + relationship + "]-(m)", nil
+	}
+	return "", fmt.Errorf("direction must be out, in or both, got %q", direction)
+}
+
+// findEntities lists the nodes with exactly this id, whatever their label.
+func findEntities(query GraphRecords, id string) ([]EntityRef, error) {
+	records, err := query(`MATCH (n {id: $id})
+RETURN labels(n)[0] AS label, n.id AS id
+LIMIT 10`, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, err
+	}
+	refs := []EntityRef{}
+	for _, record := range records {
+		ref := EntityRef{}
+		ref.Label, _ = record["label"].(string)
+		ref.ID, _ = record["id"].(string)
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// getEntity returns a node's properties and its degree per relationship type
+// and direction. The bool is false when no such node exists.
+func getEntity(query GraphRecords, label, id string) (EntityDetail, bool, error) {
+	if !labelPattern.MatchString(label) {
+		return EntityDetail{}, false, fmt.Errorf("invalid label %q", label)
+	}
+
+	records, err := query(`MATCH (n:`+label+` {id: $id})
+RETURN properties(n) AS properties
+LIMIT 1`, map[string]interface{}{"id": id})
+	if err != nil || len(records) == 0 {
+		return EntityDetail{}, false, err
+	}
+	detail := EntityDetail{Label: label, ID: id, Degree: make(map[string]RelationshipDegree)}
+	detail.Properties, _ = records[0]["properties"].(map[string]interface{})
+
+	degrees, err := query(`MATCH (n:`+label+` {id: $id})-[r]-()
+RETURN type(r) AS type, startNode(r) = n AS outgoing, count(*) AS count`, map[string]interface{}{"id": id})
+	if err != nil {
+		return EntityDetail{}, false, err
+	}
+	for _, record := range degrees {
+		relationship, _ := record["type"].(string)
+		outgoing, _ := record["outgoing"].(bool)
+		count, _ := record["count"].(int64)
+		degree := detail.Degree[relationship]
+		if outgoing {
+			degree.Out += count
+		} else {
+			degree.In += count
+		}
+		detail.Degree[relationship] = degree
+		detail.TotalDegree += count
+	}
+	return detail, true, nil
+}
+
+// getEntityNeighbors returns one page of a node's neighbors, ordered by id,
+// and the total number matching the relationship and direction filters.
+func getEntityNeighbors(query GraphRecords, label, id, relationship, direction string, symmetric map[string]bool, page, pageSize int) ([]EntityNeighbor, int64, error) {
+	if !labelPattern.MatchString(label) {
+		return nil, 0, fmt.Errorf("invalid label %q", label)
+	}
+	pattern, err := neighborPattern("(n:"+label+" {id: $id})", relationship, direction, symmetric)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	counts, err := query(`MATCH `+pattern+`
+RETURN count(*) AS total`, map[string]interface{}{"id": id})
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if len(counts) > 0 {
+		total, _ = counts[0]["total"].(int64)
+	}
+
+	records, err := query(`MATCH `+pattern+`
+RETURN type(r) AS relationship, CASE WHEN startNode(r) = n THEN 'out' ELSE 'in' END AS direction, labels(m)[0] AS label, m.id AS id
+ORDER BY id, relationship
+SKIP $skip LIMIT $limit`, map[string]interface{}{"id": id, "skip": (page - 1) * pageSize, "limit": pageSize})
+	if err != nil {
+		return nil, 0, err
+	}
+	neighbors := []EntityNeighbor{}
+	for _, record := range records {
+		neighbor := EntityNeighbor{}
+		neighbor.Relationship, _ = record["relationship"].(string)
+		neighbor.Direction, _ = record["direction"].(string)
+		neighbor.Label, _ = record["label"].(string)
+		neighbor.ID, _ = record["id"].(string)
+		neighbors = append(neighbors, neighbor)
+	}
+	return neighbors, total, nil
+}
+
+func handleFindEntities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	refs, err := findEntities(fetchGraphRecords(ns.Name), r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"entities": refs})
+}
+
+func handleEntity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	detail, ok, err := getEntity(fetchGraphRecords(ns.Name), r.PathValue("label"), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !ok {
+		http.Error(w, "Entity not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(detail)
+}
+
+func handleEntityNeighbors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = entityNeighborPageSize
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	neighbors, total, err := getEntityNeighbors(fetchGraphRecords(ns.Name), r.PathValue("label"), r.PathValue("id"),
+		r.URL.Query().Get("rel"), r.URL.Query().Get("dir"), ns.Symmetric, page, pageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := map[string]interface{}{
+		"neighbors": neighbors,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
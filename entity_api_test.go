@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNeighborPattern(t *testing.T) {
+	tests := []struct {
+		relationship string
+		direction    string
+		want         string
+		wantErr      bool
+	}{
+		{"", "", "(n)-[r]-(m)", false},
+		{"PARTNERS_WITH", "out", "(n)-[r:PARTNERS_WITH]->(m)", false},
+		{"APPEARS_IN", "in", "(n)<-[r:APPEARS_IN]-(m)", false},
+		{"KNOWS]-() DELETE n //", "", "", true},
+		{"", "up", "", true},
+	}
+	for _, tc := range tests {
+		got, err := neighborPattern("(n)", tc.relationship, tc.direction, nil)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("neighborPattern(%q, %q) = %q, %v; want %q", tc.relationship, tc.direction, got, err, tc.want)
+		}
+	}
+}
+
+func TestGetEntity(t *testing.T) {
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"properties(n)": {{"properties": map[string]interface{}{"id": "Spider-Man", "group": "Avengers"}}},
+		"count(*) AS count": {
+			{"type": "PARTNERS_WITH", "outgoing": true, "count": int64(3)},
+			{"type": "PARTNERS_WITH", "outgoing": false, "count": int64(2)},
+		},
+	}}
+
+	detail, ok, err := getEntity(graph.query, "Character", "Spider-Man")
+	if err != nil || !ok {
+		t.Fatalf("getEntity = %v, %v", ok, err)
+	}
+	want := EntityDetail{
+		Label:       "Character",
+		ID:          "Spider-Man",
+		Properties:  map[string]interface{}{"id": "Spider-Man", "group": "Avengers"},
+		Degree:      map[string]RelationshipDegree{"PARTNERS_WITH": {Out: 3, In: 2}},
+		TotalDegree: 5,
+	}
+	if !reflect.DeepEqual(detail, want) {
+		t.Errorf("detail = %+v, want %+v", detail, want)
+	}
+
+	if _, ok, err := getEntity((&stubGraph{}).query, "Character", "Nobody"); ok || err != nil {
+		t.Errorf("missing entity: ok = %v, err = %v", ok, err)
+	}
+	if _, _, err := getEntity(graph.query, "Character) DETACH DELETE (n", "x"); err == nil {
+		t.Error("expected an error for an invalid label")
+	}
+}
+
+func TestGetEntityNeighbors(t *testing.T) {
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"count(*) AS total": {{"total": int64(30)}},
+		"SKIP $skip":        {{"relationship": "PARTNERS_WITH", "direction": "in", "label": "Character", "id": "Black Cat"}},
+	}}
+
+	neighbors, total, err := getEntityNeighbors(graph.query, "Character", "Spider-Man", "PARTNERS_WITH", "in", nil, 3, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 30 {
+		t.Errorf("total = %d, want 30", total)
+	}
+	if want := []EntityNeighbor{{Relationship: "PARTNERS_WITH", Direction: "in", Label: "Character", ID: "Black Cat"}}; !reflect.DeepEqual(neighbors, want) {
+		t.Errorf("neighbors = %+v, want %+v", neighbors, want)
+	}
+	if !strings.Contains(graph.queries[1], "MATCH (n:Character {id: $id})<-[r:PARTNERS_WITH]-(m)") {
+		t.Errorf("unexpected query %q", graph.queries[1])
+	}
+	if graph.params[1]["skip"] != 20 || graph.params[1]["limit"] != 10 {
+		t.Errorf("page 3 of 10 should skip 20, got params %v", graph.params[1])
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type EvalCase struct {
+	ID              string   `json:"id"`
+	Question        string   `json:"question"`
+	GoldCypher      string   `json:"gold_cypher,omitempty"`
+	ExpectedResults []string `json:"expected_results,omitempty"`
+}
+
+type EvalCaseResult struct {
+	ID          string   `json:"id"`
+	Question    string   `json:"question"`
+	Cypher      string   `json:"cypher"`
+	Valid       bool     `json:"valid"`
+	Error       string   `json:"error,omitempty"`
+	Rows        []string `json:"rows"`
+	Expected    []string `json:"expected"`
+	Precision   float64  `json:"precision"`
+	Recall      float64  `json:"recall"`
+	F1          float64  `json:"f1"`
+	Correct     bool     `json:"correct"`
+	CypherMatch bool     `json:"cypher_match"`
+	// RepairAttempts counts the rejected queries sent back to the model
+	RepairAttempts int          `json:"repair_attempts"`
+	Latency        QueryLatency `json:"latency"`
+}
+
+type LatencyPercentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+}
+
+type EvalSummary struct {
+	Cases             int     `json:"cases"`
+	ExecutionAccuracy float64 `json:"execution_accuracy"`
+	MeanF1            float64 `json:"mean_f1"`
+	ValidityRate      float64 `json:"validity_rate"`
+	CypherExactMatch  float64 `json:"cypher_exact_match"`
+	// RepairRate is the share of cases that needed at least one repair
+	RepairRate         float64                       `json:"repair_rate"`
+	MeanRepairAttempts float64                       `json:"mean_repair_attempts"`
+	Latency            map[string]LatencyPercentiles `json:"latency_ms"`
+}
+
+// EvalSnapshot records the graph snapshot a run was pinned to.
+type EvalSnapshot struct {
+	Path          string `json:"path"`
+	CreatedAt     string `json:"created_at"`
+	Nodes         int64  `json:"nodes"`
+	Relationships int64  `json:"relationships"`
+}
+
+type EvalReport struct {
+	RunAt     string           `json:"run_at"`
+	Suite     string           `json:"suite"`
+	Namespace string           `json:"namespace,omitempty"`
+	Snapshot  *EvalSnapshot    `json:"snapshot,omitempty"`
+	Models    ModelNames       `json:"models"`
+	Prompts   PromptVersions   `json:"prompt_versions"`
+	Summary   EvalSummary      `json:"summary"`
+	Cases     []EvalCaseResult `json:"cases"`
+}
+
+func runEvalCommand(args []string) {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	suitePath := flags.String("suite", "eval/suite.json", "evaluation suite to run")
+	outPrefix := flags.String("out", "eval/runs/latest", "output path prefix for the .json and .md reports")
+	baselinePath := flags.String("baseline", "", "previous report (.json) to diff against")
+	snapshotPath := flags.String("snapshot", "", "graph snapshot to restore before running (replaces the database contents)")
+	namespace := namespaceFlag(flags)
+	flags.Parse(args)
+	checkNamespaceFlag(*namespace)
+
+	content, err := os.ReadFile(*suitePath)
+	if err != nil {
+		log.Fatalf("Failed to read eval suite: %v", err)
+	}
+	var cases []EvalCase
+	if err := json.Unmarshal(content, &cases); err != nil {
+		log.Fatalf("Invalid eval suite %s: %v", *suitePath, err)
+	}
+
+	initQueryPipeline()
+	defer driver.Close()
+
+	report := EvalReport{
+		RunAt:     time.Now().UTC().Format(time.RFC3339),
+		Suite:     *suitePath,
+		Namespace: *namespace,
+		Models:    modelNames,
+		Prompts:   PromptVersions{Cypher: promptStore.Select(promptKindCypher, "").Version, Answer: promptStore.Select(promptKindAnswer, "").Version},
+	}
+
+	// Pin the run to a known graph state
+	if *snapshotPath != "" {
+		header, err := restoreSnapshot(driver, *namespace, *snapshotPath, true)
+		if err != nil {
+			log.Fatalf("Failed to restore snapshot: %v", err)
+		}
+		if _, err := refreshNamespace(*namespace); err != nil {
+			log.Fatalf("Failed to read graph schema: %v", err)
+		}
+		report.Snapshot = &EvalSnapshot{Path: *snapshotPath, CreatedAt: header.CreatedAt, Nodes: header.Nodes, Relationships: header.Relationships}
+		fmt.Printf("📸 Restored snapshot %s (taken %s)\n", *snapshotPath, header.CreatedAt)
+	}
+
+	ns, err := lookupNamespace(*namespace)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	// Gold answers come from the suite, or from running the gold Cypher. A
+	// gold query that fails would score every case against no rows, so the
+	// run stops before asking the model anything.
+	expected := make([][]string, len(cases))
+	for i, evalCase := range cases {
+		expected[i] = evalCase.ExpectedResults
+		if len(expected[i]) == 0 && evalCase.GoldCypher != "" {
+			goldResults, _ := executeGraphQuery(ns.Name, evalCase.GoldCypher, nil)
+			rows, ok := parseResultRows(goldResults)
+			if !ok {
+				log.Fatalf("Gold Cypher of case %s failed: %s", evalCase.ID, goldResults)
+			}
+			expected[i] = rows
+		}
+	}
+
+	for i, evalCase := range cases {
+		fmt.Printf("🧪 [%d/%d] %s\n", i+1, len(cases), evalCase.Question)
+		response := runQueryPipeline(evalCase.Question, ns)
+		report.Cases = append(report.Cases, scoreEvalCase(evalCase, expected[i], response))
+	}
+	report.Summary = summarizeEval(report.Cases)
+
+	var baseline *EvalReport
+	if *baselinePath != "" {
+		content, err := os.ReadFile(*baselinePath)
+		if err != nil {
+			log.Fatalf("Failed to read baseline: %v", err)
+		}
+		baseline = &EvalReport{}
+		if err := json.Unmarshal(content, baseline); err != nil {
+			log.Fatalf("Invalid baseline %s: %v", *baselinePath, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(*outPrefix), 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	jsonReport, _ := json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(*outPrefix+".json", jsonReport, 0644); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+	markdown := renderEvalMarkdown(report, baseline)
+	if err := os.WriteFile(*outPrefix+".md", []byte(markdown), 0644); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+
+	fmt.Println()
+	fmt.Println(markdown)
+	fmt.Printf("✅ Reports written to %s.json and %s.md\n", *outPrefix, *outPrefix)
+}
+
+// parseResultRows turns executeQuery output back into rows. The second value
+// is false when the query failed to execute.
+func parseResultRows(results string) ([]string, bool) {
+	if strings.HasPrefix(results, queryErrorPrefix) {
+		return nil, false
+	}
+	if results == noResultsMessage || results == "" {
+		return []string{}, true
+	}
+	return strings.Split(results, "\n"), true
+}
+
+// scoreEvalCase compares returned rows to the expected values. A row counts
+// toward precision when it mentions at least one expected value as a whole
+// word, and an expected value counts toward recall when any row mentions it,
+// so formatted rows like "Partners: [A, B]" still score.
+func scoreEvalCase(evalCase EvalCase, expected []string, response QueryResponse) EvalCaseResult {
+	result := EvalCaseResult{
+		ID:             evalCase.ID,
+		Question:       evalCase.Question,
+		Cypher:         response.Cypher,
+		Error:          response.Error,
+		Expected:       expected,
+		Latency:        response.Latency,
+		RepairAttempts: response.RepairAttempts,
+		CypherMatch:    evalCase.GoldCypher != "" && normalizeCypher(evalCase.GoldCypher) == normalizeCypher(response.Cypher),
+	}
+	if response.Error != "" {
+		return result
+	}
+
+	rows, ok := parseResultRows(response.Results)
+	if !ok {
+		result.Error = response.Results
+		return result
+	}
+	result.Valid = true
+	result.Rows = rows
+
+	if len(expected) == 0 {
+		result.Precision, result.Recall, result.F1 = 1, 1, 1
+		if len(rows) > 0 {
+			result.Precision, result.F1 = 0, 0
+		}
+		result.Correct = len(rows) == 0
+		return result
+	}
+
+	found := 0
+	for _, value := range expected {
+		for _, row := range rows {
+			if mentionsValue(row, value) {
+				found++
+				break
+			}
+		}
+	}
+	relevant := 0
+	for _, row := range rows {
+		for _, value := range expected {
+			if mentionsValue(row, value) {
+				relevant++
+				break
+			}
+		}
+	}
+
+	result.Recall = float64(found) / float64(len(expected))
+	if len(rows) > 0 {
+		result.Precision = float64(relevant) / float64(len(rows))
+	}
+	if result.Precision+result.Recall > 0 {
+		result.F1 = 2 * result.Precision * result.Recall / (result.Precision + result.Recall)
+	}
+	result.Correct = result.Recall == 1 && result.Precision == 1
+	return result
+}
+
+func mentionsValue(row, value string) bool {
+	pattern := `(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(strings.TrimSpace(value)) + `($|[^\pL\pN])`
+	matched, _ := regexp.MatchString(pattern, row)
+	return matched
+}
+
+func summarizeEval(results []EvalCaseResult) EvalSummary {
+	summary := EvalSummary{Cases: len(results), Latency: make(map[string]LatencyPercentiles)}
+	if len(results) == 0 {
+		return summary
+	}
+
+	var correct, valid, cypherMatch, repaired, repairs int
+	var f1 float64
+	stages := map[string][]int64{}
+	for _, result := range results {
+		if result.Correct {
+			correct++
+		}
+		if result.Valid {
+			valid++
+		}
+		if result.CypherMatch {
+			cypherMatch++
+		}
+		if result.RepairAttempts > 0 {
+			repaired++
+		}
+		repairs += result.RepairAttempts
+		f1 += result.F1
+		stages["cypher"] = append(stages["cypher"], result.Latency.CypherMs)
+		stages["execution"] = append(stages["execution"], result.Latency.ExecutionMs)
+		stages["response"] = append(stages["response"], result.Latency.ResponseMs)
+		stages["total"] = append(stages["total"], result.Latency.TotalMs)
+	}
+
+	n := float64(len(results))
+	summary.ExecutionAccuracy = float64(correct) / n
+	summary.MeanF1 = f1 / n
+	summary.ValidityRate = float64(valid) / n
+	summary.CypherExactMatch = float64(cypherMatch) / n
+	summary.RepairRate = float64(repaired) / n
+	summary.MeanRepairAttempts = float64(repairs) / n
+	for stage, values := range stages {
+		summary.Latency[stage] = LatencyPercentiles{
+			P50: percentile(values, 50),
+			P90: percentile(values, 90),
+			P99: percentile(values, 99),
+		}
+	}
+	return summary
+}
+
+// percentile uses the nearest-rank method.
+func percentile(values []int64, p float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func renderEvalMarkdown(report EvalReport, baseline *EvalReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Evaluation: %s\n\n", report.Suite)
+	fmt.Fprintf(&sb, "Run %s · Cypher model `%s` · answer model `%s` · prompts cypher=%s answer=%s\n\n",
+		report.RunAt, report.Models.Cypher, report.Models.Answer, report.Prompts.Cypher, report.Prompts.Answer)
+	if report.Snapshot != nil {
+		fmt.Fprintf(&sb, "Graph snapshot `%s` taken %s (%d nodes, %d relationships)\n\n",
+			report.Snapshot.Path, report.Snapshot.CreatedAt, report.Snapshot.Nodes, report.Snapshot.Relationships)
+	}
+
+	metric := func(name string, current float64, previous func(EvalSummary) float64) {
+		if baseline == nil {
+			fmt.Fprintf(&sb, "| %s | %.3f |\n", name, current)
+			return
+		}
+		before := previous(baseline.Summary)
+		fmt.Fprintf(&sb, "| %s | %.3f | %.3f | %+.3f |\n", name, current, before, current-before)
+	}
+
+	if baseline == nil {
+		sb.WriteString("| Metric | Value |\n|--------|-------|\n")
+	} else {
+		sb.WriteString("| Metric | Current | Baseline | Δ |\n|--------|---------|----------|---|\n")
+	}
+	metric("Execution accuracy", report.Summary.ExecutionAccuracy, func(s EvalSummary) float64 { return s.ExecutionAccuracy })
+	metric("Result-set F1", report.Summary.MeanF1, func(s EvalSummary) float64 { return s.MeanF1 })
+	metric("Validity rate", report.Summary.ValidityRate, func(s EvalSummary) float64 { return s.ValidityRate })
+	metric("Cypher exact match", report.Summary.CypherExactMatch, func(s EvalSummary) float64 { return s.CypherExactMatch })
+	metric("Repair rate", report.Summary.RepairRate, func(s EvalSummary) float64 { return s.RepairRate })
+	metric("Repair attempts per case", report.Summary.MeanRepairAttempts, func(s EvalSummary) float64 { return s.MeanRepairAttempts })
+
+	sb.WriteString("\n| Latency (ms) | p50 | p90 | p99 |\n|--------------|-----|-----|-----|\n")
+	for _, stage := range []string{"cypher", "execution", "response", "total"} {
+		current := report.Summary.Latency[stage]
+		cell := func(value int64, previous func(LatencyPercentiles) int64) string {
+			if baseline == nil {
+				return fmt.Sprintf("%d", value)
+			}
+			return fmt.Sprintf("%d (%+d)", value, value-previous(baseline.Summary.Latency[stage]))
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", stage,
+			cell(current.P50, func(l LatencyPercentiles) int64 { return l.P50 }),
+			cell(current.P90, func(l LatencyPercentiles) int64 { return l.P90 }),
+			cell(current.P99, func(l LatencyPercentiles) int64 { return l.P99 }))
+	}
+
+	sb.WriteString("\n| Case | Valid | Correct | F1 | Repairs | Total ms |\n|------|-------|---------|----|---------|----------|\n")
+	for _, result := range report.Cases {
+		fmt.Fprintf(&sb, "| %s | %v | %v | %.2f | %d | %d |\n", result.ID, result.Valid, result.Correct, result.F1, result.RepairAttempts, result.Latency.TotalMs)
+	}
+
+	if baseline != nil {
+		previous := make(map[string]EvalCaseResult)
+		for _, result := range baseline.Cases {
+			previous[result.ID] = result
+		}
+		var fixed, regressed []string
+		for _, result := range report.Cases {
+			before, ok := previous[result.ID]
+			if !ok {
+				continue
+			}
+			if result.Correct && !before.Correct {
+				fixed = append(fixed, result.ID)
+			}
+			if !result.Correct && before.Correct {
+				regressed = append(regressed, result.ID)
+			}
+		}
+		fmt.Fprintf(&sb, "\nFixed since baseline: %s\n", joinOrNone(fixed))
+		fmt.Fprintf(&sb, "Regressed since baseline: %s\n", joinOrNone(regressed))
+	}
+	return sb.String()
+}
+
+func joinOrNone(ids []string) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScoreEvalCase(t *testing.T) {
+	tests := []struct {
+		name        string
+		expected    []string
+		response    QueryResponse
+		wantValid   bool
+		wantCorrect bool
+		wantF1      float64
+	}{
+		{
+			name:        "exact rows",
+			expected:    []string{"Kingpin (character)", "Silk (comics)"},
+			response:    QueryResponse{Results: "Kingpin (character)\nSilk (comics)"},
+			wantValid:   true,
+			wantCorrect: true,
+			wantF1:      1,
+		},
+		{
+			name:        "formatted row mentions every value",
+			expected:    []string{"Kingpin (character)", "Silk (comics)"},
+			response:    QueryResponse{Results: "Character: Spider-Man, Partners: [Kingpin (character) Silk (comics)]"},
+			wantValid:   true,
+			wantCorrect: true,
+			wantF1:      1,
+		},
+		{
+			name:      "half recall with an extra row",
+			expected:  []string{"Pepper Potts", "Riri Williams"},
+			response:  QueryResponse{Results: "Pepper Potts\nHappy Hogan"},
+			wantValid: true,
+			wantF1:    0.5,
+		},
+		{
+			name:      "count must match as a whole word",
+			expected:  []string{"115"},
+			response:  QueryResponse{Results: "IRON MAN appears in 1150 comics"},
+			wantValid: true,
+		},
+		{
+			name:     "execution error is invalid",
+			expected: []string{"Rikki Barnes"},
+			response: QueryResponse{Results: queryErrorPrefix + ": syntax error"},
+		},
+		{
+			name:     "generation error is invalid",
+			expected: []string{"Rikki Barnes"},
+			response: QueryResponse{Error: "Failed to generate query"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scoreEvalCase(EvalCase{ID: tc.name}, tc.expected, tc.response)
+			if got.Valid != tc.wantValid || got.Correct != tc.wantCorrect || math.Abs(got.F1-tc.wantF1) > 1e-9 {
+				t.Errorf("got valid=%v correct=%v f1=%.3f, want valid=%v correct=%v f1=%.3f",
+					got.Valid, got.Correct, got.F1, tc.wantValid, tc.wantCorrect, tc.wantF1)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []int64{50, 10, 40, 20, 30, 60, 70, 80, 90, 100}
+	for p, want := range map[float64]int64{50: 50, 90: 90, 99: 100} {
+		if got := percentile(values, p); got != want {
+			t.Errorf("p%.0f = %d, want %d", p, got, want)
+		}
+	}
+}
+
+func TestScoreEvalCaseCypherMatch(t *testing.T) {
+	gold := "MATCH (c:Character {id: 'Hulk'})-[:APPEARS_IN]->(m) RETURN count(m) AS result"
+	tests := []struct {
+		name   string
+		cypher string
+		want   bool
+	}{
+		{"identical", gold, true},
+		{"keyword case, whitespace and semicolon", "match (c:Character {id: 'Hulk'})-[:APPEARS_IN]->(m)\n  return count(m) as result;", true},
+		{"literal case differs", "MATCH (c:Character {id: 'hulk'})-[:APPEARS_IN]->(m) RETURN count(m) AS result", false},
+		{"label case differs", "MATCH (c:character {id: 'Hulk'})-[:APPEARS_IN]->(m) RETURN count(m) AS result", false},
+		{"whitespace inside a literal", "MATCH (c:Character {id: 'Hulk '})-[:APPEARS_IN]->(m) RETURN count(m) AS result", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := scoreEvalCase(EvalCase{ID: tc.name, GoldCypher: gold}, nil, QueryResponse{Cypher: tc.cypher})
+			if got.CypherMatch != tc.want {
+				t.Errorf("cypher match = %v, want %v", got.CypherMatch, tc.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeEvalRepairs(t *testing.T) {
+	summary := summarizeEval([]EvalCaseResult{
+		{ID: "a", Correct: true},
+		{ID: "b", Correct: true, RepairAttempts: 1},
+		{ID: "c", RepairAttempts: 2},
+		{ID: "d"},
+	})
+	if summary.RepairRate != 0.5 || summary.MeanRepairAttempts != 0.75 || summary.ExecutionAccuracy != 0.5 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
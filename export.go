@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	exportCSV     = "csv"
+	exportJSONL   = "jsonl"
+	exportGraphML = "graphml"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:     "text/csv; charset=utf-8",
+	exportJSONL:   "application/x-ndjson",
+	exportGraphML: "application/graphml+xml",
+}
+
+// RecordStream runs a read query and hands each record to emit as it arrives,
+// so exports never hold a whole result in memory.
+type RecordStream func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error
+
+// exportMetadata travels with every export so a file can be traced back to
+// the question and query that produced it. Exports of a past query run its
+// Cypher again at ExportedAt, so ReExecuted is set and the rows may differ
+// from the answer given at AskedAt.
+type exportMetadata struct {
+	Source     string                 `json:"source"`
+	Question   string                 `json:"question,omitempty"`
+	Cypher     string                 `json:"cypher,omitempty"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	AskedAt    string                 `json:"asked_at,omitempty"`
+	ReExecuted bool                   `json:"re_executed,omitempty"`
+	ExportedAt string                 `json:"exported_at"`
+}
+
+// fields lists the metadata that is set as key/value pairs named like its
+// JSON fields, with whitespace collapsed so each value fits on one line.
+func (meta exportMetadata) fields() [][2]string {
+	values := [][2]string{
+		{"source", meta.Source}, {"question", meta.Question}, {"cypher", meta.Cypher},
+	}
+	if len(meta.Params) > 0 {
+		params, _ := json.Marshal(meta.Params)
+		values = append(values, [2]string{"params", string(params)})
+	}
+	values = append(values, [2]string{"asked_at", meta.AskedAt})
+	if meta.ReExecuted {
+		values = append(values, [2]string{"re_executed", "true"})
+	}
+	values = append(values, [2]string{"exported_at", meta.ExportedAt})
+
+	var fields [][2]string
+	for _, value := range values {
+		if value[1] != "" {
+			fields = append(fields, [2]string{value[0], strings.Join(strings.Fields(value[1]), " ")})
+		}
+	}
+	return fields
+}
+
+// setExportHeaders also sends the metadata as X-Export-* response headers,
+// so clients can read it without parsing the body.
+func setExportHeaders(header http.Header, meta exportMetadata) {
+	for _, field := range meta.fields() {
+		words := strings.Split(field[0], "_")
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+		header.Set("X-Export-"+strings.Join(words, "-"), field[1])
+	}
+}
+
+// tableExporter writes rows of one result in a tabular format.
+type tableExporter interface {
+	WriteHeader(keys []string) error
+	WriteRow(values []interface{}) error
+	Flush() error
+}
+
+func newTableExporter(format string, w io.Writer, meta exportMetadata) (tableExporter, error) {
+	switch format {
+	case exportCSV:
+		// CSV has no metadata section, so it leads the file as "# key: value"
+		// comment lines, which csv.Reader skips when Comment is '#'
+		for _, field := range meta.fields() {
+			if _, err := fmt.Fprintf(w, "# %s: %s\n", field[0], field[1]); err != nil {
+				return nil, err
+			}
+		}
+		return &csvExporter{writer: csv.NewWriter(w)}, nil
+
+	case exportJSONL:
+		exporter := &jsonlExporter{encoder: json.NewEncoder(w)}
+		exporter.encoder.SetEscapeHTML(false)
+		if err := exporter.encoder.Encode(map[string]interface{}{"metadata": meta}); err != nil {
+			return nil, err
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unsupported table format %q", format)
+}
+
+type csvExporter struct {
+	writer *csv.Writer
+}
+
+func (e *csvExporter) WriteHeader(keys []string) error {
+	return e.writer.Write(keys)
+}
+
+func (e *csvExporter) WriteRow(values []interface{}) error {
+	row := make([]string, len(values))
+	for i, value := range values {
+		row[i] = exportCell(value)
+	}
+	return e.writer.Write(row)
+}
+
+func (e *csvExporter) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type jsonlExporter struct {
+	encoder *json.Encoder
+	keys    []string
+}
+
+func (e *jsonlExporter) WriteHeader(keys []string) error {
+	e.keys = keys
+	return nil
+}
+
+func (e *jsonlExporter) WriteRow(values []interface{}) error {
+	record := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		if i < len(e.keys) {
+			record[e.keys[i]] = value
+		}
+	}
+	return e.encoder.Encode(record)
+}
+
+func (e *jsonlExporter) Flush() error {
+	return nil
+}
+
+// exportCell renders a value for a CSV cell: strings as they are, anything
+// else (numbers, lists, nodes) as JSON.
+func exportCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+// writeSubgraphTable writes a subgraph as rows, nodes first, for the tabular
+// formats.
+func writeSubgraphTable(exporter tableExporter, subgraph Subgraph) error {
+	if err := exporter.WriteHeader([]string{"kind", "id", "label", "degree", "source", "type", "target"}); err != nil {
+		return err
+	}
+	for _, node := range subgraph.Nodes {
+		if err := exporter.WriteRow([]interface{}{"node", node.ID, node.Label, node.Degree, nil, nil, nil}); err != nil {
+			return err
+		}
+	}
+	for _, edge := range subgraph.Edges {
+		if err := exporter.WriteRow([]interface{}{"relationship", nil, nil, nil, edge.Source, edge.Type, edge.Target}); err != nil {
+			return err
+		}
+	}
+	return exporter.Flush()
+}
+
+// writeGraphML writes a subgraph as GraphML, with the metadata as graph-level
+// data so Gephi and yEd show where it came from.
+func writeGraphML(w io.Writer, meta exportMetadata, subgraph Subgraph) error {
+	params, _ := json.Marshal(meta.Params)
+
+	var sb strings.Builder
+	sb.WriteString(xml.Header)
+	sb.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	for _, key := range [][3]string{
+		{"source", "graph", "string"}, {"question", "graph", "string"}, {"cypher", "graph", "string"},
+		{"params", "graph", "string"}, {"asked_at", "graph", "string"}, {"re_executed", "graph", "boolean"},
+		{"exported_at", "graph", "string"},
+		{"label", "node", "string"}, {"degree", "node", "long"}, {"type", "edge", "string"},
+	} {
+		fmt.Fprintf(&sb, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"/>`+"\n", key[0], key[1], key[0], key[2])
+	}
+	sb.WriteString(`  <graph id="G" edgedefault="directed">` + "\n")
+	for _, data := range [][2]string{
+		{"source", meta.Source}, {"question", meta.Question}, {"cypher", meta.Cypher},
+		{"params", string(params)}, {"asked_at", meta.AskedAt}, {"re_executed", strconv.FormatBool(meta.ReExecuted)},
+		{"exported_at", meta.ExportedAt},
+	} {
+		fmt.Fprintf(&sb, `    <data key="%s">%s</data>`+"\n", data[0], xmlEscape(data[1]))
+	}
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return err
+	}
+
+	for _, node := range subgraph.Nodes {
+		if _, err := fmt.Fprintf(w, `    <node id="%s"><data key="label">%s</data><data key="degree">%d</data></node>`+"\n",
+			xmlEscape(node.ID), xmlEscape(node.Label), node.Degree); err != nil {
+			return err
+		}
+	}
+	for i, edge := range subgraph.Edges {
+		if _, err := fmt.Fprintf(w, `    <edge id="e%d" source="%s" target="%s"><data key="type">%s</data></edge>`+"\n",
+			i, xmlEscape(edge.Source), xmlEscape(edge.Target), xmlEscape(edge.Type)); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "  </graph>\n</graphml>\n")
+	return err
+}
+
+func xmlEscape(value string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(value))
+	return sb.String()
+}
+
+// exportQueryResult streams every row of the query's Cypher, all columns,
+// rather than the first-column summary shown in the chat.
+func exportQueryResult(stream RecordStream, exporter tableExporter, cypherQuery string, params map[string]interface{}, flush func()) error {
+	rows := 0
+	wroteHeader := false
+	err := stream(cypherQuery, params, func(keys []string, values []interface{}) error {
+		if !wroteHeader {
+			if err := exporter.WriteHeader(keys); err != nil {
+				return err
+			}
+			wroteHeader = true
+		}
+		if err := exporter.WriteRow(values); err != nil {
+			return err
+		}
+		if rows++; rows%500 == 0 {
+			exporter.Flush()
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return exporter.Flush()
+}
+
+// handleExport downloads a past query's result (?id=) or a node's
+// neighborhood (?node=&limit=&namespace=) as CSV, JSON Lines or GraphML
+// (?format=). GraphML, and queries without Cypher such as agent runs, export
+// the subgraph the query touched, in the namespace it was asked in. Either
+// way the graph is queried again, not the rows stored with the answer.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportCSV
+	}
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown export format %q (expected csv, jsonl or graphml)", format), http.StatusBadRequest)
+		return
+	}
+
+	meta := exportMetadata{ExportedAt: time.Now().UTC().Format(time.RFC3339)}
+	node := r.URL.Query().Get("node")
+	namespace := r.URL.Query().Get("namespace")
+	if err := validateNamespace(namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var entry HistoryEntry
+	var filename string
+	if node != "" {
+		meta.Source = "neighborhood of " + node
+		filename = "neighborhood"
+	} else {
+		if historyStore == nil {
+			http.Error(w, "Query history is not available", http.StatusServiceUnavailable)
+			return
+		}
+		var found bool
+		entry, found = historyStore.Get(r.URL.Query().Get("id"))
+		if !found {
+			http.Error(w, "Unknown query id", http.StatusNotFound)
+			return
+		}
+		meta.Source = "query " + entry.ID
+		meta.Question = entry.Query
+		meta.Cypher = entry.Cypher
+		meta.Params = entry.Params
+		meta.AskedAt = entry.Timestamp
+		meta.ReExecuted = true
+		namespace = entry.Namespace
+		filename = "query-" + entry.ID
+	}
+
+	// Subgraph exports are bounded, so build them before writing anything
+	var subgraph Subgraph
+	var err error
+	if node != "" {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > graphRecordLimit {
+			limit = neighborPageLimit
+		}
+		subgraph, err = queryNeighborhood(fetchGraphRecords(namespace), node, limit)
+	} else if format == exportGraphML || entry.Cypher == "" {
+		subgraph, err = querySubgraph(fetchGraphRecords(namespace), queryGraphIDs(entry.QueryResponse))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	setExportHeaders(w.Header(), meta)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	if format == exportGraphML {
+		err = writeGraphML(w, meta, subgraph)
+	} else {
+		var exporter tableExporter
+		exporter, err = newTableExporter(format, w, meta)
+		if err == nil {
+			if node == "" && entry.Cypher != "" {
+				flush := func() {
+					if flusher, ok := w.(http.Flusher); ok {
+						flusher.Flush()
+					}
+				}
+				err = exportQueryResult(streamGraphRecords(namespace), exporter, entry.Cypher, entry.Params, flush)
+			} else {
+				err = writeSubgraphTable(exporter, subgraph)
+			}
+		}
+	}
+	// Headers are gone by now, so a failure can only be logged
+	if err != nil {
+		log.Printf("Export of %s failed: %v", meta.Source, err)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testExportMeta = exportMetadata{
+	Source:     "query abc",
+	Question:   "Who are N'astirh's partners?",
+	Cypher:     "MATCH (c:Character {id: $name})\nRETURN c.id AS id, c.size AS size",
+	Params:     map[string]interface{}{"name": "N'astirh"},
+	AskedAt:    "2024-01-01T00:00:00Z",
+	ReExecuted: true,
+	ExportedAt: "2024-01-02T03:04:05Z",
+}
+
+func TestTableExporters(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: exportCSV,
+			want: `# source: query abc
+# question: Who are N'astirh's partners?
+# cypher: MATCH (c:Character {id: $name}) RETURN c.id AS id, c.size AS size
+# params: {"name":"N'astirh"}
+# asked_at: 2024-01-01T00:00:00Z
+# re_executed: true
+# exported_at: 2024-01-02T03:04:05Z
+id,size,groups
+"Cloak, Dagger",2,"[""X-Men"",""Avengers""]"
+S'ym,,
+`,
+		},
+		{
+			format: exportJSONL,
+			want: `{"metadata":{"source":"query abc","question":"Who are N'astirh's partners?","cypher":"MATCH (c:Character {id: $name})\nRETURN c.id AS id, c.size AS size","params":{"name":"N'astirh"},"asked_at":"2024-01-01T00:00:00Z","re_executed":true,"exported_at":"2024-01-02T03:04:05Z"}}
+{"groups":["X-Men","Avengers"],"id":"Cloak, Dagger","size":2}
+{"groups":null,"id":"S'ym","size":null}
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		var sb strings.Builder
+		exporter, err := newTableExporter(tc.format, &sb, testExportMeta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := []struct {
+			keys   []string
+			values []interface{}
+		}{
+			{[]string{"id", "size", "groups"}, []interface{}{"Cloak, Dagger", int64(2), []interface{}{"X-Men", "Avengers"}}},
+			{[]string{"id", "size", "groups"}, []interface{}{"S'ym", nil, nil}},
+		}
+		stream := func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+			for _, row := range rows {
+				if err := emit(row.keys, row.values); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := exportQueryResult(stream, exporter, testExportMeta.Cypher, testExportMeta.Params, func() {}); err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != tc.want {
+			t.Errorf("%s export:\n%s\nwant:\n%s", tc.format, sb.String(), tc.want)
+		}
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	subgraph := Subgraph{
+		Nodes: []GraphNode{{ID: "Cloak & Dagger", Label: "Character", Degree: 1}, {ID: "N'astirh", Label: "Character", Degree: 1}},
+		Edges: []GraphEdge{{Source: "Cloak & Dagger", Target: "N'astirh", Type: "PARTNERS_WITH"}},
+	}
+	var sb strings.Builder
+	if err := writeGraphML(&sb, testExportMeta, subgraph); err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		Graph struct {
+			Data  []string `xml:"data"`
+			Nodes []struct {
+				ID string `xml:"id,attr"`
+			} `xml:"node"`
+			Edges []struct {
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal([]byte(sb.String()), &document); err != nil {
+		t.Fatalf("invalid GraphML: %v\n%s", err, sb.String())
+	}
+	if len(document.Graph.Nodes) != 2 || document.Graph.Nodes[0].ID != "Cloak & Dagger" {
+		t.Errorf("nodes = %+v", document.Graph.Nodes)
+	}
+	if len(document.Graph.Edges) != 1 || document.Graph.Edges[0].Target != "N'astirh" {
+		t.Errorf("edges = %+v", document.Graph.Edges)
+	}
+	if len(document.Graph.Data) != 7 || document.Graph.Data[5] != "true" || document.Graph.Data[1] != testExportMeta.Question || document.Graph.Data[2] != testExportMeta.Cypher {
+		t.Errorf("graph metadata = %q", document.Graph.Data)
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	store, err := openHistoryStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	savedHistory, savedStream := historyStore, streamGraphRecords
+	t.Cleanup(func() { historyStore, streamGraphRecords = savedHistory, savedStream })
+	historyStore = store
+
+	entry := QueryResponse{ID: "q1", Timestamp: "2024-01-01T00:00:00Z", Query: "Who are Thor's partners?", Namespace: "experiments", Cypher: "MATCH (c {id: $name})--(p) RETURN p.id AS partner", Params: map[string]interface{}{"name": "Thor"}}
+	if err := store.Append(entry); err != nil {
+		t.Fatal(err)
+	}
+	var streamed, streamedNamespace string
+	streamGraphRecords = func(namespace string) RecordStream {
+		return func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+			streamed, streamedNamespace = cypherQuery, namespace
+			return emit([]string{"partner"}, []interface{}{"Hulk"})
+		}
+	}
+
+	tests := []struct {
+		url         string
+		status      int
+		contentType string
+		body        string
+	}{
+		// CSV leads with the metadata as comment lines
+		{url: "/api/export?id=q1", status: http.StatusOK, contentType: "text/csv; charset=utf-8", body: "# re_executed: true\n# exported_at: "},
+		{url: "/api/export?id=q1&format=jsonl", status: http.StatusOK, contentType: "application/x-ndjson", body: `{"partner":"Hulk"}`},
+		{url: "/api/export?id=q1&format=pdf", status: http.StatusBadRequest},
+		{url: "/api/export?id=missing", status: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		recorder := httptest.NewRecorder()
+		handleExport(recorder, httptest.NewRequest(http.MethodGet, tc.url, nil))
+		body, _ := io.ReadAll(recorder.Body)
+		if recorder.Code != tc.status {
+			t.Errorf("%s: status %d, want %d (%s)", tc.url, recorder.Code, tc.status, body)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := recorder.Header().Get("Content-Type"); got != tc.contentType {
+			t.Errorf("%s: content type %q, want %q", tc.url, got, tc.contentType)
+		}
+		if tc.contentType == exportContentTypes[exportCSV] {
+			// The metadata stays with the file once saved, and a reader
+			// that skips comments sees only the rows
+			if !strings.HasPrefix(string(body), "# source: query q1\n# question: "+entry.Query+"\n") {
+				t.Errorf("%s: body %q should start with the metadata", tc.url, body)
+			}
+			reader := csv.NewReader(strings.NewReader(string(body)))
+			reader.Comment = '#'
+			if records, err := reader.ReadAll(); err != nil || !reflect.DeepEqual(records, [][]string{{"partner"}, {"Hulk"}}) {
+				t.Errorf("%s: records %q, %v", tc.url, records, err)
+			}
+		}
+		if !strings.Contains(string(body), tc.body) {
+			t.Errorf("%s: body %q should contain %q", tc.url, body, tc.body)
+		}
+		header := recorder.Header()
+		if header.Get("X-Export-Question") != entry.Query || header.Get("X-Export-Re-Executed") != "true" || header.Get("X-Export-Asked-At") != entry.Timestamp {
+			t.Errorf("%s: metadata headers %v", tc.url, header)
+		}
+		if streamed != entry.Cypher || streamedNamespace != entry.Namespace {
+			t.Errorf("%s: streamed %q in %q, want %q in %q", tc.url, streamed, streamedNamespace, entry.Cypher, entry.Namespace)
+		}
+	}
+}
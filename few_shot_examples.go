@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	exampleLibraryPath  = "examples/cypher_examples.jsonl"
+	fewShotExampleCount = 4
+)
+
+type CypherExample struct {
+	Question string                 `json:"question"`
+	Cypher   string                 `json:"cypher"`
+	Params   map[string]interface{} `json:"params,omitempty"`
+	Source   string                 `json:"source,omitempty"`
+}
+
+// ExampleLibrary holds curated question→Cypher pairs and ranks them against
+// a user question with BM25 over the question text.
+type ExampleLibrary struct {
+	mu sync.RWMutex
+	// namespace is the graph the examples are written for
+	namespace string
+	path      string
+	examples  []CypherExample
+	tokens    [][]string
+	docFreq   map[string]int
+	avgLen    float64
+}
+
+func loadExampleLibrary(path string) (*ExampleLibrary, error) {
+	library := &ExampleLibrary{path: path, docFreq: make(map[string]int)}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return library, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open example library: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var example CypherExample
+		if err := json.Unmarshal([]byte(line), &example); err != nil {
+			return nil, fmt.Errorf("invalid example in %s: %v", path, err)
+		}
+		library.index(example)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read example library: %v", err)
+	}
+	return library, nil
+}
+
+// namespaceExamplePath is a namespace's example library file. Examples name
+// one graph's labels, so each namespace has its own: the shipped library for
+// the default namespace and examples/<name>.jsonl for the others.
+func namespaceExamplePath(name string) string {
+	if name == "" {
+		return exampleLibraryPath
+	}
+	return filepath.Join(filepath.Dir(exampleLibraryPath), name+".jsonl")
+}
+
+// namespaceExamples returns a namespace's example library, reading it the
+// first time it is used.
+func namespaceExamples(name string) (*ExampleLibrary, error) {
+	exampleLibrariesMu.Lock()
+	defer exampleLibrariesMu.Unlock()
+	if library, ok := exampleLibraries[name]; ok {
+		return library, nil
+	}
+	library, err := loadExampleLibrary(namespaceExamplePath(name))
+	if err != nil {
+		return nil, err
+	}
+	library.namespace = name
+	exampleLibraries[name] = library
+	return library, nil
+}
+
+func (l *ExampleLibrary) index(example CypherExample) {
+	tokens := tokenizeQuestion(example.Question)
+	seen := make(map[string]bool)
+	for _, token := range tokens {
+		if !seen[token] {
+			l.docFreq[token]++
+			seen[token] = true
+		}
+	}
+
+	totalLen := l.avgLen * float64(len(l.tokens))
+	l.examples = append(l.examples, example)
+	l.tokens = append(l.tokens, tokens)
+	l.avgLen = (totalLen + float64(len(tokens))) / float64(len(l.tokens))
+}
+
+// Add appends a verified pair to the library file and the in-memory index.
+func (l *ExampleLibrary) Add(example CypherExample) error {
+	example.Question = strings.TrimSpace(example.Question)
+	example.Cypher = strings.TrimSpace(example.Cypher)
+	if example.Question == "" || example.Cypher == "" {
+		return fmt.Errorf("question and cypher are required")
+	}
+	if err := validateCypherParams(example.Cypher, example.Params); err != nil {
+		return err
+	}
+	if err := validateRelationshipDirections(example.Cypher, knownNamespace(l.namespace).Symmetric); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, existing := range l.examples {
+		if strings.EqualFold(existing.Question, example.Question) && existing.Cypher == example.Cypher {
+			return nil
+		}
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open example library: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(example); err != nil {
+		return fmt.Errorf("failed to append example: %v", err)
+	}
+
+	l.index(example)
+	return nil
+}
+
+func (l *ExampleLibrary) All() []CypherExample {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]CypherExample(nil), l.examples...)
+}
+
+// TopK returns the k examples most similar to the question. When nothing
+// shares a term with the question, the first k examples are used instead so
+// the prompt always carries some patterns.
+func (l *ExampleLibrary) TopK(question string, k int) []CypherExample {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	const k1, b = 1.2, 0.75
+	queryTokens := tokenizeQuestion(question)
+	n := float64(len(l.examples))
+
+	type scored struct {
+		index int
+		score float64
+	}
+	var ranked []scored
+	for i, tokens := range l.tokens {
+		termFreq := make(map[string]int)
+		for _, token := range tokens {
+			termFreq[token]++
+		}
+
+		score := 0.0
+		for _, token := range queryTokens {
+			tf := float64(termFreq[token])
+			if tf == 0 {
+				continue
+			}
+			df := float64(l.docFreq[token])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(len(tokens))/l.avgLen))
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{i, score})
+		}
+	}
+
+	if len(ranked) == 0 {
+		for i := 0; i < len(l.examples) && i < k; i++ {
+			ranked = append(ranked, scored{i, 0})
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	examples := make([]CypherExample, 0, len(ranked))
+	for _, r := range ranked {
+		examples = append(examples, l.examples[r.index])
+	}
+	return examples
+}
+
+func tokenizeQuestion(text string) []string {
+	stopwords := map[string]bool{
+		"a": true, "an": true, "the": true, "of": true, "is": true, "are": true,
+		"s": true, "to": true, "in": true, "and": true, "or": true, "with": true,
+	}
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var tokens []string
+	for _, field := range fields {
+		if !stopwords[field] {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+// formatExamples renders each example as the JSON object the generator is
+// asked to return.
+func formatExamples(examples []CypherExample) string {
+	var sb strings.Builder
+	for _, example := range examples {
+		var encoded strings.Builder
+		encoder := json.NewEncoder(&encoded)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(CypherQuery{Cypher: example.Cypher, Params: example.Params})
+		fmt.Fprintf(&sb, "For questions like %q:\n%s\n", example.Question, encoded.String())
+	}
+	return strings.TrimSpace(sb.String())
+}
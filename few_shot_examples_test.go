@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExampleLibraryTopK(t *testing.T) {
+	questions := []string{
+		"Who partners with Spider-Man?",
+		"Which team is Wolverine a member of?",
+		"Which heroes know Captain America?",
+		"How many comics does Hulk appear in?",
+		"Who partners with Thor in the Avengers team and also knows Hulk?",
+		"Which movies does Thor appear in?",
+	}
+	var lines []string
+	for _, question := range questions {
+		lines = append(lines, `{"question": "`+question+`", "cypher": "MATCH (n) RETURN n.id AS result LIMIT 10"}`)
+	}
+	path := filepath.Join(t.TempDir(), "examples.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	library, err := loadExampleLibrary(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		k        int
+		want     []string
+	}{
+		{
+			name:     "shared rare term",
+			question: "What team is Storm a member of?",
+			k:        1,
+			want:     []string{questions[1]},
+		},
+		{
+			// Both mention partners; the shorter question matches more closely
+			name:     "length normalization",
+			question: "Who partners with Daredevil?",
+			k:        2,
+			want:     []string{questions[0], questions[4]},
+		},
+		{
+			// "thor" is in two questions, "movies" in one, so it weighs more
+			name:     "rarer terms weigh more",
+			question: "Which movies feature Thor?",
+			k:        2,
+			want:     []string{questions[5], questions[4]},
+		},
+		{
+			name:     "only matching examples",
+			question: "Count the comics",
+			k:        4,
+			want:     []string{questions[3]},
+		},
+		{
+			name:     "case and punctuation folded",
+			question: "HULK!!! comics???",
+			k:        1,
+			want:     []string{questions[3]},
+		},
+		{
+			// Stop words alone share nothing, so the first k are used
+			name:     "no shared terms",
+			question: "Is it the one with a cape?",
+			k:        2,
+			want:     questions[:2],
+		},
+		{
+			name:     "k larger than the library",
+			question: "Galactus",
+			k:        10,
+			want:     questions,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, example := range library.TopK(tc.question, tc.k) {
+				got = append(got, example.Question)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("TopK(%q, %d) = %q, want %q", tc.question, tc.k, got, tc.want)
+			}
+		})
+	}
+
+	empty, err := loadExampleLibrary(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := empty.TopK("Who partners with Thor?", 4); len(got) != 0 {
+		t.Errorf("empty library returned %v", got)
+	}
+}
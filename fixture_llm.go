@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+type LLMFixture struct {
+	Hash       string `json:"hash"`
+	Prompt     string `json:"prompt"`
+	Completion string `json:"completion"`
+}
+
+// FixtureStore is a set of recorded prompt→completion pairs keyed by a hash
+// of the prompt, backed by a JSON file.
+type FixtureStore struct {
+	mu       sync.Mutex
+	path     string
+	fixtures map[string]LLMFixture
+}
+
+// FixtureLLM is an llms.Model that replays completions from a FixtureStore.
+// With a wrapped model it runs in record mode instead: every call goes to the
+// real model and the exchange is saved, so a live session can be captured
+// once and replayed offline.
+type FixtureLLM struct {
+	store *FixtureStore
+	inner llms.Model
+}
+
+func openFixtureStore(path string, create bool) (*FixtureStore, error) {
+	store := &FixtureStore{path: path, fixtures: make(map[string]LLMFixture)}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LLM fixtures: %v", err)
+	}
+
+	var list []LLMFixture
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("invalid LLM fixtures in %s: %v", path, err)
+	}
+	for _, fixture := range list {
+		store.fixtures[fixture.Hash] = fixture
+	}
+	return store, nil
+}
+
+func (s *FixtureStore) lookup(hash string) (LLMFixture, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fixture, ok := s.fixtures[hash]
+	return fixture, ok
+}
+
+func (s *FixtureStore) record(fixture LLMFixture) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures[fixture.Hash] = fixture
+
+	// Rewrite the whole file sorted by hash so re-recording gives stable diffs
+	list := make([]LLMFixture, 0, len(s.fixtures))
+	for _, fixture := range s.fixtures {
+		list = append(list, fixture)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Hash < list[j].Hash })
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %v", err)
+	}
+	file, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to write LLM fixtures: %v", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+func promptHash(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+func promptText(messages []llms.MessageContent) string {
+	var parts []string
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if text, ok := part.(llms.TextContent); ok {
+				parts = append(parts, string(message.Role)+": "+text.Text)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (f *FixtureLLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	prompt := promptText(messages)
+	hash := promptHash(prompt)
+
+	if f.inner == nil {
+		fixture, ok := f.store.lookup(hash)
+		if !ok {
+			return nil, fmt.Errorf("no LLM fixture for prompt hash %s", hash[:12])
+		}
+		return &llms.ContentResponse{
+			Choices: []*llms.ContentChoice{{Content: fixture.Completion}},
+		}, nil
+	}
+
+	response, err := f.inner.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Choices) > 0 {
+		if err := f.store.record(LLMFixture{Hash: hash, Prompt: prompt, Completion: response.Choices[0].Content}); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (f *FixtureLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, f, prompt, options...)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/fake"
+)
+
+var updateFixtures = flag.Bool("update", false, "re-record testdata/llm_fixtures.json from the completions in the test tables")
+
+const testFixturePath = "testdata/llm_fixtures.json"
+
+var (
+	recordStoreOnce sync.Once
+	recordStore     *FixtureStore
+)
+
+// fixtureModel returns a model that replays testdata fixtures. With -update it
+// records the completions, in order, against whatever prompts the code under
+// test sends, so prompt changes only need a re-run with -update. No (or an
+// empty) completion means the model has nothing to say.
+func fixtureModel(t *testing.T, completions ...string) llms.Model {
+	t.Helper()
+
+	if *updateFixtures {
+		recordStoreOnce.Do(func() {
+			os.Remove(testFixturePath)
+			recordStore, _ = openFixtureStore(testFixturePath, true)
+		})
+		var scripted []string
+		for _, completion := range completions {
+			if completion != "" {
+				scripted = append(scripted, completion)
+			}
+		}
+		if len(scripted) == 0 {
+			return &FixtureLLM{store: recordStore}
+		}
+		return &FixtureLLM{store: recordStore, inner: fake.NewFakeLLM(scripted)}
+	}
+
+	store, err := openFixtureStore(testFixturePath, false)
+	if err != nil {
+		t.Fatalf("failed to open fixtures (run go test -update to record them): %v", err)
+	}
+	return &FixtureLLM{store: store}
+}
+
+func TestFixtureLLMRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	ctx := context.Background()
+
+	store, err := openFixtureStore(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &FixtureLLM{store: store, inner: fake.NewFakeLLM([]string{"first", "second"})}
+	for _, prompt := range []string{"prompt one", "prompt two"} {
+		if _, err := llms.GenerateFromSinglePrompt(ctx, recorder, prompt); err != nil {
+			t.Fatalf("record %q: %v", prompt, err)
+		}
+	}
+
+	replayStore, err := openFixtureStore(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay := &FixtureLLM{store: replayStore}
+
+	tests := []struct {
+		prompt  string
+		want    string
+		wantErr bool
+	}{
+		{prompt: "prompt two", want: "second"},
+		{prompt: "prompt one", want: "first"},
+		{prompt: "never recorded", wantErr: true},
+	}
+	for _, tc := range tests {
+		got, err := llms.GenerateFromSinglePrompt(ctx, replay, tc.prompt)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("replay %q: expected error, got %q", tc.prompt, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("replay %q: %v", tc.prompt, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("replay %q = %q, want %q", tc.prompt, got, tc.want)
+		}
+	}
+}
@@ -2,6 +2,11 @@ module graph-rag-with-go
 
 go 1.24.6
 
+require (
+	github.com/neo4j/neo4j-go-driver/v5 v5.28.1
+	github.com/tmc/langchaingo v0.1.13
+)
+
 require (
 	cloud.google.com/go v0.114.0 // indirect
 	cloud.google.com/go/ai v0.7.0 // indirect
@@ -23,9 +28,7 @@ require (
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/googleapis/enterprise-certificate-proxy v0.3.2 // indirect
 	github.com/googleapis/gax-go/v2 v2.12.4 // indirect
-	github.com/neo4j/neo4j-go-driver/v5 v5.28.1 // indirect
 	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
-	github.com/tmc/langchaingo v0.1.13 // indirect
 	github.com/tmc/langgraphgo v0.0.0-20240324234251-3b0caeaffd16 // indirect
 	go.opencensus.io v0.24.0 // indirect
 	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.51.0 // indirect
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	graphRecordLimit   = 1000
+	subgraphNodeLimit  = 150
+	neighborPageLimit  = 25
+	subgraphSeedsLimit = 500
+)
+
+type GraphNode struct {
+	ID     string `json:"id"`
+	Label  string `json:"label"`
+	Degree int64  `json:"degree"`
+}
+
+type GraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// Subgraph is what the visualization panel draws. Truncated is set when
+// nodes or edges were left out to keep hubs readable.
+type Subgraph struct {
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []GraphEdge `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// queryGraphIDs collects every string a query touched: parameter values,
+// result rows and, for agent runs, tool arguments and results. Strings that
+// are not node ids simply match nothing.
+func queryGraphIDs(response QueryResponse) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			if v != "" && !seen[v] && len(ids) < subgraphSeedsLimit {
+				seen[v] = true
+				ids = append(ids, v)
+			}
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+
+	for _, value := range response.Params {
+		collect(value)
+	}
+	if rows, ok := parseResultRows(response.Results); ok && response.Mode != queryModeAgent {
+		for _, row := range rows {
+			collect(row)
+		}
+	}
+	for _, call := range response.Trace {
+		for _, value := range call.Args {
+			collect(value)
+		}
+		var result interface{}
+		if json.Unmarshal([]byte(call.Result), &result) == nil {
+			collect(result)
+		}
+	}
+	return ids
+}
+
+// querySubgraph returns the nodes with the given ids and the relationships
+// between them.
+func querySubgraph(query GraphRecords, ids []string) (Subgraph, error) {
+	subgraph := Subgraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	if len(ids) == 0 {
+		return subgraph, nil
+	}
+
+	nodes, err := query(`MATCH (n)
+WHERE n.id IN $ids
+RETURN n.id AS id, labels(n)[0] AS label, size([(n)--() | 1]) AS degree
+LIMIT $limit`, map[string]interface{}{"ids": ids, "limit": subgraphNodeLimit + 1})
+	if err != nil {
+		return subgraph, err
+	}
+	if len(nodes) > subgraphNodeLimit {
+		nodes = nodes[:subgraphNodeLimit]
+		subgraph.Truncated = true
+	}
+	var kept []string
+	for _, record := range nodes {
+		node := graphNodeFromRecord(record)
+		subgraph.Nodes = append(subgraph.Nodes, node)
+		kept = append(kept, node.ID)
+	}
+
+	edges, err := query(`MATCH (a)-[r]->(b)
+WHERE a.id IN $ids AND b.id IN $ids
+RETURN a.id AS source, type(r) AS type, b.id AS target
+LIMIT $limit`, map[string]interface{}{"ids": kept, "limit": graphRecordLimit})
+	if err != nil {
+		return subgraph, err
+	}
+	if len(edges) >= graphRecordLimit {
+		subgraph.Truncated = true
+	}
+	for _, record := range edges {
+		subgraph.Edges = append(subgraph.Edges, graphEdgeFromRecord(record))
+	}
+	return subgraph, nil
+}
+
+// queryNeighborhood returns a node and up to limit of its neighbors, for
+// click-to-expand. Truncated means the node has more relationships than were
+// returned.
+func queryNeighborhood(query GraphRecords, id string, limit int) (Subgraph, error) {
+	subgraph := Subgraph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+
+	center, err := query(`MATCH (n {id: $id})
+RETURN n.id AS id, labels(n)[0] AS label, size([(n)--() | 1]) AS degree
+LIMIT 1`, map[string]interface{}{"id": id})
+	if err != nil {
+		return subgraph, err
+	}
+	if len(center) == 0 {
+		return subgraph, fmt.Errorf("no node with id %q", id)
+	}
+	node := graphNodeFromRecord(center[0])
+	subgraph.Nodes = append(subgraph.Nodes, node)
+	subgraph.Truncated = node.Degree > int64(limit)
+
+	neighbors, err := query(`MATCH (n {id: $id})-[r]-(m)
+RETURN m.id AS id, labels(m)[0] AS label, size([(m)--() | 1]) AS degree, type(r) AS type, startNode(r) = n AS outgoing
+LIMIT $limit`, map[string]interface{}{"id": id, "limit": limit})
+	if err != nil {
+		return subgraph, err
+	}
+	seen := map[string]bool{node.ID: true}
+	for _, record := range neighbors {
+		neighbor := graphNodeFromRecord(record)
+		if !seen[neighbor.ID] {
+			seen[neighbor.ID] = true
+			subgraph.Nodes = append(subgraph.Nodes, neighbor)
+		}
+		edge := GraphEdge{Source: node.ID, Target: neighbor.ID}
+		if outgoing, _ := record["outgoing"].(bool); !outgoing {
+			edge.Source, edge.Target = neighbor.ID, node.ID
+		}
+		edge.Type, _ = record["type"].(string)
+		subgraph.Edges = append(subgraph.Edges, edge)
+	}
+	return subgraph, nil
+}
+
+func graphNodeFromRecord(record map[string]interface{}) GraphNode {
+	node := GraphNode{}
+	node.ID, _ = record["id"].(string)
+	node.Label, _ = record["label"].(string)
+	node.Degree, _ = record["degree"].(int64)
+	return node
+}
+
+func graphEdgeFromRecord(record map[string]interface{}) GraphEdge {
+	edge := GraphEdge{}
+	edge.Source, _ = record["source"].(string)
+	edge.Target, _ = record["target"].(string)
+	edge.Type, _ = record["type"].(string)
+	return edge
+}
+
+// handleQueryGraph returns the subgraph behind a past query (?id=) or the
+// neighborhood of one node (?node=&limit=&namespace=).
+func handleQueryGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var subgraph Subgraph
+	var err error
+	if node := r.URL.Query().Get("node"); node != "" {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit < 1 || limit > subgraphNodeLimit {
+			limit = neighborPageLimit
+		}
+		namespace := r.URL.Query().Get("namespace")
+		if err := validateNamespace(namespace); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subgraph, err = queryNeighborhood(fetchGraphRecords(namespace), node, limit)
+	} else {
+		if historyStore == nil {
+			http.Error(w, "Query history is not available", http.StatusServiceUnavailable)
+			return
+		}
+		entry, ok := historyStore.Get(r.URL.Query().Get("id"))
+		if !ok {
+			http.Error(w, "Unknown query id", http.StatusNotFound)
+			return
+		}
+		subgraph, err = querySubgraph(fetchGraphRecords(entry.Namespace), queryGraphIDs(entry.QueryResponse))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subgraph)
+}
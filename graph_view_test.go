@@ -0,0 +1,129 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryGraphIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		response QueryResponse
+		want     []string
+	}{
+		{
+			name: "params and result rows",
+			response: QueryResponse{
+				Params:  map[string]interface{}{"name": "Spider-Man", "limit": int64(10)},
+				Results: "Black Cat\nSilver Sable\nBlack Cat",
+			},
+			want: []string{"Spider-Man", "Black Cat", "Silver Sable"},
+		},
+		{
+			name:     "query error",
+			response: QueryResponse{Results: queryErrorPrefix + ": syntax error"},
+		},
+		{
+			name: "agent trace",
+			response: QueryResponse{
+				Mode:    queryModeAgent,
+				Results: "1. find_entity → ...",
+				Trace: []ToolCall{
+					{Tool: "find_entity", Args: map[string]interface{}{"name": "spider"}, Result: `[{"id":"Spider-Man","labels":["Character"]}]`},
+					{Tool: "shortest_path", Args: map[string]interface{}{"from": "Spider-Man", "to": "Hulk"}, Result: `[{"nodes":["Spider-Man","Thor","Hulk"]}]`},
+				},
+			},
+			want: []string{"spider", "Spider-Man", "Character", "Hulk", "Thor"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := queryGraphIDs(tc.response)
+			if len(tc.want) == 0 && len(got) == 0 {
+				return
+			}
+			if !sameElements(got, tc.want) {
+				t.Errorf("ids = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// sameElements compares string slices ignoring order, since map iteration
+// decides the order ids are collected in.
+func sameElements(got, want []string) bool {
+	count := make(map[string]int)
+	for _, value := range got {
+		count[value]++
+	}
+	for _, value := range want {
+		count[value]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQuerySubgraph(t *testing.T) {
+	nodes := make([]map[string]interface{}, subgraphNodeLimit+1)
+	for i := range nodes {
+		nodes[i] = map[string]interface{}{"id": string(rune('A' + i%26)), "label": "Character", "degree": int64(i)}
+	}
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"WHERE n.id IN $ids": nodes,
+		"MATCH (a)-[r]->(b)": {{"source": "A", "type": "PARTNERS_WITH", "target": "B"}},
+	}}
+
+	subgraph, err := querySubgraph(graph.query, []string{"A", "B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subgraph.Nodes) != subgraphNodeLimit || !subgraph.Truncated {
+		t.Errorf("got %d nodes (truncated %v), want %d and truncated", len(subgraph.Nodes), subgraph.Truncated, subgraphNodeLimit)
+	}
+	if want := (GraphNode{ID: "B", Label: "Character", Degree: 1}); subgraph.Nodes[1] != want {
+		t.Errorf("node = %+v, want %+v", subgraph.Nodes[1], want)
+	}
+	if want := []GraphEdge{{Source: "A", Target: "B", Type: "PARTNERS_WITH"}}; !reflect.DeepEqual(subgraph.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", subgraph.Edges, want)
+	}
+
+	empty, err := querySubgraph(graph.query, nil)
+	if err != nil || len(empty.Nodes) != 0 || len(graph.queries) != 2 {
+		t.Errorf("no ids should give an empty graph without querying, got %+v after %d queries", empty, len(graph.queries))
+	}
+}
+
+func TestQueryNeighborhood(t *testing.T) {
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"LIMIT 1": {{"id": "Spider-Man", "label": "Character", "degree": int64(3)}},
+		"-[r]-(m)": {
+			{"id": "Black Cat", "label": "Character", "degree": int64(1), "type": "PARTNERS_WITH", "outgoing": true},
+			{"id": "Silver Sable", "label": "Character", "degree": int64(2), "type": "PARTNERS_WITH", "outgoing": false},
+		},
+	}}
+
+	subgraph, err := queryNeighborhood(graph.query, "Spider-Man", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subgraph.Nodes) != 3 || !subgraph.Truncated {
+		t.Errorf("got %d nodes (truncated %v), want 3 and truncated", len(subgraph.Nodes), subgraph.Truncated)
+	}
+	want := []GraphEdge{
+		{Source: "Spider-Man", Target: "Black Cat", Type: "PARTNERS_WITH"},
+		{Source: "Silver Sable", Target: "Spider-Man", Type: "PARTNERS_WITH"},
+	}
+	if !reflect.DeepEqual(subgraph.Edges, want) {
+		t.Errorf("edges = %+v, want %+v", subgraph.Edges, want)
+	}
+
+	missing := &stubGraph{}
+	if _, err := queryNeighborhood(missing.query, "Nobody", 2); err == nil {
+		t.Error("expected an error for an unknown node")
+	}
+}
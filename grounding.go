@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SentenceGrounding ties one answer sentence to the result rows it relies on
+// (1-based) and lists any names or numbers the results do not support.
+type SentenceGrounding struct {
+	Text        string   `json:"text"`
+	Rows        []int    `json:"rows,omitempty"`
+	Unsupported []string `json:"unsupported,omitempty"`
+}
+
+// Grounding is the post-generation check of an answer against the graph
+// results. Score is the share of sentences with no unsupported claims.
+type Grounding struct {
+	Score     float64             `json:"score"`
+	Sentences []SentenceGrounding `json:"sentences"`
+}
+
+var (
+	citationPattern = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+	entityPattern   = regexp.MustCompile(`\p{Lu}[\pL\pN'’\-/.]*(?:\s+\p{Lu}[\pL\pN'’\-/.]*)*`)
+	numberPattern   = regexp.MustCompile(`\pN+(?:[.,]\pN+)*`)
+	sentencePattern = regexp.MustCompile(`[^.!?\n]+(?:[.!?]+|$)`)
+)
+
+// groundingStopwords are capitalized words that start sentences or name the
+// domain rather than making a claim about the graph.
+var groundingStopwords = map[string]bool{
+	"a": true, "according": true, "all": true, "also": true, "an": true, "and": true,
+	"as": true, "at": true, "based": true, "both": true, "but": true, "by": true,
+	"comics": true, "cypher": true, "dr": true, "each": true, "for": true, "from": true,
+	"graph": true, "he": true, "her": true, "here": true, "his": true, "however": true,
+	"i": true, "if": true, "in": true, "it": true, "its": true, "knowledge": true,
+	"marvel": true, "mr": true, "mrs": true, "ms": true, "neo4j": true, "no": true,
+	"not": true, "of": true, "on": true, "only": true, "or": true, "she": true,
+	"so": true, "some": true, "sorry": true, "that": true, "the": true, "their": true,
+	"there": true, "these": true, "they": true, "this": true, "those": true, "to": true,
+	"try": true, "unfortunately": true, "universe": true, "we": true, "what": true,
+	"when": true, "which": true, "who": true, "with": true, "yes": true, "you": true,
+	"your": true,
+}
+
+// numberRows prefixes each result row with its citation marker, [1] first.
+func numberRows(results string) string {
+	rows, ok := parseResultRows(results)
+	if !ok || len(rows) == 0 {
+		return results
+	}
+	numbered := make([]string, len(rows))
+	for i, row := range rows {
+		numbered[i] = fmt.Sprintf("[%d] %s", i+1, row)
+	}
+	return strings.Join(numbered, "\n")
+}
+
+// checkGrounding splits the answer into sentences, resolves the [n] citations
+// in each and flags entity names and numbers that appear in neither the
+// results nor the question. Rows a sentence mentions verbatim count as cited
+// even without a marker.
+func checkGrounding(answer, results, question string) *Grounding {
+	rows, _ := parseResultRows(results)
+	evidence := results + "\n" + question
+
+	grounding := &Grounding{Score: 1}
+	grounded := 0
+	for _, text := range sentencePattern.FindAllString(answer, -1) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		sentence := SentenceGrounding{Text: text}
+		cited := make(map[int]bool)
+
+		for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+			for _, marker := range strings.Split(match[1], ",") {
+				n, _ := strconv.Atoi(strings.TrimSpace(marker))
+				if n < 1 || n > len(rows) {
+					sentence.Unsupported = append(sentence.Unsupported, fmt.Sprintf("[%d]", n))
+					continue
+				}
+				cited[n] = true
+			}
+		}
+		claims := citationPattern.ReplaceAllString(text, " ")
+		for i, row := range rows {
+			if strings.TrimSpace(row) != "" && mentionsValue(claims, row) {
+				cited[i+1] = true
+			}
+		}
+		for i := range rows {
+			if cited[i+1] {
+				sentence.Rows = append(sentence.Rows, i+1)
+			}
+		}
+
+		for _, entity := range entityPattern.FindAllString(claims, -1) {
+			entity = trimClaim(entity)
+			if entity == "" || mentionsValue(evidence, entity) {
+				continue
+			}
+			// A multi-word phrase is fine when each word is supported on its own
+			for _, word := range strings.Fields(entity) {
+				word = trimClaim(word)
+				if word != "" && !groundingStopwords[strings.ToLower(word)] && !mentionsValue(evidence, word) {
+					sentence.Unsupported = append(sentence.Unsupported, word)
+				}
+			}
+		}
+		for _, number := range numberPattern.FindAllString(entityPattern.ReplaceAllString(claims, " "), -1) {
+			if !mentionsValue(evidence, number) {
+				sentence.Unsupported = append(sentence.Unsupported, number)
+			}
+		}
+
+		if len(sentence.Unsupported) == 0 {
+			grounded++
+		}
+		grounding.Sentences = append(grounding.Sentences, sentence)
+	}
+
+	if len(grounding.Sentences) > 0 {
+		grounding.Score = float64(grounded) / float64(len(grounding.Sentences))
+	}
+	return grounding
+}
+
+// trimClaim drops trailing punctuation and a possessive 's.
+func trimClaim(term string) string {
+	term = strings.TrimRight(term, ".'’-/")
+	term = strings.TrimSuffix(strings.TrimSuffix(term, "'s"), "’s")
+	return term
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumberRows(t *testing.T) {
+	tests := []struct {
+		results string
+		want    string
+	}{
+		{"Hulk\nIron Man", "[1] Hulk\n[2] Iron Man"},
+		{noResultsMessage, noResultsMessage},
+		{queryErrorPrefix + ": syntax error", queryErrorPrefix + ": syntax error"},
+	}
+	for _, tc := range tests {
+		if got := numberRows(tc.results); got != tc.want {
+			t.Errorf("numberRows(%q) = %q, want %q", tc.results, got, tc.want)
+		}
+	}
+}
+
+func TestCheckGrounding(t *testing.T) {
+	tests := []struct {
+		name      string
+		answer    string
+		results   string
+		question  string
+		wantScore float64
+		want      []SentenceGrounding
+	}{
+		{
+			name:      "cited and grounded",
+			answer:    "Thor has partnered with Hulk [1] and Iron Man [2].",
+			results:   "Hulk\nIron Man",
+			question:  "Who are Thor's partners?",
+			wantScore: 1,
+			want: []SentenceGrounding{
+				{Text: "Thor has partnered with Hulk [1] and Iron Man [2].", Rows: []int{1, 2}},
+			},
+		},
+		{
+			name:      "rows mentioned without markers still count as cited",
+			answer:    "Here are the results. The Hulk is one of them!",
+			results:   "Hulk\nIron Man",
+			question:  "Who are Thor's partners?",
+			wantScore: 1,
+			want: []SentenceGrounding{
+				{Text: "Here are the results."},
+				{Text: "The Hulk is one of them!", Rows: []int{1}},
+			},
+		},
+		{
+			name:      "invented entities, numbers and citations are flagged",
+			answer:    "Spider-Man's partner is Black Cat [1]. They first met in 1979 alongside Silver Sable [4].",
+			results:   "Black Cat",
+			question:  "Who are Spider-Man's partners?",
+			wantScore: 0.5,
+			want: []SentenceGrounding{
+				{Text: "Spider-Man's partner is Black Cat [1].", Rows: []int{1}},
+				{Text: "They first met in 1979 alongside Silver Sable [4].", Unsupported: []string{"[4]", "Silver", "Sable", "1979"}},
+			},
+		},
+		{
+			name:      "case and hero aliases match the results",
+			answer:    "Peter Parker appears in 1,200 comics.",
+			results:   "SPIDER-MAN/PETER PARKER: 1,200",
+			question:  "How many comics does Spider-Man appear in?",
+			wantScore: 1,
+			want: []SentenceGrounding{
+				{Text: "Peter Parker appears in 1,200 comics."},
+			},
+		},
+		{
+			name:      "empty answer",
+			answer:    "",
+			results:   "Hulk",
+			wantScore: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := checkGrounding(tc.answer, tc.results, tc.question)
+			if got.Score != tc.wantScore {
+				t.Errorf("score = %v, want %v", got.Score, tc.wantScore)
+			}
+			if !reflect.DeepEqual(got.Sentences, tc.want) {
+				t.Errorf("sentences = %+v, want %+v", got.Sentences, tc.want)
+			}
+		})
+	}
+}
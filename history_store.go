@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const historyFilePath = "data/history.jsonl"
+
+type Feedback struct {
+	Rating          string                 `json:"rating"`
+	CorrectedCypher string                 `json:"corrected_cypher,omitempty"`
+	CorrectedParams map[string]interface{} `json:"corrected_params,omitempty"`
+	Comment         string                 `json:"comment,omitempty"`
+	Timestamp       string                 `json:"timestamp"`
+}
+
+type HistoryEntry struct {
+	QueryResponse
+	Feedback *Feedback `json:"feedback,omitempty"`
+}
+
+// historyRecord is one line of the append-only log. Query records carry a
+// full entry; feedback records point back at an entry by id.
+type historyRecord struct {
+	Type     string        `json:"type"`
+	Entry    *HistoryEntry `json:"entry,omitempty"`
+	ID       string        `json:"id,omitempty"`
+	Feedback *Feedback     `json:"feedback,omitempty"`
+}
+
+type HistoryStore struct {
+	mu      sync.Mutex
+	file    *os.File
+	entries []*HistoryEntry
+	index   map[string]*HistoryEntry
+}
+
+func openHistoryStore(path string) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	store := &HistoryStore{index: make(map[string]*HistoryEntry)}
+
+	// Replay the existing log to rebuild the in-memory index
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var record historyRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue
+			}
+			store.apply(record)
+		}
+		existing.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read history log: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history log: %v", err)
+	}
+	store.file = file
+	return store, nil
+}
+
+func (s *HistoryStore) apply(record historyRecord) {
+	switch record.Type {
+	case "query":
+		if record.Entry == nil || record.Entry.ID == "" {
+			return
+		}
+		if existing, ok := s.index[record.Entry.ID]; ok {
+			*existing = *record.Entry
+			return
+		}
+		s.entries = append(s.entries, record.Entry)
+		s.index[record.Entry.ID] = record.Entry
+	case "feedback":
+		if entry, ok := s.index[record.ID]; ok {
+			entry.Feedback = record.Feedback
+		}
+	}
+}
+
+func (s *HistoryStore) write(record historyRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to history log: %v", err)
+	}
+	return nil
+}
+
+func (s *HistoryStore) Append(response QueryResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record := historyRecord{Type: "query", Entry: &HistoryEntry{QueryResponse: response}}
+	if err := s.write(record); err != nil {
+		return err
+	}
+	s.apply(record)
+	return nil
+}
+
+// SetFeedback records feedback on an entry. The bool is false when no entry
+// has this id.
+func (s *HistoryStore) SetFeedback(id string, feedback Feedback) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return false, nil
+	}
+	record := historyRecord{Type: "feedback", ID: id, Feedback: &feedback}
+	if err := s.write(record); err != nil {
+		return true, err
+	}
+	s.apply(record)
+	return true, nil
+}
+
+func (s *HistoryStore) Get(id string) (HistoryEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.index[id]
+	if !ok {
+		return HistoryEntry{}, false
+	}
+	return *entry, true
+}
+
+// Search returns one page of entries, newest first, whose question, Cypher
+// or answer contain the search text. rating filters on feedback ("up",
+// "down", or "none" for entries without feedback).
+func (s *HistoryStore) Search(text, rating string, page, pageSize int) ([]HistoryEntry, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	text = strings.ToLower(strings.TrimSpace(text))
+	var matches []HistoryEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		entry := s.entries[i]
+		if text != "" &&
+			!strings.Contains(strings.ToLower(entry.Query), text) &&
+			!strings.Contains(strings.ToLower(entry.Cypher), text) &&
+			!strings.Contains(strings.ToLower(entry.Response), text) {
+			continue
+		}
+		switch rating {
+		case "":
+		case "none":
+			if entry.Feedback != nil {
+				continue
+			}
+		default:
+			if entry.Feedback == nil || entry.Feedback.Rating != rating {
+				continue
+			}
+		}
+		matches = append(matches, *entry)
+	}
+
+	total := len(matches)
+	start := (page - 1) * pageSize
+	if start >= total {
+		return []HistoryEntry{}, total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	return matches[start:end], total
+}
+
+// All returns every entry in insertion order.
+func (s *HistoryStore) All() []HistoryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]HistoryEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+func (s *HistoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func newHistoryID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return getCurrentTimestamp()
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testHistoryStore(t *testing.T, path string) *HistoryStore {
+	t.Helper()
+	store, err := openHistoryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHistoryStoreSearch(t *testing.T) {
+	store := testHistoryStore(t, filepath.Join(t.TempDir(), "history.jsonl"))
+	entries := []QueryResponse{
+		{ID: "1", Query: "Who partners with Thor?", Cypher: "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]-(p) RETURN p.id AS result LIMIT 10", Response: "Thor partners with Hulk."},
+		{ID: "2", Query: "Which team did Wolverine join?", Cypher: "MATCH (c:Character)-[:MEMBER_OF]->(t:Team) RETURN t.id AS result LIMIT 10", Response: "X-Men."},
+		{ID: "3", Query: "How many comics feature Hulk?", Cypher: "MATCH (h:Hero)-[:APPEARS_IN]->(c:Comic) RETURN toString(count(c)) AS result", Response: "Hulk appears in 12 comics."},
+		{ID: "4", Query: "Who knows Spider-Man?", Cypher: "MATCH (h:Hero)-[:KNOWS]-(o:Hero) RETURN o.id AS result LIMIT 10", Response: "Many heroes."},
+	}
+	for _, entry := range entries {
+		if err := store.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.SetFeedback("1", Feedback{Rating: "up"})
+	store.SetFeedback("3", Feedback{Rating: "down"})
+
+	tests := []struct {
+		name      string
+		text      string
+		rating    string
+		page      int
+		pageSize  int
+		wantIDs   []string
+		wantTotal int
+	}{
+		{name: "all, newest first", page: 1, pageSize: 10, wantIDs: []string{"4", "3", "2", "1"}, wantTotal: 4},
+		{name: "question", text: "wolverine", page: 1, pageSize: 10, wantIDs: []string{"2"}, wantTotal: 1},
+		{name: "cypher", text: "APPEARS_IN", page: 1, pageSize: 10, wantIDs: []string{"3"}, wantTotal: 1},
+		{name: "answer", text: "  hulk ", page: 1, pageSize: 10, wantIDs: []string{"3", "1"}, wantTotal: 2},
+		{name: "rated up", rating: "up", page: 1, pageSize: 10, wantIDs: []string{"1"}, wantTotal: 1},
+		{name: "rated down", rating: "down", page: 1, pageSize: 10, wantIDs: []string{"3"}, wantTotal: 1},
+		{name: "unrated", rating: "none", page: 1, pageSize: 10, wantIDs: []string{"4", "2"}, wantTotal: 2},
+		{name: "text and rating", text: "hulk", rating: "up", page: 1, pageSize: 10, wantIDs: []string{"1"}, wantTotal: 1},
+		{name: "first page", page: 1, pageSize: 3, wantIDs: []string{"4", "3", "2"}, wantTotal: 4},
+		{name: "last page", page: 2, pageSize: 3, wantIDs: []string{"1"}, wantTotal: 4},
+		{name: "past the end", page: 3, pageSize: 3, wantIDs: []string{}, wantTotal: 4},
+		{name: "no match", text: "galactus", page: 1, pageSize: 10, wantIDs: []string{}, wantTotal: 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			page, total := store.Search(tc.text, tc.rating, tc.page, tc.pageSize)
+			ids := []string{}
+			for _, entry := range page {
+				ids = append(ids, entry.ID)
+			}
+			if !reflect.DeepEqual(ids, tc.wantIDs) || total != tc.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", ids, total, tc.wantIDs, tc.wantTotal)
+			}
+		})
+	}
+}
+
+func TestHistoryStoreSetFeedback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := testHistoryStore(t, path)
+	if err := store.Append(QueryResponse{ID: "a", Query: "Who is Thor?"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, err := store.SetFeedback("missing", Feedback{Rating: "up"}); found || err != nil {
+		t.Errorf("unknown id: found = %v, err = %v", found, err)
+	}
+	if found, err := store.SetFeedback("a", Feedback{Rating: "up"}); !found || err != nil {
+		t.Fatalf("found = %v, err = %v", found, err)
+	}
+	// Later feedback replaces earlier feedback
+	correction := Feedback{Rating: "down", CorrectedCypher: "MATCH (c:Character {id: $name}) RETURN c.id AS result",
+		CorrectedParams: map[string]interface{}{"name": "Thor"}, Comment: "match by id"}
+	if _, err := store.SetFeedback("a", correction); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := store.Get("a"); !reflect.DeepEqual(entry.Feedback, &correction) {
+		t.Errorf("feedback = %+v, want %+v", entry.Feedback, correction)
+	}
+
+	// The log replays to the same state
+	store.Close()
+	reopened := testHistoryStore(t, path)
+	entries := reopened.All()
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Feedback, &correction) {
+		t.Errorf("replayed entries = %+v", entries)
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Import formats, chosen by the manifest or the file extension
+const (
+	importCSV      = "csv"
+	importGraphML  = "graphml"
+	importJSONL    = "jsonl"
+	importEdgeList = "edgelist"
+	importTurtle   = "turtle"
+)
+
+// Used when a file does not say what its nodes and relationships are
+const (
+	defaultNodeLabel        = "Character"
+	defaultRelationshipType = "RELATED_TO"
+)
+
+// Row is the record's line in its file (CSV rows count the header as line
+// 1; GraphML uses the element's start line, RDF leaves it 0). File is filled
+// in by the loader, not by importers.
+type ImportNode struct {
+	Label string
+	// Labels are further labels the node carries, such as Placeholder
+	Labels     []string
+	ID         string
+	Properties map[string]interface{}
+	File       string
+	Row        int
+}
+
+type ImportEdge struct {
+	Type        string
+	SourceLabel string
+	Source      string
+	TargetLabel string
+	Target      string
+	Properties  map[string]interface{}
+	File        string
+	Row         int
+}
+
+// importSink receives everything an importer reads from a file.
+type importSink interface {
+	Node(node ImportNode) error
+	Edge(edge ImportEdge) error
+}
+
+// rowSink stamps the current row on everything passed through it, for
+// importers that build records away from the loop that reads them.
+type rowSink struct {
+	importSink
+	row int
+}
+
+func (s *rowSink) Node(node ImportNode) error {
+	node.Row = s.row
+	return s.importSink.Node(node)
+}
+
+func (s *rowSink) Edge(edge ImportEdge) error {
+	edge.Row = s.row
+	return s.importSink.Edge(edge)
+}
+
+// temporalSink converts temporal properties (see temporalProperties) for
+// formats whose values carry no type of their own.
+type temporalSink struct {
+	importSink
+}
+
+func (s temporalSink) Node(node ImportNode) error {
+	node.Properties = temporalProperties(node.Properties)
+	return s.importSink.Node(node)
+}
+
+func (s temporalSink) Edge(edge ImportEdge) error {
+	edge.Properties = temporalProperties(edge.Properties)
+	return s.importSink.Edge(edge)
+}
+
+// graphImporter reads one file in its format and hands nodes and edges to
+// the sink in file order.
+type graphImporter func(r io.Reader, spec DatasetSpec, sink importSink) error
+
+var graphImporters = map[string]graphImporter{
+	importCSV:      importMarvelCSV,
+	importGraphML:  importGraphMLFile,
+	importJSONL:    importJSONLines,
+	importEdgeList: importEdgeListFile,
+	importTurtle:   importRDF,
+}
+
+var importExtensions = map[string]string{
+	".csv":      importCSV,
+	".graphml":  importGraphML,
+	".jsonl":    importJSONL,
+	".ndjson":   importJSONL,
+	".edgelist": importEdgeList,
+	".edges":    importEdgeList,
+	".ttl":      importTurtle,
+	".nt":       importTurtle,
+}
+
+// importFormat is the manifest's format for a file, or the one its
+// extension implies ("" when neither says).
+func importFormat(spec DatasetSpec) string {
+	if spec.Format != "" {
+		return spec.Format
+	}
+	return importExtensions[strings.ToLower(filepath.Ext(spec.Path))]
+}
+
+func (spec DatasetSpec) nodeLabel() string {
+	if spec.NodeLabel != "" {
+		return spec.NodeLabel
+	}
+	return defaultNodeLabel
+}
+
+func (spec DatasetSpec) relationshipType() string {
+	if spec.RelationshipType != "" {
+		return spec.RelationshipType
+	}
+	return defaultRelationshipType
+}
+
+// importMarvelCSV reads the shipped Marvel datasets, telling nodes from
+// edges by directory and file name. Columns after the ones a file needs are
+// read as properties when they are temporal (see temporalColumns).
+func importMarvelCSV(r io.Reader, spec DatasetSpec, sink importSink) error {
+	fileName := strings.ToLower(filepath.Base(spec.Path))
+	dirName := strings.ToLower(filepath.Base(filepath.Dir(spec.Path)))
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV: %v", err)
+	}
+	if len(records) < 2 {
+		fmt.Printf("⚠️ Skipping empty file: %s\n", filepath.Base(spec.Path))
+		return nil
+	}
+	data := records[1:]
+	rows := &rowSink{importSink: sink}
+	sink = rows
+
+	var emit func(row []string) error
+	columns := 2
+	temporal := func(properties map[string]interface{}, row []string) map[string]interface{} {
+		return temporalColumns(properties, records[0][columns:], row[columns:])
+	}
+	switch {
+	// === Marvel Characters Partnerships ===
+	case strings.Contains(dirName, "marvel_characters_partnerships") && strings.Contains(fileName, "nodes"):
+		columns = 3
+		emit = func(row []string) error {
+			size, _ := strconv.Atoi(row[2])
+			return sink.Node(ImportNode{Label: "Character", ID: row[1], Properties: temporal(map[string]interface{}{
+				"name": row[1], "group": row[0], "size": size,
+			}, row)})
+		}
+	case strings.Contains(dirName, "marvel_characters_partnerships") && strings.Contains(fileName, "edges"):
+		emit = func(row []string) error {
+			return sink.Edge(ImportEdge{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: row[0], TargetLabel: "Character", Target: row[1], Properties: temporal(nil, row)})
+		}
+
+	// === Marvel Universe Social Network ===
+	case strings.Contains(dirName, "marvel_universe_social_network") && strings.Contains(fileName, "nodes"):
+		emit = func(row []string) error {
+			switch row[1] {
+			case "hero":
+				return sink.Node(ImportNode{Label: "Hero", ID: row[0], Properties: temporal(map[string]interface{}{"name": row[0]}, row)})
+			case "comic":
+				return sink.Node(ImportNode{Label: "Comic", ID: row[0], Properties: temporal(map[string]interface{}{"title": row[0]}, row)})
+			}
+			return nil
+		}
+	case strings.Contains(dirName, "marvel_universe_social_network") && strings.Contains(fileName, "hero-network"):
+		emit = func(row []string) error {
+			return sink.Edge(ImportEdge{Type: "KNOWS", SourceLabel: "Hero", Source: row[0], TargetLabel: "Hero", Target: row[1], Properties: temporal(nil, row)})
+		}
+	case strings.Contains(dirName, "marvel_universe_social_network") && strings.Contains(fileName, "edges"):
+		emit = func(row []string) error {
+			return sink.Edge(ImportEdge{Type: "APPEARS_IN", SourceLabel: "Hero", Source: row[0], TargetLabel: "Comic", Target: row[1], Properties: temporal(nil, row)})
+		}
+
+	// === Marvel Teams ===
+	case strings.Contains(dirName, "marvel_teams") && strings.Contains(fileName, "teams"):
+		columns = 1
+		emit = func(row []string) error {
+			return sink.Node(ImportNode{Label: "Team", ID: row[0], Properties: temporal(map[string]interface{}{"name": row[0]}, row)})
+		}
+	case strings.Contains(dirName, "marvel_teams") && strings.Contains(fileName, "memberships"):
+		emit = func(row []string) error {
+			return sink.Edge(ImportEdge{Type: "MEMBER_OF", SourceLabel: "Character", Source: row[0], TargetLabel: "Team", Target: row[1], Properties: temporal(nil, row)})
+		}
+
+	// === Marvel Movies ===
+	case strings.Contains(dirName, "marvel_movies") && strings.Contains(fileName, "movies"):
+		columns = 1
+		emit = func(row []string) error {
+			return sink.Node(ImportNode{Label: "Movie", ID: row[0], Properties: temporal(map[string]interface{}{"title": row[0]}, row)})
+		}
+	case strings.Contains(dirName, "marvel_movies") && strings.Contains(fileName, "appearances"):
+		emit = func(row []string) error {
+			return sink.Edge(ImportEdge{Type: "APPEARS_IN_MOVIE", SourceLabel: "Character", Source: row[0], TargetLabel: "Movie", Target: row[1], Properties: temporal(nil, row)})
+		}
+	default:
+		fmt.Printf("⚠️ Skipping unrecognized dataset: %s\n", filepath.Base(spec.Path))
+		return nil
+	}
+
+	for i, row := range data {
+		if len(row) < columns {
+			continue
+		}
+		rows.row = i + 2
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importGraphMLFile reads GraphML as written by Gephi, yEd or our own
+// exports. A node's "label" data becomes its Neo4j label when it is a valid
+// identifier and the manifest does not set one; an edge's "type" or "label"
+// data becomes its relationship type. Other data become properties, typed
+// by their key's attr.type.
+func importGraphMLFile(r io.Reader, spec DatasetSpec, sink importSink) error {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type element struct {
+		ID     string `xml:"id,attr"`
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+		Data   []data `xml:"data"`
+	}
+	type key struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+
+	keys := make(map[string]key)
+	// GraphML edges refer to node ids; remember what each became
+	nodes := make(map[string]ImportNode)
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid GraphML: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		line, _ := decoder.InputPos()
+
+		switch start.Name.Local {
+		case "key":
+			var k key
+			if err := decoder.DecodeElement(&k, &start); err != nil {
+				return fmt.Errorf("invalid GraphML key: %v", err)
+			}
+			if k.Name == "" {
+				k.Name = k.ID
+			}
+			keys[k.ID] = k
+
+		case "node":
+			var e element
+			if err := decoder.DecodeElement(&e, &start); err != nil {
+				return fmt.Errorf("invalid GraphML node: %v", err)
+			}
+			node := ImportNode{Label: spec.NodeLabel, ID: e.ID, Properties: map[string]interface{}{}, Row: line}
+			for _, d := range e.Data {
+				k := keys[d.Key]
+				switch {
+				case spec.IDKey != "" && k.Name == spec.IDKey:
+					node.ID = d.Value
+				case k.Name == "label" && spec.NodeLabel == "" && labelPattern.MatchString(d.Value):
+					node.Label = d.Value
+				default:
+					node.Properties[k.Name] = graphMLValue(d.Value, k.Type)
+				}
+			}
+			if node.Label == "" {
+				node.Label = defaultNodeLabel
+			}
+			nodes[e.ID] = node
+			if err := sink.Node(node); err != nil {
+				return err
+			}
+
+		case "edge":
+			var e element
+			if err := decoder.DecodeElement(&e, &start); err != nil {
+				return fmt.Errorf("invalid GraphML edge: %v", err)
+			}
+			source, ok := nodes[e.Source]
+			if !ok {
+				source = ImportNode{Label: spec.nodeLabel(), ID: e.Source}
+			}
+			target, ok := nodes[e.Target]
+			if !ok {
+				target = ImportNode{Label: spec.nodeLabel(), ID: e.Target}
+			}
+			edge := ImportEdge{Type: spec.RelationshipType, SourceLabel: source.Label, Source: source.ID,
+				TargetLabel: target.Label, Target: target.ID, Properties: map[string]interface{}{}, Row: line}
+			for _, d := range e.Data {
+				k := keys[d.Key]
+				if (k.Name == "type" || k.Name == "label") && spec.RelationshipType == "" && relationshipTypePattern.MatchString(d.Value) {
+					edge.Type = d.Value
+				} else {
+					edge.Properties[k.Name] = graphMLValue(d.Value, k.Type)
+				}
+			}
+			if edge.Type == "" {
+				edge.Type = defaultRelationshipType
+			}
+			if err := sink.Edge(edge); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func graphMLValue(value, attrType string) interface{} {
+	switch attrType {
+	case "int", "long":
+		if i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			return i
+		}
+	case "float", "double":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// jsonlRecord is one line of a JSON Lines import:
+//
+//	{"kind": "node", "label": "Hero", "id": "THOR/DR. DONALD BLAK", "properties": {...}}
+//	{"kind": "edge", "type": "KNOWS", "source": "...", "target": "...", "source_label": "Hero", "target_label": "Hero"}
+type jsonlRecord struct {
+	Kind        string                 `json:"kind"`
+	Label       string                 `json:"label"`
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Source      string                 `json:"source"`
+	Target      string                 `json:"target"`
+	SourceLabel string                 `json:"source_label"`
+	TargetLabel string                 `json:"target_label"`
+	Properties  map[string]interface{} `json:"properties"`
+}
+
+func importJSONLines(r io.Reader, spec DatasetSpec, sink importSink) error {
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	decoder.UseNumber()
+	for line := 1; ; line++ {
+		var record jsonlRecord
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid JSON Lines record %d: %v", line, err)
+		}
+		for name, value := range record.Properties {
+			record.Properties[name] = normalizeParamValue(value)
+		}
+
+		var err error
+		switch record.Kind {
+		case "node":
+			if record.Label == "" {
+				record.Label = spec.nodeLabel()
+			}
+			err = sink.Node(ImportNode{Label: record.Label, ID: record.ID, Properties: record.Properties, Row: line})
+		case "edge", "relationship":
+			if record.Type == "" {
+				record.Type = spec.relationshipType()
+			}
+			if record.SourceLabel == "" {
+				record.SourceLabel = spec.nodeLabel()
+			}
+			if record.TargetLabel == "" {
+				record.TargetLabel = spec.nodeLabel()
+			}
+			err = sink.Edge(ImportEdge{Type: record.Type, SourceLabel: record.SourceLabel, Source: record.Source,
+				TargetLabel: record.TargetLabel, Target: record.Target, Properties: record.Properties, Row: line})
+		default:
+			err = fmt.Errorf("record %d: kind must be node or edge, got %q", line, record.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// importEdgeListFile reads "source target [weight]" lines separated by
+// whitespace, with "quoted ids" for names containing spaces and # or %
+// comment lines. Both ends become nodes with the manifest's label.
+func importEdgeListFile(r io.Reader, spec DatasetSpec, sink importSink) error {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "%") {
+			continue
+		}
+		fields, err := splitEdgeListLine(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: expected source and target, got %q", line, text)
+		}
+
+		edge := ImportEdge{Type: spec.relationshipType(), SourceLabel: spec.nodeLabel(), Source: fields[0],
+			TargetLabel: spec.nodeLabel(), Target: fields[1], Row: line}
+		if len(fields) > 2 {
+			weight, err := strconv.ParseFloat(fields[2], 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid weight %q", line, fields[2])
+			}
+			edge.Properties = map[string]interface{}{"weight": weight}
+		}
+		for _, id := range fields[:2] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if err := sink.Node(ImportNode{Label: spec.nodeLabel(), ID: id, Properties: map[string]interface{}{"name": id}, Row: line}); err != nil {
+				return err
+			}
+		}
+		if err := sink.Edge(edge); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func splitEdgeListLine(line string) ([]string, error) {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote in %q", line)
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields, nil
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// recordingSink keeps everything an importer emits.
+type recordingSink struct {
+	nodes []ImportNode
+	edges []ImportEdge
+}
+
+func (s *recordingSink) Node(node ImportNode) error {
+	s.nodes = append(s.nodes, node)
+	return nil
+}
+
+func (s *recordingSink) Edge(edge ImportEdge) error {
+	s.edges = append(s.edges, edge)
+	return nil
+}
+
+func TestImportMarvelCSV(t *testing.T) {
+	tests := []struct {
+		path      string
+		content   string
+		wantNodes []ImportNode
+		wantEdges []ImportEdge
+	}{
+		{
+			path:    "dataset/marvel_characters_partnerships/nodes.csv",
+			content: "group,id,size\n1,Baron Zemo,2\n1,short\n",
+			wantNodes: []ImportNode{{Label: "Character", ID: "Baron Zemo", Properties: map[string]interface{}{
+				"name": "Baron Zemo", "group": "1", "size": 2,
+			}, Row: 2}},
+		},
+		{
+			path:    "dataset/marvel_universe_social_network/nodes.csv",
+			content: "node,type,publication_date,notes\nAVF 4,comic,1963-05,x\n3-D MAN/CHARLES CHAN,hero\nX,villain\nAA2 35,comic,May 1963\n",
+			wantNodes: []ImportNode{
+				{Label: "Comic", ID: "AVF 4", Properties: map[string]interface{}{"title": "AVF 4", "publication_date": neo4j.DateOf(time.Date(1963, 5, 1, 0, 0, 0, 0, time.UTC))}, Row: 2},
+				{Label: "Hero", ID: "3-D MAN/CHARLES CHAN", Properties: map[string]interface{}{"name": "3-D MAN/CHARLES CHAN"}, Row: 3},
+				{Label: "Comic", ID: "AA2 35", Properties: map[string]interface{}{"title": "AA2 35"}, Row: 5},
+			},
+		},
+		{
+			path:      "dataset/marvel_universe_social_network/edges.csv",
+			content:   "hero,comic\n3-D MAN/CHARLES CHAN,AVF 4\n",
+			wantEdges: []ImportEdge{{Type: "APPEARS_IN", SourceLabel: "Hero", Source: "3-D MAN/CHARLES CHAN", TargetLabel: "Comic", Target: "AVF 4", Row: 2}},
+		},
+		{
+			path:      "dataset/marvel_universe_social_network/hero-network.csv",
+			content:   "hero1,hero2\n\"LITTLE, ABNER\",\"BLACK PANTHER/T'CHAL\"\n",
+			wantEdges: []ImportEdge{{Type: "KNOWS", SourceLabel: "Hero", Source: "LITTLE, ABNER", TargetLabel: "Hero", Target: "BLACK PANTHER/T'CHAL", Row: 2}},
+		},
+		{
+			path:      "dataset/marvel_teams/teams.csv",
+			content:   "team,founded\nAvengers,1963\nDefenders,\n",
+			wantNodes: []ImportNode{{Label: "Team", ID: "Avengers", Properties: map[string]interface{}{"name": "Avengers", "founded": 1963}, Row: 2}, {Label: "Team", ID: "Defenders", Properties: map[string]interface{}{"name": "Defenders"}, Row: 3}},
+		},
+		{
+			path:    "dataset/marvel_teams/memberships.csv",
+			content: "character,team,since\nIron Man,Avengers,1963\nStorm (Marvel Comics),X-Men\n",
+			wantEdges: []ImportEdge{
+				{Type: "MEMBER_OF", SourceLabel: "Character", Source: "Iron Man", TargetLabel: "Team", Target: "Avengers", Properties: map[string]interface{}{"since": 1963}, Row: 2},
+				{Type: "MEMBER_OF", SourceLabel: "Character", Source: "Storm (Marvel Comics)", TargetLabel: "Team", Target: "X-Men", Row: 3},
+			},
+		},
+		{
+			path:      "dataset/marvel_movies/movies.csv",
+			content:   "movie,release_date\nThor (film),2011-05-06\n",
+			wantNodes: []ImportNode{{Label: "Movie", ID: "Thor (film)", Properties: map[string]interface{}{"title": "Thor (film)", "release_date": neo4j.DateOf(time.Date(2011, 5, 6, 0, 0, 0, 0, time.UTC))}, Row: 2}},
+		},
+		{
+			path:      "dataset/marvel_movies/appearances.csv",
+			content:   "character,movie\nLoki (comics),Thor (film)\n",
+			wantEdges: []ImportEdge{{Type: "APPEARS_IN_MOVIE", SourceLabel: "Character", Source: "Loki (comics)", TargetLabel: "Movie", Target: "Thor (film)", Row: 2}},
+		},
+		{path: "dataset/other/people.csv", content: "a,b\n1,2\n"},
+	}
+
+	for _, tc := range tests {
+		sink := &recordingSink{}
+		if err := importMarvelCSV(strings.NewReader(tc.content), DatasetSpec{Path: tc.path}, sink); err != nil {
+			t.Errorf("%s: %v", tc.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(sink.nodes, tc.wantNodes) || !reflect.DeepEqual(sink.edges, tc.wantEdges) {
+			t.Errorf("%s: nodes %+v edges %+v", tc.path, sink.nodes, sink.edges)
+		}
+	}
+}
+
+func TestImportGraphML(t *testing.T) {
+	// Gephi keeps names in "label" and numbers nodes
+	gephi := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key attr.name="label" attr.type="string" for="node" id="label"/>
+  <key attr.name="Weight" attr.type="double" for="edge" id="weight"/>
+  <key attr.name="appearances" attr.type="int" for="node" id="appearances"/>
+  <graph defaultedgetype="undirected">
+    <node id="n0"><data key="label">Spider-Man</data><data key="appearances">3</data></node>
+    <node id="n1"><data key="label">Black Cat</data></node>
+    <edge source="n0" target="n1"><data key="weight">2.5</data></edge>
+  </graph>
+</graphml>`
+	sink := &recordingSink{}
+	spec := DatasetSpec{Path: "gephi.graphml", NodeLabel: "Character", RelationshipType: "PARTNERS_WITH", IDKey: "label"}
+	if err := importGraphMLFile(strings.NewReader(gephi), spec, sink); err != nil {
+		t.Fatal(err)
+	}
+	wantNodes := []ImportNode{
+		{Label: "Character", ID: "Spider-Man", Properties: map[string]interface{}{"appearances": int64(3)}, Row: 7},
+		{Label: "Character", ID: "Black Cat", Properties: map[string]interface{}{}, Row: 8},
+	}
+	wantEdges := []ImportEdge{{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Spider-Man", TargetLabel: "Character", Target: "Black Cat", Properties: map[string]interface{}{"Weight": 2.5}, Row: 9}}
+	if !reflect.DeepEqual(sink.nodes, wantNodes) {
+		t.Errorf("nodes = %+v", sink.nodes)
+	}
+	if !reflect.DeepEqual(sink.edges, wantEdges) {
+		t.Errorf("edges = %+v", sink.edges)
+	}
+
+	// Our own exports carry labels and relationship types
+	subgraph := Subgraph{
+		Nodes: []GraphNode{{ID: "THOR/DR. DONALD BLAK", Label: "Hero", Degree: 1}, {ID: "AVF 4", Label: "Comic", Degree: 1}},
+		Edges: []GraphEdge{{Source: "THOR/DR. DONALD BLAK", Target: "AVF 4", Type: "APPEARS_IN"}},
+	}
+	var sb strings.Builder
+	if err := writeGraphML(&sb, testExportMeta, subgraph); err != nil {
+		t.Fatal(err)
+	}
+	sink = &recordingSink{}
+	if err := importGraphMLFile(strings.NewReader(sb.String()), DatasetSpec{Path: "export.graphml"}, sink); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.nodes) != 2 || sink.nodes[0].Label != "Hero" || sink.nodes[1].Label != "Comic" {
+		t.Errorf("nodes = %+v", sink.nodes)
+	}
+	wantEdge := ImportEdge{Type: "APPEARS_IN", SourceLabel: "Hero", Source: "THOR/DR. DONALD BLAK", TargetLabel: "Comic", Target: "AVF 4", Properties: map[string]interface{}{}}
+	if len(sink.edges) == 1 {
+		wantEdge.Row = sink.edges[0].Row
+	}
+	if len(sink.edges) != 1 || !reflect.DeepEqual(sink.edges[0], wantEdge) || wantEdge.Row == 0 {
+		t.Errorf("edges = %+v", sink.edges)
+	}
+}
+
+func TestImportJSONLines(t *testing.T) {
+	content := `{"kind": "node", "label": "Hero", "id": "HULK/DR. ROBERT BRUC", "properties": {"appearances": 1}}
+{"kind": "node", "id": "Rick Jones"}
+{"kind": "edge", "source": "Rick Jones", "target": "HULK/DR. ROBERT BRUC", "target_label": "Hero", "properties": {"weight": 0.5}}
+`
+	sink := &recordingSink{}
+	if err := importJSONLines(strings.NewReader(content), DatasetSpec{Path: "extra.jsonl", RelationshipType: "KNOWS"}, sink); err != nil {
+		t.Fatal(err)
+	}
+	wantNodes := []ImportNode{
+		{Label: "Hero", ID: "HULK/DR. ROBERT BRUC", Properties: map[string]interface{}{"appearances": int64(1)}, Row: 1},
+		{Label: "Character", ID: "Rick Jones", Row: 2},
+	}
+	wantEdges := []ImportEdge{{Type: "KNOWS", SourceLabel: "Character", Source: "Rick Jones", TargetLabel: "Hero", Target: "HULK/DR. ROBERT BRUC", Properties: map[string]interface{}{"weight": 0.5}, Row: 3}}
+	if !reflect.DeepEqual(sink.nodes, wantNodes) || !reflect.DeepEqual(sink.edges, wantEdges) {
+		t.Errorf("nodes %+v edges %+v", sink.nodes, sink.edges)
+	}
+
+	err := importJSONLines(strings.NewReader(`{"kind": "comic", "id": "AVF 4"}`), DatasetSpec{}, &recordingSink{})
+	if err == nil || !strings.Contains(err.Error(), "kind must be node or edge") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTemporalSink(t *testing.T) {
+	releaseDate := neo4j.DateOf(time.Date(2012, 5, 4, 0, 0, 0, 0, time.UTC))
+	jsonl := `{"kind": "node", "label": "Movie", "id": "The Avengers", "properties": {"release_date": "2012-05-04", "title": "2012-05-04"}}
+{"kind": "edge", "source": "Thor", "target": "Avengers", "target_label": "Team", "properties": {"since": "1963", "until": "soon"}}
+`
+	graphml := `<graphml>
+  <key id="d0" for="node" attr.name="release_date" attr.type="string"/>
+  <key id="d1" for="edge" attr.name="since" attr.type="int"/>
+  <graph edgedefault="directed">
+    <node id="m1"><data key="d0">2012-05</data></node>
+    <node id="m2"/>
+    <edge source="m1" target="m2"><data key="d1">1963</data></edge>
+  </graph>
+</graphml>`
+
+	sink := &recordingSink{}
+	if err := importJSONLines(strings.NewReader(jsonl), DatasetSpec{}, temporalSink{sink}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.nodes[0].Properties; got["release_date"] != releaseDate || got["title"] != "2012-05-04" {
+		t.Errorf("JSON Lines node properties = %#v", got)
+	}
+	if got := sink.edges[0].Properties; got["since"] != 1963 || got["until"] != "soon" {
+		t.Errorf("JSON Lines edge properties = %#v", got)
+	}
+
+	sink = &recordingSink{}
+	if err := importGraphMLFile(strings.NewReader(graphml), DatasetSpec{}, temporalSink{sink}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.nodes[0].Properties["release_date"]; got != neo4j.DateOf(time.Date(2012, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("GraphML release_date = %#v", got)
+	}
+	if got := sink.edges[0].Properties["since"]; got != int64(1963) {
+		t.Errorf("GraphML since = %#v", got)
+	}
+}
+
+func TestImportEdgeList(t *testing.T) {
+	content := `# classic hero network
+"LITTLE, ABNER" "PRINCESS ZANDA"
+"LITTLE, ABNER"	BLACK_PANTHER 2
+% trailing comment
+`
+	sink := &recordingSink{}
+	if err := importEdgeListFile(strings.NewReader(content), DatasetSpec{Path: "hero.edgelist", NodeLabel: "Hero", RelationshipType: "KNOWS"}, sink); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, node := range sink.nodes {
+		ids = append(ids, node.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"LITTLE, ABNER", "PRINCESS ZANDA", "BLACK_PANTHER"}) {
+		t.Errorf("node ids = %q", ids)
+	}
+	if len(sink.edges) != 2 || sink.edges[1].Target != "BLACK_PANTHER" || sink.edges[1].Properties["weight"] != 2.0 || sink.edges[0].Type != "KNOWS" {
+		t.Errorf("edges = %+v", sink.edges)
+	}
+
+	for _, bad := range []string{"lonely\n", "\"unterminated b\n", "a b heavy\n"} {
+		if err := importEdgeListFile(strings.NewReader(bad), DatasetSpec{}, &recordingSink{}); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestGraphWriterBatches(t *testing.T) {
+	var statements []string
+	var batchSizes []int
+	writer := newGraphWriter(func(cypherQuery string, params map[string]interface{}) error {
+		statements = append(statements, cypherQuery)
+		batchSizes = append(batchSizes, len(params["rows"].([]interface{})))
+		return nil
+	})
+
+	writer.phase = importPhaseNodes
+	for i := 0; i < importBatchSize; i++ {
+		writer.Node(ImportNode{Label: "Comic", ID: "C"})
+	}
+	writer.Node(ImportNode{Label: "Hero", ID: "H"})
+	writer.Edge(ImportEdge{Type: "APPEARS_IN", SourceLabel: "Hero", TargetLabel: "Comic"})
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	writer.phase = importPhaseEdges
+	writer.Edge(ImportEdge{Type: "APPEARS_IN", SourceLabel: "Hero", Source: "H", TargetLabel: "Comic", Target: "C"})
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(batchSizes, []int{importBatchSize, 1, 1}) {
+		t.Errorf("batch sizes = %v", batchSizes)
+	}
+	if !strings.Contains(statements[0], "MERGE (n:Comic {id: row.id})") || !strings.Contains(statements[1], "MERGE (n:Hero {id: row.id})") || !strings.Contains(statements[2], "MERGE (a)-[r:APPEARS_IN]->(b)") {
+		t.Errorf("statements = %q", statements)
+	}
+	if writer.nodes != importBatchSize+1 || writer.edges != 1 {
+		t.Errorf("counted %d nodes and %d edges", writer.nodes, writer.edges)
+	}
+
+	writer.phase = importPhaseNodes
+	if err := writer.Node(ImportNode{Label: "Comic) DETACH DELETE (n", ID: "C"}); err == nil {
+		t.Error("expected an invalid label to be rejected")
+	}
+	if err := writer.Node(ImportNode{Label: "Comic", Labels: []string{"X) DETACH DELETE (n"}, ID: "C"}); err == nil {
+		t.Error("expected an invalid extra label to be rejected")
+	}
+
+	// Extra labels are batched apart and set after the merge
+	statements = nil
+	writer.Node(ImportNode{Label: "Hero", Labels: []string{"Placeholder"}, ID: "G"})
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 || !strings.Contains(statements[0], "MERGE (n:Hero {id: row.id})") || !strings.HasSuffix(statements[0], ", n:Placeholder") {
+		t.Errorf("statements = %q", statements)
+	}
+}
+
+func TestDanglingPolicies(t *testing.T) {
+	load := func(policy string) (*graphWriter, []string, error) {
+		var statements []string
+		writer := newGraphWriter(func(cypherQuery string, params map[string]interface{}) error {
+			statements = append(statements, cypherQuery)
+			return nil
+		})
+		writer.dangling = policy
+		writer.phase = importPhaseNodes
+		writer.Node(ImportNode{Label: "Hero", ID: "THOR"})
+		writer.phase = importPhaseEdges
+		for _, edge := range []ImportEdge{
+			{Type: "KNOWS", SourceLabel: "Hero", Source: "THOR", TargetLabel: "Hero", Target: "LOKI"},
+			{Type: "KNOWS", SourceLabel: "Hero", Source: "SIF", TargetLabel: "Hero", Target: "LOKI"},
+			{Type: "KNOWS", SourceLabel: "Hero", Source: "THOR", TargetLabel: "Hero", Target: "THOR"},
+		} {
+			if err := writer.Edge(edge); err != nil {
+				return writer, statements, err
+			}
+		}
+		return writer, statements, writer.Flush()
+	}
+
+	writer, _, err := load(danglingSkip)
+	if err != nil || writer.edges != 1 || writer.danglingEdges != 2 || writer.placeholders != 0 {
+		t.Errorf("skip: %d edges, %d dangling, %d placeholders, err %v", writer.edges, writer.danglingEdges, writer.placeholders, err)
+	}
+
+	writer, statements, err := load(danglingPlaceholder)
+	if err != nil || writer.edges != 3 || writer.danglingEdges != 2 || writer.placeholders != 2 {
+		t.Errorf("placeholder: %d edges, %d dangling, %d placeholders, err %v", writer.edges, writer.danglingEdges, writer.placeholders, err)
+	}
+	if len(statements) != 3 || !strings.Contains(statements[1], "SET n:Placeholder") || !strings.Contains(statements[2], "MERGE (a)-[r:KNOWS]->(b)") {
+		t.Errorf("placeholders should be written before the edges: %q", statements)
+	}
+
+	writer, _, err = load(danglingFail)
+	if err == nil || !strings.Contains(err.Error(), "missing Hero LOKI") || writer.edges != 0 {
+		t.Errorf("fail: %d edges, err %v", writer.edges, err)
+	}
+
+	if err := validateDatasetSpec(DatasetSpec{Path: "x.csv", Dangling: "ignore"}); err == nil {
+		t.Error("expected an unknown dangling policy to be rejected")
+	}
+}
+
+func TestSymmetricRelationships(t *testing.T) {
+	var rows int
+	writer := newGraphWriter(func(cypherQuery string, params map[string]interface{}) error {
+		if strings.Contains(cypherQuery, "MERGE (a)") {
+			rows += len(params["rows"].([]interface{}))
+		}
+		return nil
+	})
+	writer.symmetric = map[string]bool{"KNOWS": true}
+	writer.phase = importPhaseNodes
+	for _, id := range []string{"THOR", "LOKI"} {
+		writer.Node(ImportNode{Label: "Hero", ID: id})
+	}
+	writer.phase = importPhaseEdges
+	for _, edge := range []ImportEdge{
+		{Type: "KNOWS", SourceLabel: "Hero", Source: "THOR", TargetLabel: "Hero", Target: "LOKI"},
+		{Type: "KNOWS", SourceLabel: "Hero", Source: "LOKI", TargetLabel: "Hero", Target: "THOR"},
+		{Type: "KNOWS", SourceLabel: "Hero", Source: "THOR", TargetLabel: "Hero", Target: "THOR"},
+		{Type: "RIVALS", SourceLabel: "Hero", Source: "LOKI", TargetLabel: "Hero", Target: "THOR"},
+		{Type: "RIVALS", SourceLabel: "Hero", Source: "THOR", TargetLabel: "Hero", Target: "LOKI"},
+	} {
+		writer.Edge(edge)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 4 || writer.reverseDuplicates != 1 {
+		t.Errorf("wrote %d relationships with %d reverse duplicates, want 4 and 1", rows, writer.reverseDuplicates)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, datasetManifest), []byte(`{"symmetric_relationships": ["KNOWS"]}`), 0644)
+	symmetric, err := loadSymmetricRelationships(dir)
+	if err != nil || !reflect.DeepEqual(symmetric, map[string]bool{"KNOWS": true}) {
+		t.Errorf("symmetric = %v, %v", symmetric, err)
+	}
+	os.WriteFile(filepath.Join(dir, datasetManifest), []byte(`{"symmetric_relationships": ["knows]-(x"]}`), 0644)
+	if _, err := loadSymmetricRelationships(dir); err == nil {
+		t.Error("expected an invalid relationship type to be rejected")
+	}
+}
+
+func TestDiscoverDatasets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"marvel/nodes.csv", "gephi/heroes.graphml", "network/knows.txt", "extra.jsonl", "README.md"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+	}
+	manifest := `{"datasets": [{"path": "network/knows.txt", "format": "edgelist", "node_label": "Hero", "relationship_type": "KNOWS"}]}`
+	os.WriteFile(filepath.Join(dir, datasetManifest), []byte(manifest), 0644)
+
+	specs, err := discoverDatasets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, spec := range specs {
+		rel, _ := filepath.Rel(dir, spec.Path)
+		got = append(got, rel+":"+importFormat(spec))
+	}
+	want := []string{"network/knows.txt:edgelist", "extra.jsonl:jsonl", "gephi/heroes.graphml:graphml", "marvel/nodes.csv:csv"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("datasets = %q, want %q", got, want)
+	}
+
+	os.WriteFile(filepath.Join(dir, datasetManifest), []byte(`{"datasets": [{"path": "x.xlsx"}]}`), 0644)
+	if _, err := discoverDatasets(dir); err == nil || !strings.Contains(err.Error(), "unknown import format") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestImportKeepsReservedProperties(t *testing.T) {
+	// A source property named like the merge key or a provenance field must
+	// not re-key the node or overwrite where it came from
+	content := `{"kind": "node", "label": "Hero", "id": "HULK", "properties": {"id": "BANNER", "source_file": "forged.csv", "import_id": "x", "appearances": 1}}
+{"kind": "node", "label": "Hero", "id": "THOR", "properties": {"appearances": 2}}
+{"kind": "edge", "source": "HULK", "source_label": "Hero", "target": "THOR", "target_label": "Hero", "properties": {"source_row": 99, "weight": 0.5}}
+`
+	var rows []map[string]interface{}
+	writer := newGraphWriter(func(cypherQuery string, params map[string]interface{}) error {
+		for _, row := range params["rows"].([]interface{}) {
+			rows = append(rows, row.(map[string]interface{}))
+		}
+		return nil
+	})
+	writer.dataset, writer.source = "extra", "extra.jsonl"
+	for _, phase := range []string{importPhaseNodes, importPhaseEdges} {
+		writer.phase = phase
+		if err := importJSONLines(strings.NewReader(content), DatasetSpec{Path: "extra.jsonl", RelationshipType: "KNOWS"}, writer); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	want := []map[string]interface{}{
+		{"appearances": int64(1)},
+		{"appearances": int64(2)},
+		{"weight": 0.5},
+	}
+	for i, row := range rows {
+		if !reflect.DeepEqual(row["properties"], want[i]) {
+			t.Errorf("row %d properties = %+v, want %+v", i, row["properties"], want[i])
+		}
+	}
+	if rows[0]["id"] != "HULK" || rows[0]["file"] != "extra.jsonl" {
+		t.Errorf("node row = %+v, want id HULK and the loader's provenance", rows[0])
+	}
+}
+
+func TestLoadDatasetsChecksBeforeClearing(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "heroes.jsonl"), []byte(`{"kind": "node", "label": "Hero", "id": "THOR"}
+{"kind": "edge", "source_label": "Hero", "source": "THOR", "target_label": "Hero", "target": "LOKI"}
+`), 0644)
+	load := func(policy string) ([]string, error) {
+		manifest := `{"datasets": [{"path": "heroes.jsonl", "relationship_type": "KNOWS", "dangling": "` + policy + `"}]}`
+		os.WriteFile(filepath.Join(dir, datasetManifest), []byte(manifest), 0644)
+		specs, err := discoverDatasets(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The graph records what was done to it, starting from a previous load
+		graph := []string{"previous graph"}
+		_, err = loadDatasets(dir, specs, graphLoad{
+			clear:   func() error { graph = nil; return nil },
+			migrate: func() error { graph = append(graph, "migrated"); return nil },
+			write: func(cypherQuery string, params map[string]interface{}) error {
+				graph = append(graph, strings.Split(cypherQuery, "\n")[1])
+				return nil
+			},
+		})
+		return graph, err
+	}
+
+	graph, err := load(danglingFail)
+	if err == nil || !strings.Contains(err.Error(), "missing Hero LOKI") {
+		t.Errorf("fail: error = %v", err)
+	}
+	if !reflect.DeepEqual(graph, []string{"previous graph"}) {
+		t.Errorf("a rejected load changed the graph: %q", graph)
+	}
+
+	graph, err = load(danglingPlaceholder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"migrated", "MERGE (n:Hero {id: row.id})", "MERGE (n:Hero {id: row.id})", "MATCH (a:Hero {id: row.source})"}
+	if !reflect.DeepEqual(graph, want) {
+		t.Errorf("placeholder load wrote %q", graph)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	llmCachePath     = "data/llm_cache.jsonl"
+	llmCacheCapacity = 1000
+	llmCacheTTL      = 24 * time.Hour
+)
+
+// LLMCacheKey identifies a completion. Context carries any other prompt input
+// (few-shot examples, or the Cypher and results behind an answer) as a hash.
+type LLMCacheKey struct {
+	Role              string
+	Model             string
+	PromptVersion     string
+	SchemaFingerprint string
+	Question          string
+	Context           string
+}
+
+func (k LLMCacheKey) String() string {
+	return fingerprint(strings.Join([]string{k.Role, k.Model, k.PromptVersion, k.SchemaFingerprint, k.Question, k.Context}, "\x00"))
+}
+
+type CacheHits struct {
+	Cypher  bool `json:"cypher"`
+	Results bool `json:"results"`
+	Answer  bool `json:"answer"`
+}
+
+type llmCacheRecord struct {
+	Key        string    `json:"key"`
+	Completion string    `json:"completion"`
+	Expires    time.Time `json:"expires"`
+	// Deleted marks a record that removes an earlier completion
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// LLMCache keeps completions in an in-memory LRU backed by an append-only
+// JSONL file, which is compacted to the live entries on startup.
+type LLMCache struct {
+	lru  *lruCache[string]
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func openLLMCache(path string, capacity int, ttl time.Duration) (*LLMCache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	cache := &LLMCache{lru: newLRUCache[string](capacity, ttl), path: path}
+
+	// Replay the log; later records win and expired ones are dropped
+	if existing, err := os.Open(path); err == nil {
+		now := time.Now()
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var record llmCacheRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue
+			}
+			if record.Deleted {
+				cache.lru.Delete(record.Key)
+				continue
+			}
+			if !record.Expires.IsZero() && now.After(record.Expires) {
+				continue
+			}
+			cache.lru.putWithExpiry(record.Key, record.Completion, record.Expires)
+		}
+		existing.Close()
+	}
+
+	if err := cache.rewrite(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// rewrite replaces the log with the live entries, oldest first so a replay
+// restores the same recency order.
+func (c *LLMCache) rewrite() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file != nil {
+		c.file.Close()
+	}
+	file, err := os.Create(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to write LLM cache: %v", err)
+	}
+
+	entries := c.lru.entries()
+	encoder := json.NewEncoder(file)
+	for i := len(entries) - 1; i >= 0; i-- {
+		encoder.Encode(llmCacheRecord{Key: entries[i].key, Completion: entries[i].value, Expires: entries[i].expires})
+	}
+	c.file = file
+	return nil
+}
+
+func (c *LLMCache) Get(key LLMCacheKey) (string, bool) {
+	return c.lru.Get(key.String())
+}
+
+func (c *LLMCache) Put(key LLMCacheKey, completion string) {
+	var expires time.Time
+	if c.lru.ttl > 0 {
+		expires = time.Now().Add(c.lru.ttl)
+	}
+	c.lru.putWithExpiry(key.String(), completion, expires)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	line, _ := json.Marshal(llmCacheRecord{Key: key.String(), Completion: completion, Expires: expires})
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to persist LLM cache entry: %v", err)
+	}
+}
+
+// Delete drops one completion, such as a query the database has rejected.
+func (c *LLMCache) Delete(key LLMCacheKey) {
+	c.lru.Delete(key.String())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	line, _ := json.Marshal(llmCacheRecord{Key: key.String(), Deleted: true})
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to persist LLM cache entry: %v", err)
+	}
+}
+
+// Invalidate drops every cached completion, in memory and on disk.
+func (c *LLMCache) Invalidate() error {
+	c.lru.Clear()
+	return c.rewrite()
+}
+
+func (c *LLMCache) Stats() cacheStats {
+	return c.lru.Stats()
+}
+
+func (c *LLMCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// cacheTTLFromEnv reads a cache's TTL from the environment variable name,
+// falling back to fallback when it is unset or invalid.
+func cacheTTLFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %v: %v", name, value, fallback, err)
+		return fallback
+	}
+	return ttl
+}
+
+// normalizeQuestion folds case, whitespace and trailing punctuation so trivially
+// different phrasings of the same question share a cache entry.
+func normalizeQuestion(question string) string {
+	question = strings.ToLower(strings.Join(strings.Fields(question), " "))
+	return strings.TrimRight(question, "?!. ")
+}
+
+func fingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
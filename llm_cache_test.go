@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newLRUCache[string](2, 0)
+	cache.Put("a", "1")
+	cache.Put("b", "2")
+	cache.Get("a")
+	cache.Put("c", "3")
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits != 3 || stats.Misses != 1 {
+		t.Errorf("stats = %+v, want 3 hits and 1 miss", stats)
+	}
+}
+
+func TestLRUCacheExpiresEntries(t *testing.T) {
+	cache := newLRUCache[string](10, time.Millisecond)
+	cache.Put("a", "1")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expected entry to expire")
+	}
+}
+
+func TestLLMCachePersistsAndInvalidates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm_cache.jsonl")
+	key := LLMCacheKey{Role: llmRoleCypher, Model: "ollama/llama3.2", PromptVersion: "v1", SchemaFingerprint: "abc", Question: normalizeQuestion("Who are Thor's partners?")}
+
+	cache, err := openLLMCache(path, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(key, "MATCH (n) RETURN n")
+	cache.Close()
+
+	reopened, err := openLLMCache(path, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameQuestion := key
+	sameQuestion.Question = normalizeQuestion("  who are THOR'S   partners ")
+	if got, ok := reopened.Get(sameQuestion); !ok || got != "MATCH (n) RETURN n" {
+		t.Fatalf("after reopen got %q, %v", got, ok)
+	}
+
+	otherSchema := key
+	otherSchema.SchemaFingerprint = "def"
+	if _, ok := reopened.Get(otherSchema); ok {
+		t.Error("a different schema fingerprint must miss")
+	}
+
+	if err := reopened.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+	reopened.Close()
+
+	afterInvalidate, err := openLLMCache(path, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer afterInvalidate.Close()
+	if _, ok := afterInvalidate.Get(key); ok {
+		t.Error("expected cache to be empty after invalidation")
+	}
+}
+
+func TestLLMCacheDeleteSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "llm_cache.jsonl")
+	kept := LLMCacheKey{Role: llmRoleCypher, Question: "kept"}
+	deleted := LLMCacheKey{Role: llmRoleCypher, Question: "deleted"}
+
+	cache, err := openLLMCache(path, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Put(kept, "MATCH (n) RETURN n")
+	cache.Put(deleted, "MATCH (n) RETURN m")
+	cache.Delete(deleted)
+	if _, ok := cache.Get(deleted); ok {
+		t.Error("deleted entry is still cached")
+	}
+	cache.Close()
+
+	reopened, err := openLLMCache(path, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, ok := reopened.Get(deleted); ok {
+		t.Error("deleted entry came back after reopen")
+	}
+	if _, ok := reopened.Get(kept); !ok {
+		t.Error("kept entry is missing after reopen")
+	}
+}
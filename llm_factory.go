@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/googleai"
+	"github.com/tmc/langchaingo/llms/googleai/vertex"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// LLM roles. Each role is configured separately so a small, fast model can
+// write Cypher while a larger one writes the prose answer.
+const (
+	llmRoleCypher = "cypher"
+	llmRoleAnswer = "answer"
+)
+
+type LLMConfig struct {
+	Provider string
+	Model    string
+	BaseURL  string
+	APIKey   string
+	Project  string
+	Location string
+}
+
+func (c LLMConfig) Name() string {
+	return c.Provider + "/" + c.Model
+}
+
+type ModelNames struct {
+	Cypher string `json:"cypher"`
+	Answer string `json:"answer"`
+}
+
+var defaultModels = map[string]string{
+	"ollama":   "llama3.2",
+	"openai":   "gpt-4o-mini",
+	"googleai": "gemini-1.5-flash",
+	"vertex":   "gemini-1.5-flash",
+}
+
+// llmConfigFromEnv reads <ROLE>_LLM_* variables, falling back to the shared
+// LLM_* variables and then to Ollama with llama3.2.
+func llmConfigFromEnv(role string) LLMConfig {
+	get := func(key string) string {
+		if value := os.Getenv(strings.ToUpper(role) + "_LLM_" + key); value != "" {
+			return value
+		}
+		return os.Getenv("LLM_" + key)
+	}
+
+	config := LLMConfig{
+		Provider: strings.ToLower(get("PROVIDER")),
+		Model:    get("MODEL"),
+		BaseURL:  get("BASE_URL"),
+		APIKey:   get("API_KEY"),
+		Project:  get("PROJECT"),
+		Location: get("LOCATION"),
+	}
+	if config.Provider == "" {
+		config.Provider = "ollama"
+	}
+	if config.Model == "" {
+		config.Model = defaultModels[config.Provider]
+	}
+	return config
+}
+
+func newLLM(config LLMConfig) (llms.Model, error) {
+	switch config.Provider {
+	case "ollama":
+		opts := []ollama.Option{ollama.WithModel(config.Model)}
+		if config.BaseURL != "" {
+			opts = append(opts, ollama.WithServerURL(config.BaseURL))
+		}
+		return ollama.New(opts...)
+
+	case "openai":
+		// Local OpenAI-compatible servers such as llama.cpp ignore the key,
+		// but the client refuses to start without one.
+		apiKey := config.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if apiKey == "" && config.BaseURL != "" {
+			apiKey = "no-key"
+		}
+		opts := []openai.Option{openai.WithModel(config.Model), openai.WithToken(apiKey)}
+		if config.BaseURL != "" {
+			opts = append(opts, openai.WithBaseURL(config.BaseURL))
+		}
+		return openai.New(opts...)
+
+	case "googleai":
+		apiKey := config.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("GOOGLE_API_KEY")
+		}
+		return googleai.New(context.Background(),
+			googleai.WithAPIKey(apiKey),
+			googleai.WithDefaultModel(config.Model),
+		)
+
+	case "vertex":
+		if config.Project == "" {
+			return nil, fmt.Errorf("vertex provider requires LLM_PROJECT")
+		}
+		location := config.Location
+		if location == "" {
+			location = "us-central1"
+		}
+		return vertex.New(context.Background(),
+			googleai.WithCloudProject(config.Project),
+			googleai.WithCloudLocation(location),
+			googleai.WithDefaultModel(config.Model),
+		)
+	}
+
+	return nil, fmt.Errorf("unknown LLM provider %q (expected ollama, openai, googleai or vertex)", config.Provider)
+}
+
+// newRoleLLMs builds the Cypher and answer models, sharing one client when
+// both roles are configured identically. LLM_REPLAY=<file> answers every
+// prompt from recorded fixtures instead; LLM_RECORD=<file> records the live
+// session into that file.
+func newRoleLLMs() (llms.Model, llms.Model, ModelNames, error) {
+	cypherConfig := llmConfigFromEnv(llmRoleCypher)
+	answerConfig := llmConfigFromEnv(llmRoleAnswer)
+	names := ModelNames{Cypher: cypherConfig.Name(), Answer: answerConfig.Name()}
+
+	if path := os.Getenv("LLM_REPLAY"); path != "" {
+		store, err := openFixtureStore(path, false)
+		if err != nil {
+			return nil, nil, names, err
+		}
+		replay := &FixtureLLM{store: store}
+		names = ModelNames{Cypher: "replay/" + path, Answer: "replay/" + path}
+		return replay, replay, names, nil
+	}
+
+	cypherModel, err := newLLM(cypherConfig)
+	if err != nil {
+		return nil, nil, names, fmt.Errorf("cypher LLM: %v", err)
+	}
+	answerModel := cypherModel
+	if answerConfig != cypherConfig {
+		answerModel, err = newLLM(answerConfig)
+		if err != nil {
+			return nil, nil, names, fmt.Errorf("answer LLM: %v", err)
+		}
+	}
+
+	if path := os.Getenv("LLM_RECORD"); path != "" {
+		store, err := openFixtureStore(path, true)
+		if err != nil {
+			return nil, nil, names, err
+		}
+		cypherModel = &FixtureLLM{store: store, inner: cypherModel}
+		answerModel = &FixtureLLM{store: store, inner: answerModel}
+	}
+	return cypherModel, answerModel, names, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// clearLLMEnv unsets every variable the LLM factory reads, so tests do not
+// depend on the developer's environment.
+func clearLLMEnv(t *testing.T) {
+	t.Helper()
+	for _, prefix := range []string{"", "CYPHER_", "ANSWER_"} {
+		for _, key := range []string{"PROVIDER", "MODEL", "BASE_URL", "API_KEY", "PROJECT", "LOCATION"} {
+			t.Setenv(prefix+"LLM_"+key, "")
+		}
+	}
+	for _, name := range []string{"OPENAI_API_KEY", "GOOGLE_API_KEY", "LLM_REPLAY", "LLM_RECORD"} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestLLMConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		role string
+		want LLMConfig
+	}{
+		{
+			name: "defaults",
+			role: llmRoleCypher,
+			want: LLMConfig{Provider: "ollama", Model: "llama3.2"},
+		},
+		{
+			name: "provider default model",
+			env:  map[string]string{"LLM_PROVIDER": "OpenAI"},
+			role: llmRoleAnswer,
+			want: LLMConfig{Provider: "openai", Model: "gpt-4o-mini"},
+		},
+		{
+			name: "shared settings",
+			env:  map[string]string{"LLM_PROVIDER": "vertex", "LLM_MODEL": "gemini-2.0-flash", "LLM_PROJECT": "marvel", "LLM_LOCATION": "europe-west4"},
+			role: llmRoleCypher,
+			want: LLMConfig{Provider: "vertex", Model: "gemini-2.0-flash", Project: "marvel", Location: "europe-west4"},
+		},
+		{
+			name: "role settings override shared ones",
+			env: map[string]string{"LLM_PROVIDER": "ollama", "LLM_MODEL": "llama3.1:8b",
+				"CYPHER_LLM_PROVIDER": "openai", "CYPHER_LLM_BASE_URL": "http://localhost:8081/v1", "CYPHER_LLM_MODEL": "qwen2.5-coder-1.5b"},
+			role: llmRoleCypher,
+			want: LLMConfig{Provider: "openai", Model: "qwen2.5-coder-1.5b", BaseURL: "http://localhost:8081/v1"},
+		},
+		{
+			name: "other role's settings ignored",
+			env:  map[string]string{"LLM_MODEL": "llama3.1:8b", "CYPHER_LLM_MODEL": "qwen2.5-coder-1.5b"},
+			role: llmRoleAnswer,
+			want: LLMConfig{Provider: "ollama", Model: "llama3.1:8b"},
+		},
+		{
+			name: "role provider gets its own default model",
+			env:  map[string]string{"ANSWER_LLM_PROVIDER": "googleai", "ANSWER_LLM_API_KEY": "key"},
+			role: llmRoleAnswer,
+			want: LLMConfig{Provider: "googleai", Model: "gemini-1.5-flash", APIKey: "key"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clearLLMEnv(t)
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			if got := llmConfigFromEnv(tc.role); got != tc.want {
+				t.Errorf("config = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewLLM(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LLMConfig
+		check   func(model interface{}) bool
+		wantErr string
+	}{
+		{
+			name:   "ollama",
+			config: LLMConfig{Provider: "ollama", Model: "llama3.2", BaseURL: "http://localhost:11434"},
+			check:  func(model interface{}) bool { _, ok := model.(*ollama.LLM); return ok },
+		},
+		{
+			// Local OpenAI-compatible servers need no key
+			name:   "openai-compatible server",
+			config: LLMConfig{Provider: "openai", Model: "qwen2.5-coder-1.5b", BaseURL: "http://localhost:8081/v1"},
+			check:  func(model interface{}) bool { _, ok := model.(*openai.LLM); return ok },
+		},
+		{
+			name:   "openai with a key",
+			config: LLMConfig{Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-test"},
+			check:  func(model interface{}) bool { _, ok := model.(*openai.LLM); return ok },
+		},
+		{
+			// Without a base URL there is no local server to ignore the key
+			name:    "openai without a key",
+			config:  LLMConfig{Provider: "openai", Model: "gpt-4o-mini"},
+			wantErr: "missing the OpenAI API key",
+		},
+		{
+			name:    "vertex without a project",
+			config:  LLMConfig{Provider: "vertex", Model: "gemini-1.5-flash"},
+			wantErr: "requires LLM_PROJECT",
+		},
+		{
+			name:    "unknown provider",
+			config:  LLMConfig{Provider: "anthropic", Model: "any"},
+			wantErr: `unknown LLM provider "anthropic"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			clearLLMEnv(t)
+			model, err := newLLM(tc.config)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.check(model) {
+				t.Errorf("model is a %T", model)
+			}
+		})
+	}
+}
+
+func TestNewRoleLLMs(t *testing.T) {
+	clearLLMEnv(t)
+	cypherModel, answerModel, names, err := newRoleLLMs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Identically configured roles share one client
+	if cypherModel != answerModel || names != (ModelNames{Cypher: "ollama/llama3.2", Answer: "ollama/llama3.2"}) {
+		t.Errorf("shared config: %T and %T, names %+v", cypherModel, answerModel, names)
+	}
+
+	t.Setenv("ANSWER_LLM_MODEL", "llama3.1:8b")
+	cypherModel, answerModel, names, err = newRoleLLMs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cypherModel == answerModel || names.Answer != "ollama/llama3.1:8b" {
+		t.Errorf("separate configs: shared = %v, names %+v", cypherModel == answerModel, names)
+	}
+
+	t.Setenv("ANSWER_LLM_PROVIDER", "vertex")
+	if _, _, _, err := newRoleLLMs(); err == nil || !strings.HasPrefix(err.Error(), "answer LLM:") {
+		t.Errorf("error = %v, want the answer LLM's", err)
+	}
+}
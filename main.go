@@ -2,9 +2,33 @@ package main
 
 import (
 	"fmt"
+	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "load":
+			runLoadCommand(os.Args[2:])
+			return
+		case "eval":
+			runEvalCommand(os.Args[2:])
+			return
+		case "snapshot":
+			runSnapshotCommand(os.Args[2:])
+			return
+		case "restore":
+			runRestoreCommand(os.Args[2:])
+			return
+		case "migrate":
+			runMigrateCommand(os.Args[2:])
+			return
+		case "rdf-export":
+			runRDFExportCommand(os.Args[2:])
+			return
+		}
+	}
+
 	fmt.Println("🚀 Marvel Comics Graph RAG System")
 	fmt.Println("🌐 Starting Web UI...")
 	fmt.Println("📱 Open your browser and go to: http://localhost:8080")
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const migrationsDir = "migrations"
+
+// Migration files are named like 0002_add_team_constraints.cypher
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.cypher$`)
+
+// Migration is one versioned file of schema statements, separated by
+// semicolons at the end of a line.
+type Migration struct {
+	Version    int
+	Name       string
+	Path       string
+	Checksum   string
+	Statements []string
+}
+
+// AppliedMigration is what a :SchemaMigration node records.
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt string
+}
+
+// MigrationState pairs a migration file with its record in the database.
+type MigrationState struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+const (
+	migrationPending = "pending"
+	migrationApplied = "applied"
+	// The file changed after it was applied
+	migrationChanged = "changed"
+	// Applied, but the file is gone
+	migrationMissing = "missing"
+	// Pending, but a later version is already applied
+	migrationOutOfOrder = "out-of-order"
+)
+
+func loadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %v", err)
+	}
+
+	var migrations []Migration
+	versions := make(map[int]string)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".cypher" {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: migration files must be named <version>_<name>.cypher", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if other, ok := versions[version]; ok {
+			return nil, fmt.Errorf("%s and %s have the same version", other, entry.Name())
+		}
+		versions[version] = entry.Name()
+
+		path := filepath.Join(dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		sum := sha256.Sum256(content)
+		migration := Migration{
+			Version:    version,
+			Name:       match[2],
+			Path:       path,
+			Checksum:   hex.EncodeToString(sum[:]),
+			Statements: splitCypherStatements(string(content)),
+		}
+		if len(migration.Statements) == 0 {
+			return nil, fmt.Errorf("%s has no statements", path)
+		}
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// splitCypherStatements drops // comment lines and splits on semicolons that
+// end a line.
+func splitCypherStatements(content string) []string {
+	var statements []string
+	var current []string
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSuffix(strings.TrimSpace(strings.Join(current, "\n")), ";")
+			statements = append(statements, strings.TrimSpace(statement))
+			current = nil
+		}
+	}
+	if statement := strings.TrimSpace(strings.Join(current, "\n")); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
+
+func appliedMigrations(query GraphRecords) (map[int]AppliedMigration, error) {
+	records, err := query(`MATCH (m:SchemaMigration)
+RETURN m.version AS version, m.name AS name, m.checksum AS checksum, m.applied_at AS applied_at
+ORDER BY version`, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	applied := make(map[int]AppliedMigration)
+	for _, record := range records {
+		version, _ := record["version"].(int64)
+		migration := AppliedMigration{Version: int(version)}
+		migration.Name, _ = record["name"].(string)
+		migration.Checksum, _ = record["checksum"].(string)
+		migration.AppliedAt, _ = record["applied_at"].(string)
+		applied[migration.Version] = migration
+	}
+	return applied, nil
+}
+
+// migrationStates lists every migration on disk or in the database, by version.
+func migrationStates(migrations []Migration, applied map[int]AppliedMigration) []MigrationState {
+	var states []MigrationState
+	latest := 0
+	for version := range applied {
+		if version > latest {
+			latest = version
+		}
+	}
+	onDisk := make(map[int]bool)
+	for _, migration := range migrations {
+		onDisk[migration.Version] = true
+		state := MigrationState{Version: migration.Version, Name: migration.Name, State: migrationPending}
+		if migration.Version < latest {
+			state.State = migrationOutOfOrder
+		}
+		if record, ok := applied[migration.Version]; ok {
+			state.AppliedAt = record.AppliedAt
+			state.State = migrationApplied
+			if record.Checksum != migration.Checksum {
+				state.State = migrationChanged
+			}
+		}
+		states = append(states, state)
+	}
+	for version, record := range applied {
+		if !onDisk[version] {
+			states = append(states, MigrationState{Version: version, Name: record.Name, State: migrationMissing, AppliedAt: record.AppliedAt})
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states
+}
+
+// migrateUp applies the pending migrations in version order, recording each
+// one after all its statements succeed. It refuses to run while an applied
+// migration's file has changed, since the database no longer matches it, or
+// while a pending migration is older than one already applied, since it
+// would run out of order.
+func migrateUp(query GraphRecords, write GraphWrite, migrations []Migration) ([]Migration, error) {
+	applied, err := appliedMigrations(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range migrationStates(migrations, applied) {
+		switch state.State {
+		case migrationChanged:
+			return nil, fmt.Errorf("migration %04d_%s was changed after it was applied; add a new migration instead", state.Version, state.Name)
+		case migrationOutOfOrder:
+			return nil, fmt.Errorf("migration %04d_%s is older than migrations already applied; renumber it after the latest one", state.Version, state.Name)
+		}
+	}
+
+	var ran []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		for _, statement := range migration.Statements {
+			if err := write(statement, nil); err != nil {
+				return ran, fmt.Errorf("migration %s failed: %v", migration.Path, err)
+			}
+		}
+		err := write(`MERGE (m:SchemaMigration {version: $version})
+SET m.name = $name, m.checksum = $checksum, m.applied_at = $applied_at`, map[string]interface{}{
+			"version":    migration.Version,
+			"name":       migration.Name,
+			"checksum":   migration.Checksum,
+			"applied_at": time.Now().UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return ran, fmt.Errorf("failed to record migration %s: %v", migration.Path, err)
+		}
+		ran = append(ran, migration)
+	}
+	return ran, nil
+}
+
+// migrateSchema brings a namespace's database schema up to date; loads and
+// restores run it before writing. Every namespace keeps its own migration log.
+func migrateSchema(driver neo4j.Driver, namespace string) error {
+	migrations, err := loadMigrations(migrationsDir)
+	if err != nil {
+		return err
+	}
+	session := namespaceSession(driver, namespace, neo4j.AccessModeWrite)
+	defer session.Close()
+
+	query := func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		return queryRecords(driver, namespace, cypherQuery, params, graphRecordLimit)
+	}
+	write := func(cypherQuery string, params map[string]interface{}) error {
+		return runWrite(session, cypherQuery, params)
+	}
+	ran, err := migrateUp(query, write, migrations)
+	for _, migration := range ran {
+		fmt.Printf("🧱 Applied migration %04d_%s\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("✅ Graph schema ready.")
+	return nil
+}
+
+func runMigrateCommand(args []string) {
+	if len(args) == 0 || (args[0] != "status" && args[0] != "up") {
+		log.Fatalf("Usage: migrate status|up")
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	dir := flags.String("dir", migrationsDir, "folder of migration files")
+	namespace := namespaceFlag(flags)
+	flags.Parse(args[1:])
+	checkNamespaceFlag(*namespace)
+
+	driver, err := neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "", ""))
+	if err != nil {
+		log.Fatalf("Failed to create Neo4j driver: %v", err)
+	}
+	defer driver.Close()
+
+	migrations, err := loadMigrations(*dir)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	query := func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		return queryRecords(driver, *namespace, cypherQuery, params, graphRecordLimit)
+	}
+
+	if args[0] == "up" {
+		session := namespaceSession(driver, *namespace, neo4j.AccessModeWrite)
+		defer session.Close()
+		ran, err := migrateUp(query, func(cypherQuery string, params map[string]interface{}) error {
+			return runWrite(session, cypherQuery, params)
+		}, migrations)
+		for _, migration := range ran {
+			fmt.Printf("🧱 Applied migration %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("✅ Schema is up to date.")
+		}
+		return
+	}
+
+	applied, err := appliedMigrations(query)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	icons := map[string]string{migrationApplied: "✅", migrationPending: "⏳", migrationChanged: "⚠️", migrationMissing: "❓", migrationOutOfOrder: "⛔"}
+	for _, state := range migrationStates(migrations, applied) {
+		fmt.Printf("%s %04d_%s  %s %s\n", icons[state.State], state.Version, state.Name, state.State, state.AppliedAt)
+	}
+}
@@ -0,0 +1,5 @@
+// Unique ids for the labels the datasets load, and for the migration log itself
+CREATE CONSTRAINT IF NOT EXISTS FOR (m:SchemaMigration) REQUIRE m.version IS UNIQUE;
+CREATE CONSTRAINT IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE;
+CREATE CONSTRAINT IF NOT EXISTS FOR (h:Hero) REQUIRE h.id IS UNIQUE;
+CREATE CONSTRAINT IF NOT EXISTS FOR (c:Comic) REQUIRE c.id IS UNIQUE;
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	if _, err := loadMigrations(migrationsDir); err != nil {
+		t.Fatalf("repository migrations: %v", err)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "0002_team_index.cypher"), []byte("CREATE INDEX IF NOT EXISTS\nFOR (t:Team) ON (t.name);\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "0001_initial.cypher"), []byte("// ids\nCREATE CONSTRAINT IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE;\nCREATE INDEX IF NOT EXISTS FOR (c:Comic) ON (c.title)"), 0644)
+	os.WriteFile(filepath.Join(dir, "README.md"), nil, 0644)
+
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "team_index" {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	wantStatements := []string{
+		"CREATE CONSTRAINT IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE",
+		"CREATE INDEX IF NOT EXISTS FOR (c:Comic) ON (c.title)",
+	}
+	if !reflect.DeepEqual(migrations[0].Statements, wantStatements) || migrations[1].Statements[0] != "CREATE INDEX IF NOT EXISTS\nFOR (t:Team) ON (t.name)" {
+		t.Errorf("statements = %q, %q", migrations[0].Statements, migrations[1].Statements)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("checksums = %s, %s", migrations[0].Checksum, migrations[1].Checksum)
+	}
+
+	os.WriteFile(filepath.Join(dir, "2_team_index.cypher"), []byte("RETURN 1"), 0644)
+	if _, err := loadMigrations(dir); err == nil || !strings.Contains(err.Error(), "same version") {
+		t.Errorf("duplicate version error = %v", err)
+	}
+	os.Remove(filepath.Join(dir, "2_team_index.cypher"))
+	os.WriteFile(filepath.Join(dir, "add-teams.cypher"), []byte("RETURN 1"), 0644)
+	if _, err := loadMigrations(dir); err == nil {
+		t.Error("expected a misnamed migration to be rejected")
+	}
+}
+
+func TestMigrateUp(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "initial", Checksum: "aaa", Statements: []string{"CREATE CONSTRAINT one"}},
+		{Version: 2, Name: "teams", Checksum: "bbb", Statements: []string{"CREATE CONSTRAINT two", "CREATE INDEX three"}},
+	}
+	applied := []map[string]interface{}{{"version": int64(1), "name": "initial", "checksum": "aaa", "applied_at": "2024-01-01T00:00:00Z"}}
+	query := func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		return applied, nil
+	}
+	var statements []string
+	var recorded []interface{}
+	write := func(cypherQuery string, params map[string]interface{}) error {
+		if strings.Contains(cypherQuery, "SchemaMigration") {
+			recorded = append(recorded, params["version"])
+			return nil
+		}
+		statements = append(statements, cypherQuery)
+		return nil
+	}
+
+	ran, err := migrateUp(query, write, migrations)
+	if err != nil || len(ran) != 1 || ran[0].Version != 2 {
+		t.Fatalf("ran %+v, %v", ran, err)
+	}
+	if !reflect.DeepEqual(statements, []string{"CREATE CONSTRAINT two", "CREATE INDEX three"}) || !reflect.DeepEqual(recorded, []interface{}{2}) {
+		t.Errorf("statements = %q, recorded = %v", statements, recorded)
+	}
+
+	// An edited migration blocks the pending ones
+	applied[0]["checksum"] = "edited"
+	statements = nil
+	if _, err := migrateUp(query, write, migrations); err == nil || !strings.Contains(err.Error(), "0001_initial was changed") || statements != nil {
+		t.Errorf("changed migration: err = %v, statements = %q", err, statements)
+	}
+
+	applied = append(applied, map[string]interface{}{"version": int64(7), "name": "dropped", "checksum": "ccc"})
+	got, _ := appliedMigrations(query)
+	states := migrationStates(migrations, got)
+	var summary []string
+	for _, state := range states {
+		summary = append(summary, state.Name+":"+state.State)
+	}
+	if want := []string{"initial:changed", "teams:out-of-order", "dropped:missing"}; !reflect.DeepEqual(summary, want) {
+		t.Errorf("states = %v, want %v", summary, want)
+	}
+}
+
+func TestMigrateUpOutOfOrder(t *testing.T) {
+	// 0002 was added after 0003 had already been applied
+	migrations := []Migration{
+		{Version: 1, Name: "initial", Checksum: "aaa", Statements: []string{"CREATE CONSTRAINT one"}},
+		{Version: 2, Name: "late", Checksum: "bbb", Statements: []string{"CREATE CONSTRAINT two"}},
+		{Version: 3, Name: "teams", Checksum: "ccc", Statements: []string{"CREATE CONSTRAINT three"}},
+	}
+	query := func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		return []map[string]interface{}{
+			{"version": int64(1), "name": "initial", "checksum": "aaa"},
+			{"version": int64(3), "name": "teams", "checksum": "ccc"},
+		}, nil
+	}
+	var statements []string
+	write := func(cypherQuery string, params map[string]interface{}) error {
+		statements = append(statements, cypherQuery)
+		return nil
+	}
+
+	if _, err := migrateUp(query, write, migrations); err == nil || !strings.Contains(err.Error(), "0002_late is older") || statements != nil {
+		t.Errorf("err = %v, statements = %q", err, statements)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A namespace is a graph in its own Neo4j database, so experimental datasets
+// never share a label space with the Marvel one. The default namespace ("")
+// is the server's home database, loaded from dataset/; namespace x is the
+// database x, loaded from namespaces/x/ (same layout, manifest included).
+// On a server with a single database (Neo4j Community Edition) namespaces
+// share the home database instead; see shared_namespaces.go.
+const namespaceDir = "namespaces"
+
+// Neo4j database names, less dots and the names Neo4j reserves
+var namespacePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{2,62}$`)
+
+// GraphNamespace is what queries against one namespace are generated and
+// checked with: its schema (followed by its notes), its symmetric
+// relationship types and the files its labels and relationship types were
+// loaded from.
+type GraphNamespace struct {
+	Name        string
+	Schema      string
+	Fingerprint string
+	Symmetric   map[string]bool
+	Sources     map[string][]string
+}
+
+var (
+	namespacesMu sync.Mutex
+	namespaces   = make(map[string]*GraphNamespace)
+)
+
+func validateNamespace(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !namespacePattern.MatchString(name) || name == "system" || name == "neo4j" {
+		return fmt.Errorf("invalid namespace %q: use 3-63 lowercase letters, digits and dashes, starting with a letter", name)
+	}
+	return nil
+}
+
+// namespaceDatasetDir is the folder a namespace's datasets are loaded from.
+func namespaceDatasetDir(name string) string {
+	if name == "" {
+		return datasetDir
+	}
+	return filepath.Join(namespaceDir, name)
+}
+
+// namespaceNotes is what prompts are told about a namespace beyond its
+// schema, such as what the data is and how its ids look, read from the
+// notes.md next to its manifest. It starts with a blank line so it can
+// follow the schema, and is empty when there are no notes.
+func namespaceNotes(name string) string {
+	notes, err := os.ReadFile(filepath.Join(namespaceDatasetDir(name), datasetNotes))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read notes of %s: %v", namespaceLabel(name), err)
+		}
+		return ""
+	}
+	if text := strings.TrimSpace(string(notes)); text != "" {
+		return "\n\n" + text
+	}
+	return ""
+}
+
+// namespaceLabel names a namespace in messages.
+func namespaceLabel(name string) string {
+	if name == "" {
+		return "default namespace"
+	}
+	return "namespace " + name
+}
+
+// lookupNamespace returns a namespace, reading its schema the first time it
+// is used.
+func lookupNamespace(name string) (*GraphNamespace, error) {
+	if err := validateNamespace(name); err != nil {
+		return nil, err
+	}
+	namespacesMu.Lock()
+	ns, ok := namespaces[name]
+	namespacesMu.Unlock()
+	if ok {
+		return ns, nil
+	}
+	return refreshNamespace(name)
+}
+
+// knownNamespace returns a namespace already read, or an empty one; it never
+// touches the database.
+func knownNamespace(name string) *GraphNamespace {
+	namespacesMu.Lock()
+	defer namespacesMu.Unlock()
+	if ns, ok := namespaces[name]; ok {
+		return ns
+	}
+	return &GraphNamespace{Name: name}
+}
+
+// refreshNamespace re-reads a namespace's schema, symmetric relationship
+// types and sources, after a load or restore or on first use. A namespace
+// other than the default must have been loaded.
+func refreshNamespace(name string) (*GraphNamespace, error) {
+	ns := &GraphNamespace{Name: name}
+	symmetric, err := loadSymmetricRelationships(namespaceDatasetDir(name))
+	if err != nil {
+		log.Printf("Failed to read symmetric relationships of %s: %v", namespaceLabel(name), err)
+	}
+	ns.Symmetric = symmetric
+
+	ns.Schema = getGraphSchema(driver, name, ns.Symmetric)
+	if ns.Schema == graphSchemaUnavailable && name != "" {
+		return nil, fmt.Errorf("namespace %q has no readable database; load it first", name)
+	}
+	// Prompts render the notes through the schema, and cache keys change with them
+	ns.Schema += namespaceNotes(name)
+	ns.Fingerprint = fingerprint(ns.Schema)
+	if ns.Sources, err = loadGraphSources(fetchGraphRecords(name)); err != nil {
+		log.Printf("Failed to read graph sources of %s: %v", namespaceLabel(name), err)
+	}
+
+	namespacesMu.Lock()
+	namespaces[name] = ns
+	namespacesMu.Unlock()
+	return ns, nil
+}
+
+// createNamespaceDatabase creates a namespace's database if it does not
+// exist yet; the default namespace always does, and on a shared database
+// every namespace lives in it.
+func createNamespaceDatabase(driver neo4j.Driver, name string) error {
+	if name == "" || namespacesShared(driver) {
+		return nil
+	}
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite, DatabaseName: "system"})
+	defer session.Close()
+	if err := runWrite(session, "CREATE DATABASE $name IF NOT EXISTS WAIT", map[string]interface{}{"name": name}); err != nil {
+		return fmt.Errorf("failed to create database for namespace %s: %v", name, err)
+	}
+	return nil
+}
+
+// namespaceFlag adds the --namespace flag every command takes.
+func namespaceFlag(flags *flag.FlagSet) *string {
+	return flags.String("namespace", "", "graph namespace (Neo4j database) to use; empty for the default")
+}
+
+// checkNamespaceFlag stops a command whose --namespace value is invalid.
+func checkNamespaceFlag(name string) {
+	if err := validateNamespace(name); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// requestNamespace is the namespace an API request names in its namespace
+// query parameter.
+func requestNamespace(r *http.Request) (*GraphNamespace, error) {
+	return lookupNamespace(r.URL.Query().Get("namespace"))
+}
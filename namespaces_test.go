@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateNamespace(t *testing.T) {
+	for _, name := range []string{"", "lab", "marvel-2026", "experiments"} {
+		if err := validateNamespace(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+	for _, name := range []string{"ab", "Lab", "2lab", "lab.v2", "lab_v2", "system", "neo4j", "lab/../x"} {
+		if err := validateNamespace(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
+
+func TestNamespacePaths(t *testing.T) {
+	if dir := namespaceDatasetDir(""); dir != datasetDir {
+		t.Errorf("default dataset dir = %q", dir)
+	}
+	if dir := namespaceDatasetDir("lab"); dir != filepath.Join("namespaces", "lab") {
+		t.Errorf("lab dataset dir = %q", dir)
+	}
+	if file := qualityReportFile(""); file != filepath.Join("data", "quality_report.json") {
+		t.Errorf("default quality report = %q", file)
+	}
+	if file := qualityReportFile("lab"); file != filepath.Join("data", "quality_report.lab.json") {
+		t.Errorf("lab quality report = %q", file)
+	}
+	if path := namespaceExamplePath(""); path != exampleLibraryPath {
+		t.Errorf("default example library = %q", path)
+	}
+	if path := namespaceExamplePath("lab"); path != filepath.Join("examples", "lab.jsonl") {
+		t.Errorf("lab example library = %q", path)
+	}
+}
+
+func TestNamespaceNotes(t *testing.T) {
+	// The Marvel notes ship with the default dataset; other namespaces
+	// bring their own or have none
+	if notes := namespaceNotes(""); !strings.HasPrefix(notes, "\n\nThis is a Marvel Comics knowledge graph.") || !strings.Contains(notes, "(h:Hero {id: string, name: string})") {
+		t.Errorf("default notes = %q", notes)
+	}
+	if notes := namespaceNotes("no-such-namespace"); notes != "" {
+		t.Errorf("notes of a namespace without a folder = %q", notes)
+	}
+}
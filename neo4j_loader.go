@@ -1,225 +1,591 @@
 package main
 
 import (
-	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
 
-func loadDataToNeo4j() {
-	// 1. Connect to Neo4j
-	driver, err := neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "Samyuktha@12", ""))
+// graphGeneration is bumped after every load and restore in this process so
+// caches keyed on it never serve results from an earlier graph. Other
+// processes' loads are not seen; the result cache's TTL covers those.
+var graphGeneration atomic.Uint64
+
+const (
+	datasetDir       = "dataset"
+	datasetManifest  = "manifest.json"
+	datasetNotes     = "notes.md"
+	importBatchSize  = 1000
+	importPhaseNodes = "nodes"
+	importPhaseEdges = "edges"
+)
+
+// What to do with an edge whose source or target no file defines
+const (
+	danglingSkip        = "skip"
+	danglingPlaceholder = "placeholder"
+	danglingFail        = "fail"
+)
+
+// DatasetSpec describes one file under dataset/. Files listed in
+// dataset/manifest.json take their settings from it; any other file is
+// imported by extension with the defaults.
+type DatasetSpec struct {
+	Path             string `json:"path"`
+	Format           string `json:"format,omitempty"`
+	NodeLabel        string `json:"node_label,omitempty"`
+	RelationshipType string `json:"relationship_type,omitempty"`
+	IDKey            string `json:"id_key,omitempty"`
+	Mapping          string `json:"mapping,omitempty"`
+	// Dangling is skip (the default), placeholder or fail
+	Dangling string `json:"dangling,omitempty"`
+}
+
+func (spec DatasetSpec) danglingPolicy() string {
+	if spec.Dangling != "" {
+		return spec.Dangling
+	}
+	return danglingSkip
+}
+
+// DatasetLoad is what one file contributed to a load.
+type DatasetLoad struct {
+	Path           string `json:"path"`
+	Format         string `json:"format"`
+	DanglingPolicy string `json:"dangling_policy"`
+	Nodes          int    `json:"nodes"`
+	Relationships  int    `json:"relationships"`
+	DanglingEdges  int    `json:"dangling_edges"`
+	Placeholders   int    `json:"placeholders"`
+	// ReverseDuplicates counts B→A rows of a symmetric type already loaded as A→B
+	ReverseDuplicates int `json:"reverse_duplicates"`
+}
+
+type DatasetManifest struct {
+	Datasets []DatasetSpec `json:"datasets"`
+	// Symmetric lists relationship types with no meaningful direction; each
+	// pair is stored once and queried undirected
+	Symmetric []string `json:"symmetric_relationships,omitempty"`
+}
+
+func readDatasetManifest(dir string) (DatasetManifest, error) {
+	var manifest DatasetManifest
+	content, err := os.ReadFile(filepath.Join(dir, datasetManifest))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
 	if err != nil {
-		log.Fatalf("Failed to create driver: %v", err)
+		return manifest, fmt.Errorf("failed to read manifest: %v", err)
 	}
-	defer driver.Close()
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return manifest, nil
+}
 
-	// 2. Find all CSVs in dataset folder
-	var csvFiles []string
-	err = filepath.Walk("dataset", func(path string, info os.FileInfo, err error) error {
+// loadSymmetricRelationships returns the relationship types the manifest
+// declares symmetric.
+func loadSymmetricRelationships(dir string) (map[string]bool, error) {
+	manifest, err := readDatasetManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	symmetric := make(map[string]bool)
+	for _, relType := range manifest.Symmetric {
+		if !relationshipTypePattern.MatchString(relType) {
+			return nil, fmt.Errorf("invalid symmetric relationship type %q", relType)
+		}
+		symmetric[relType] = true
+	}
+	return symmetric, nil
+}
+
+// discoverDatasets lists the files to import, in manifest order and then in
+// directory order, with paths relative to the working directory.
+func discoverDatasets(dir string) ([]DatasetSpec, error) {
+	manifest, err := readDatasetManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]bool)
+	var specs []DatasetSpec
+	for _, spec := range manifest.Datasets {
+		spec.Path = filepath.Join(dir, spec.Path)
+		if err := validateDatasetSpec(spec); err != nil {
+			return nil, err
+		}
+		listed[spec.Path] = true
+		specs = append(specs, spec)
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".csv") {
-			csvFiles = append(csvFiles, path)
+		if info.IsDir() || listed[path] || info.Name() == datasetManifest {
+			return nil
+		}
+		spec := DatasetSpec{Path: path}
+		if importFormat(spec) != "" {
+			specs = append(specs, spec)
 		}
 		return nil
 	})
 	if err != nil {
-		log.Fatalf("Failed to read datasets folder: %v", err)
+		return nil, fmt.Errorf("failed to read datasets folder: %v", err)
 	}
+	return specs, nil
+}
 
-	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+func validateDatasetSpec(spec DatasetSpec) error {
+	if _, ok := graphImporters[importFormat(spec)]; !ok {
+		return fmt.Errorf("%s: unknown import format %q", spec.Path, importFormat(spec))
+	}
+	if spec.NodeLabel != "" && !labelPattern.MatchString(spec.NodeLabel) {
+		return fmt.Errorf("%s: invalid node label %q", spec.Path, spec.NodeLabel)
+	}
+	if spec.RelationshipType != "" && !relationshipTypePattern.MatchString(spec.RelationshipType) {
+		return fmt.Errorf("%s: invalid relationship type %q", spec.Path, spec.RelationshipType)
+	}
+	switch spec.danglingPolicy() {
+	case danglingSkip, danglingPlaceholder, danglingFail:
+	default:
+		return fmt.Errorf("%s: dangling must be skip, placeholder or fail, got %q", spec.Path, spec.Dangling)
+	}
+	return nil
+}
+
+// loadDataToNeo4j replaces a namespace's graph with every dataset in its
+// folder and returns the report for what it loaded. A dataset whose dangling
+// policy is fail rejects the load before the graph is cleared.
+func loadDataToNeo4j(namespace string) (DataQualityReport, error) {
+	// 1. Connect to Neo4j
+	driver, err := neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "Samyuktha@12", ""))
+	if err != nil {
+		return DataQualityReport{}, fmt.Errorf("failed to create driver: %v", err)
+	}
+	defer driver.Close()
+
+	// 2. Find the dataset files and their importers
+	dir := namespaceDatasetDir(namespace)
+	specs, err := discoverDatasets(dir)
+	if err != nil {
+		return DataQualityReport{}, fmt.Errorf("failed to find datasets: %v", err)
+	}
+
+	if err := createNamespaceDatabase(driver, namespace); err != nil {
+		return DataQualityReport{}, err
+	}
+	session := namespaceSession(driver, namespace, neo4j.AccessModeWrite)
 	defer session.Close()
 
-	// 3. Clear existing data
-	clearDatabase(session)
+	// 3. Check every file, clear existing data, migrate and load
+	report, err := loadDatasets(dir, specs, graphLoad{
+		clear:   func() error { return clearDatabase(session) },
+		migrate: func() error { return migrateSchema(driver, namespace) },
+		write: func(cypherQuery string, params map[string]interface{}) error {
+			return runWrite(session, cypherQuery, params)
+		},
+	})
+	if err != nil {
+		return DataQualityReport{}, err
+	}
 
-	// 4. Create unified schema
-	createSchema(session)
+	// 4. Report what was loaded and what looked wrong in the data
+	reportPath := qualityReportFile(namespace)
+	if err := saveQualityReport(reportPath, report); err != nil {
+		log.Printf("Failed to save data-quality report: %v", err)
+	}
+	fmt.Printf("🩺 Data quality: %d findings, report saved to %s\n", report.Findings, reportPath)
 
-	// 5. Load nodes first, then relationships
-	loadNodesFirst(session, csvFiles)
-	loadRelationships(session, csvFiles)
+	fmt.Printf("✅ All datasets loaded into one unified Neo4j knowledge graph (%s).\n", namespaceLabel(namespace))
+	return report, nil
+}
 
-	fmt.Println("✅ All datasets loaded into one unified Neo4j knowledge graph.")
+// graphLoad is the database side of a load: clearing the graph, applying
+// migrations and running the batched writes.
+type graphLoad struct {
+	clear   func() error
+	migrate func() error
+	write   GraphWrite
 }
 
-func clearDatabase(session neo4j.Session) {
-	_, err := session.Run("MATCH (n) DETACH DELETE n", nil)
+// loadDatasets replaces the graph with the dataset files. Every file is read
+// once without writing before the graph is cleared, so a load that would
+// fail (a malformed file, or a dangling edge under the fail policy) leaves
+// the current graph untouched.
+func loadDatasets(dir string, specs []DatasetSpec, target graphLoad) (DataQualityReport, error) {
+	symmetric, err := loadSymmetricRelationships(dir)
 	if err != nil {
-		log.Fatalf("Failed to clear database: %v", err)
+		return DataQualityReport{}, err
+	}
+	newWriter := func(write GraphWrite) *graphWriter {
+		writer := newGraphWriter(write)
+		writer.symmetric = symmetric
+		writer.root = dir
+		return writer
 	}
-	fmt.Println("🗑️ Database cleared.")
-}
 
-func loadNodesFirst(session neo4j.Session, csvFiles []string) {
-	for _, filePath := range csvFiles {
-		fileName := filepath.Base(filePath)
-		if strings.Contains(strings.ToLower(fileName), "nodes") {
-			fmt.Printf("📂 Loading nodes: %s\n", filePath)
-			loadCSVIntoNeo4j(session, filePath)
+	check := newWriter(func(cypherQuery string, params map[string]interface{}) error { return nil })
+	for _, phase := range []string{importPhaseNodes, importPhaseEdges} {
+		for _, spec := range specs {
+			if err := importDataset(check, spec, phase, &DatasetLoad{}); err != nil {
+				return DataQualityReport{}, fmt.Errorf("failed to load %s, the graph was left unchanged: %v", spec.Path, err)
+			}
+		}
+	}
+
+	if err := target.clear(); err != nil {
+		return DataQualityReport{}, err
+	}
+	if err := target.migrate(); err != nil {
+		return DataQualityReport{}, fmt.Errorf("failed to migrate schema: %v", err)
+	}
+
+	// Nodes from every file first, then relationships
+	writer := newWriter(target.write)
+	writer.importID = newImportID()
+	fmt.Printf("🏷️ Import id: %s\n", writer.importID)
+	quality := newQualityCollector()
+	quality.symmetric = symmetric
+	writer.observer = quality
+	loads := make([]DatasetLoad, len(specs))
+	for _, phase := range []string{importPhaseNodes, importPhaseEdges} {
+		for i, spec := range specs {
+			loads[i].Path, loads[i].Format, loads[i].DanglingPolicy = spec.Path, importFormat(spec), spec.danglingPolicy()
+			if err := importDataset(writer, spec, phase, &loads[i]); err != nil {
+				graphGeneration.Add(1)
+				return DataQualityReport{}, fmt.Errorf("failed to load %s: %v", spec.Path, err)
+			}
+			printDatasetLoad(spec, phase, loads[i])
 		}
 	}
+	graphGeneration.Add(1)
+
+	report := quality.Report()
+	report.Datasets = loads
+	report.ImportID = writer.importID
+	return report, nil
+}
+
+// runLoadCommand loads a namespace's datasets from the command line, as the
+// "Load Data" button does for the web UI.
+func runLoadCommand(args []string) {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	namespace := namespaceFlag(flags)
+	flags.Parse(args)
+	checkNamespaceFlag(*namespace)
+
+	if _, err := loadDataToNeo4j(*namespace); err != nil {
+		log.Fatalf("Data load failed: %v", err)
+	}
+}
+
+// importDataset runs one file's importer, writing only the records of the
+// given phase and adding what it wrote to load.
+func importDataset(writer *graphWriter, spec DatasetSpec, phase string, load *DatasetLoad) error {
+	file, err := os.Open(spec.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer.phase = phase
+	writer.file = spec.Path
+	writer.dataset, writer.source = datasetSource(writer.root, spec.Path)
+	writer.dangling = spec.danglingPolicy()
+	nodes, edges, dangling, placeholders, reverse := writer.nodes, writer.edges, writer.danglingEdges, writer.placeholders, writer.reverseDuplicates
+	// RDF literals are typed; every other format gets its dates by name
+	var sink importSink = temporalSink{writer}
+	if importFormat(spec) == importTurtle {
+		sink = writer
+	}
+	err = graphImporters[importFormat(spec)](file, spec, sink)
+	if err == nil {
+		err = writer.Flush()
+	}
+	load.Nodes += writer.nodes - nodes
+	load.Relationships += writer.edges - edges
+	load.DanglingEdges += writer.danglingEdges - dangling
+	load.Placeholders += writer.placeholders - placeholders
+	load.ReverseDuplicates += writer.reverseDuplicates - reverse
+	return err
+}
+
+func printDatasetLoad(spec DatasetSpec, phase string, load DatasetLoad) {
+	if phase == importPhaseNodes && load.Nodes > 0 {
+		fmt.Printf("✅ %d nodes loaded from %s\n", load.Nodes, spec.Path)
+	}
+	if phase == importPhaseEdges && load.Relationships > 0 {
+		fmt.Printf("✅ %d relationships loaded from %s\n", load.Relationships, spec.Path)
+	}
+	if phase == importPhaseEdges && load.ReverseDuplicates > 0 {
+		fmt.Printf("🔁 %d reverse duplicates of symmetric relationships skipped in %s\n", load.ReverseDuplicates, spec.Path)
+	}
+	if phase == importPhaseEdges && load.DanglingEdges > 0 {
+		fmt.Printf("⚠️ %d edges in %s reference missing nodes (%s: %d placeholders created)\n", load.DanglingEdges, spec.Path, load.DanglingPolicy, load.Placeholders)
+	}
+}
+
+// GraphWrite runs one write statement.
+type GraphWrite func(cypherQuery string, params map[string]interface{}) error
+
+type edgeGroup struct {
+	Type, SourceLabel, TargetLabel string
+}
+
+// graphWriter is the ingestion path every importer feeds: nodes are merged
+// on (label, id) and relationships on their endpoints, in UNWIND batches of
+// importBatchSize rows sharing a label or relationship type. Edges whose
+// endpoints were never written are handled by the dangling policy, and a
+// symmetric relationship is written once per pair, in the first direction seen.
+// Everything written is stamped with its provenance (see provenance.go).
+type graphWriter struct {
+	write    GraphWrite
+	phase    string
+	file     string
+	dangling string
+	// dataset and source are the file's provenance names relative to root,
+	// importID the load's
+	root, dataset, source, importID string
+	// symmetric relationship types and the pairs written for them
+	symmetric map[string]bool
+	pairs     map[edgeKey]bool
+	// observer, when set, sees every record before it is batched
+	observer importSink
+	known    map[nodeKey]bool
+	// nodeBatches are keyed by the node's labels joined by colons
+	nodeBatches        map[string][]interface{}
+	placeholderBatches map[string][]interface{}
+	edgeBatches        map[edgeGroup][]interface{}
+
+	nodes, edges, danglingEdges, placeholders, reverseDuplicates int
+}
+
+func newGraphWriter(write GraphWrite) *graphWriter {
+	return &graphWriter{
+		write:              write,
+		dangling:           danglingSkip,
+		known:              make(map[nodeKey]bool),
+		pairs:              make(map[edgeKey]bool),
+		nodeBatches:        make(map[string][]interface{}),
+		placeholderBatches: make(map[string][]interface{}),
+		edgeBatches:        make(map[edgeGroup][]interface{}),
+	}
 }
 
-func loadRelationships(session neo4j.Session, csvFiles []string) {
-	for _, filePath := range csvFiles {
-		fileName := filepath.Base(filePath)
-		if strings.Contains(strings.ToLower(fileName), "edges") || strings.Contains(strings.ToLower(fileName), "hero-network") {
-			fmt.Printf("📂 Loading relationships: %s\n", filePath)
-			loadCSVIntoNeo4j(session, filePath)
+func (w *graphWriter) Node(node ImportNode) error {
+	if w.phase != importPhaseNodes {
+		return nil
+	}
+	labels := append([]string{node.Label}, node.Labels...)
+	for _, label := range labels {
+		if !labelPattern.MatchString(label) {
+			return fmt.Errorf("invalid node label %q", label)
 		}
 	}
+	if node.Properties == nil {
+		node.Properties = map[string]interface{}{}
+	}
+	node.File = w.file
+	if w.observer != nil {
+		if err := w.observer.Node(node); err != nil {
+			return err
+		}
+	}
+	w.known[nodeKey{node.Label, node.ID}] = true
+	batch := strings.Join(labels, ":")
+	w.nodeBatches[batch] = append(w.nodeBatches[batch], map[string]interface{}{
+		"id": node.ID, "properties": withoutReserved(node.Properties), "dataset": w.dataset, "file": w.source, "row": node.Row,
+	})
+	if len(w.nodeBatches[batch]) >= importBatchSize {
+		return w.flushNodes(batch)
+	}
+	return nil
 }
 
-func createSchema(session neo4j.Session) {
-	constraints := []string{
-		"CREATE CONSTRAINT IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE",
-		"CREATE CONSTRAINT IF NOT EXISTS FOR (h:Hero) REQUIRE h.id IS UNIQUE",
-		"CREATE CONSTRAINT IF NOT EXISTS FOR (c:Comic) REQUIRE c.id IS UNIQUE",
-		"CREATE CONSTRAINT IF NOT EXISTS FOR (m:Movie) REQUIRE m.id IS UNIQUE",
-		"CREATE CONSTRAINT IF NOT EXISTS FOR (t:Team) REQUIRE t.id IS UNIQUE",
+func (w *graphWriter) Edge(edge ImportEdge) error {
+	if w.phase != importPhaseEdges {
+		return nil
 	}
-	for _, constraint := range constraints {
-		_, err := session.Run(constraint, nil)
-		if err != nil {
-			log.Fatalf("Failed to create constraint: %v", err)
+	if !relationshipTypePattern.MatchString(edge.Type) {
+		return fmt.Errorf("invalid relationship type %q", edge.Type)
+	}
+	if !labelPattern.MatchString(edge.SourceLabel) || !labelPattern.MatchString(edge.TargetLabel) {
+		return fmt.Errorf("invalid endpoint labels %q and %q", edge.SourceLabel, edge.TargetLabel)
+	}
+	if edge.Properties == nil {
+		edge.Properties = map[string]interface{}{}
+	}
+	edge.File = w.file
+	if w.observer != nil {
+		if err := w.observer.Edge(edge); err != nil {
+			return err
 		}
 	}
-	fmt.Println("✅ Graph schema ready.")
+
+	var missing []nodeKey
+	for _, key := range []nodeKey{{edge.SourceLabel, edge.Source}, {edge.TargetLabel, edge.Target}} {
+		if !w.known[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		w.danglingEdges++
+		switch w.dangling {
+		case danglingFail:
+			return fmt.Errorf("edge %s -[%s]-> %s references missing %s %s (dangling policy is fail)",
+				edge.Source, edge.Type, edge.Target, missing[0].Label, missing[0].ID)
+		case danglingPlaceholder:
+			for _, key := range missing {
+				w.known[key] = true
+				w.placeholders++
+				w.placeholderBatches[key.Label] = append(w.placeholderBatches[key.Label], map[string]interface{}{
+					"id": key.ID, "dataset": w.dataset, "file": w.source, "row": edge.Row,
+				})
+			}
+		default:
+			return nil
+		}
+	}
+
+	if w.symmetric[edge.Type] {
+		source, target := nodeKey{edge.SourceLabel, edge.Source}, nodeKey{edge.TargetLabel, edge.Target}
+		if w.pairs[edgeKey{edge.Type, target, source}] && source != target {
+			w.reverseDuplicates++
+			return nil
+		}
+		w.pairs[edgeKey{edge.Type, source, target}] = true
+	}
+
+	group := edgeGroup{Type: edge.Type, SourceLabel: edge.SourceLabel, TargetLabel: edge.TargetLabel}
+	w.edgeBatches[group] = append(w.edgeBatches[group], map[string]interface{}{
+		"source": edge.Source, "target": edge.Target, "properties": withoutReserved(edge.Properties),
+		"dataset": w.dataset, "file": w.source, "row": edge.Row,
+	})
+	if len(w.edgeBatches[group]) >= importBatchSize {
+		return w.flushEdges(group)
+	}
+	return nil
 }
 
-func loadCSVIntoNeo4j(session neo4j.Session, filePath string) {
-	fileName := filepath.Base(filePath)
-	dirName := filepath.Base(filepath.Dir(filePath))
+// reservedProperties are written by the loader itself. A source property
+// with one of these names would re-key a node or forge its provenance, so it
+// is left out of the properties set from the source.
+var reservedProperties = []string{"id", provenanceDataset, provenanceFile, provenanceRow, provenanceImport}
+
+func withoutReserved(properties map[string]interface{}) map[string]interface{} {
+	for _, name := range reservedProperties {
+		if _, ok := properties[name]; ok {
+			kept := make(map[string]interface{}, len(properties))
+			for key, value := range properties {
+				kept[key] = value
+			}
+			for _, reserved := range reservedProperties {
+				delete(kept, reserved)
+			}
+			return kept
+		}
+	}
+	return properties
+}
 
-	file, err := os.Open(filePath)
+// flushNodes merges a batch on its first label and sets the others.
+func (w *graphWriter) flushNodes(batch string) error {
+	rows := w.nodeBatches[batch]
+	delete(w.nodeBatches, batch)
+	label, extra, _ := strings.Cut(batch, ":")
+	setLabels := ""
+	if extra != "" {
+		setLabels = ", n:" + extra
+	}
+	err := w.write(`UNWIND $rows AS row
+MERGE (n:`+label+` {id: row.id})
+ON CREATE SET `+provenanceSet("n")+`
+SET n += row.properties, n.import_id = $import_id`+setLabels, map[string]interface{}{"rows": rows, "import_id": w.importID})
 	if err != nil {
-		log.Fatalf("Failed to open %s: %v", filePath, err)
+		return fmt.Errorf("failed to merge %s nodes: %v", label, err)
 	}
-	defer file.Close()
+	w.nodes += len(rows)
+	return nil
+}
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+// flushPlaceholders creates the stand-in nodes for dangling endpoints. A
+// placeholder that a later file defines keeps the :Placeholder label.
+func (w *graphWriter) flushPlaceholders() error {
+	for label, rows := range w.placeholderBatches {
+		delete(w.placeholderBatches, label)
+		err := w.write(`UNWIND $rows AS row
+MERGE (n:`+label+` {id: row.id})
+ON CREATE SET `+provenanceSet("n")+`
+SET n:Placeholder, n.import_id = $import_id`, map[string]interface{}{"rows": rows, "import_id": w.importID})
+		if err != nil {
+			return fmt.Errorf("failed to create %s placeholders: %v", label, err)
+		}
+	}
+	return nil
+}
+
+func (w *graphWriter) flushEdges(group edgeGroup) error {
+	// Edges may point at placeholders still waiting in a batch
+	if err := w.flushPlaceholders(); err != nil {
+		return err
+	}
+	rows := w.edgeBatches[group]
+	delete(w.edgeBatches, group)
+	err := w.write(`UNWIND $rows AS row
+MATCH (a:`+group.SourceLabel+` {id: row.source})
+MATCH (b:`+group.TargetLabel+` {id: row.target})
+MERGE (a)-[r:`+group.Type+`]->(b)
+ON CREATE SET `+provenanceSet("r")+`
+SET r += row.properties, r.import_id = $import_id`, map[string]interface{}{"rows": rows, "import_id": w.importID})
 	if err != nil {
-		log.Fatalf("Failed to read CSV %s: %v", filePath, err)
-	}
-	if len(records) < 2 {
-		fmt.Printf("⚠️ Skipping empty file: %s\n", fileName)
-		return
-	}
-	data := records[1:]
-
-	// === Marvel Characters Partnerships ===
-	if strings.Contains(strings.ToLower(dirName), "marvel_characters_partnerships") {
-		if strings.Contains(strings.ToLower(fileName), "nodes") {
-			for _, row := range data {
-				if len(row) >= 3 {
-					size, _ := strconv.Atoi(row[2])
-					_, err := session.Run(`
-						MERGE (c:Character {id: $id})
-						SET c.name = $id, c.group = $group, c.size = $size
-					`, map[string]interface{}{
-						"id":    row[1],
-						"group": row[0],
-						"size":  size,
-					})
-					if err != nil {
-						log.Printf("Failed MERGE character %s: %v", row[1], err)
-					}
-				}
-			}
-			fmt.Println("✅ Characters loaded from partnerships dataset.")
-		} else if strings.Contains(strings.ToLower(fileName), "edges") {
-			for _, row := range data {
-				if len(row) >= 2 {
-					_, err := session.Run(`
-						MATCH (a:Character {id: $source})
-						MATCH (b:Character {id: $target})
-						MERGE (a)-[:PARTNERS_WITH]->(b)
-					`, map[string]interface{}{
-						"source": row[0],
-						"target": row[1],
-					})
-					if err != nil {
-						log.Printf("Failed MERGE relationship %s -> %s: %v", row[0], row[1], err)
-					}
-				}
-			}
-			fmt.Println("✅ Partnerships loaded.")
-		}
-		return
-	}
-
-	// === Marvel Universe Social Network ===
-	if strings.Contains(strings.ToLower(dirName), "marvel_universe_social_network") {
-		if strings.Contains(strings.ToLower(fileName), "nodes") {
-			for _, row := range data {
-				if len(row) >= 2 {
-					if row[1] == "hero" {
-						_, err := session.Run(`
-							MERGE (h:Hero {id: $id})
-							SET h.name = $id
-						`, map[string]interface{}{"id": row[0]})
-						if err != nil {
-							log.Printf("Failed MERGE hero %s: %v", row[0], err)
-						}
-					} else if row[1] == "comic" {
-						_, err := session.Run(`
-							MERGE (c:Comic {id: $id})
-							SET c.title = $id
-						`, map[string]interface{}{"id": row[0]})
-						if err != nil {
-							log.Printf("Failed MERGE comic %s: %v", row[0], err)
-						}
-					}
-				}
-			}
-			fmt.Println("✅ Heroes & Comics loaded from social network dataset.")
-		} else if strings.Contains(strings.ToLower(fileName), "hero-network") {
-			for _, row := range data {
-				if len(row) >= 2 {
-					_, err := session.Run(`
-						MATCH (h1:Hero {id: $hero1})
-						MATCH (h2:Hero {id: $hero2})
-						MERGE (h1)-[:KNOWS]->(h2)
-					`, map[string]interface{}{
-						"hero1": row[0],
-						"hero2": row[1],
-					})
-					if err != nil {
-						log.Printf("Failed MERGE hero link %s -> %s: %v", row[0], row[1], err)
-					}
-				}
-			}
-			fmt.Println("✅ Hero-to-hero links loaded.")
-		} else if strings.Contains(strings.ToLower(fileName), "edges") {
-			for _, row := range data {
-				if len(row) >= 2 {
-					_, err := session.Run(`
-						MATCH (h:Hero {id: $hero})
-						MATCH (c:Comic {id: $comic})
-						MERGE (h)-[:APPEARS_IN]->(c)
-					`, map[string]interface{}{
-						"hero":  row[0],
-						"comic": row[1],
-					})
-					if err != nil {
-						log.Printf("Failed MERGE appearance %s -> %s: %v", row[0], row[1], err)
-					}
-				}
-			}
-			fmt.Println("✅ Hero-to-comic appearances loaded.")
+		return fmt.Errorf("failed to merge %s relationships: %v", group.Type, err)
+	}
+	w.edges += len(rows)
+	return nil
+}
+
+// Flush writes whatever is left in the batches.
+func (w *graphWriter) Flush() error {
+	for batch := range w.nodeBatches {
+		if err := w.flushNodes(batch); err != nil {
+			return err
 		}
-		return
 	}
+	for group := range w.edgeBatches {
+		if err := w.flushEdges(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	fmt.Printf("⚠️ Skipping unrecognized dataset: %s\n", fileName)
+// runWrite runs a statement and waits for it to finish, so errors raised
+// while executing surface here rather than being dropped.
+func runWrite(session neo4j.Session, cypherQuery string, params map[string]interface{}) error {
+	result, err := session.Run(cypherQuery, params)
+	if err != nil {
+		return err
+	}
+	_, err = result.Consume()
+	return err
+}
+
+// clearDatabase deletes the graph but keeps the migration log, since the
+// schema it describes stays in place.
+func clearDatabase(session neo4j.Session) error {
+	if err := runWrite(session, "MATCH (n) WHERE NOT n:SchemaMigration DETACH DELETE n", nil); err != nil {
+		return fmt.Errorf("failed to clear database: %v", err)
+	}
+	fmt.Println("🗑️ Database cleared.")
+	return nil
 }
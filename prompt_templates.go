@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const promptDir = "prompts"
+
+// Prompt kinds. Template files are named <kind>.<version>.tmpl.
+const (
+	promptKindCypher = "cypher"
+	promptKindAnswer = "answer"
+	promptKindAgent  = "agent"
+	promptKindRepair = "repair"
+)
+
+type PromptTemplate struct {
+	Kind     string
+	Version  string
+	template *template.Template
+}
+
+func (p *PromptTemplate) Render(data interface{}) (string, error) {
+	var sb strings.Builder
+	if err := p.template.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render %s prompt %s: %v", p.Kind, p.Version, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+type PromptExperiment struct {
+	Version string `json:"version"`
+	Percent int    `json:"percent"`
+}
+
+// PromptRouting picks the version used for a prompt kind. When an experiment
+// is set, that share of requests gets the experiment version instead.
+type PromptRouting struct {
+	Active     string            `json:"active"`
+	Experiment *PromptExperiment `json:"experiment,omitempty"`
+}
+
+type PromptVersions struct {
+	Cypher string `json:"cypher"`
+	Answer string `json:"answer"`
+	Agent  string `json:"agent,omitempty"`
+	Repair string `json:"repair,omitempty"`
+}
+
+type PromptStore struct {
+	mu        sync.RWMutex
+	dir       string
+	templates map[string]map[string]*PromptTemplate
+	routing   map[string]PromptRouting
+}
+
+func loadPromptStore(dir string) (*PromptStore, error) {
+	store := &PromptStore{dir: dir}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Reload re-reads every template and the routing file. The previous set stays
+// active if anything fails to parse.
+func (s *PromptStore) Reload() error {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.tmpl"))
+	if err != nil {
+		return fmt.Errorf("failed to list prompt templates: %v", err)
+	}
+
+	templates := make(map[string]map[string]*PromptTemplate)
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("prompt template %s must be named <kind>.<version>.tmpl", path)
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read prompt template %s: %v", path, err)
+		}
+		parsed, err := template.New(name).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse prompt template %s: %v", path, err)
+		}
+
+		kind, version := parts[0], parts[1]
+		if templates[kind] == nil {
+			templates[kind] = make(map[string]*PromptTemplate)
+		}
+		templates[kind][version] = &PromptTemplate{Kind: kind, Version: version, template: parsed}
+	}
+
+	routing := make(map[string]PromptRouting)
+	content, err := os.ReadFile(filepath.Join(s.dir, "routing.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read prompt routing: %v", err)
+	}
+	if err := json.Unmarshal(content, &routing); err != nil {
+		return fmt.Errorf("invalid prompt routing: %v", err)
+	}
+
+	// Every routed version must exist
+	for _, kind := range []string{promptKindCypher, promptKindAnswer, promptKindAgent, promptKindRepair} {
+		route, ok := routing[kind]
+		if !ok {
+			return fmt.Errorf("prompt routing has no entry for %s", kind)
+		}
+		if templates[kind][route.Active] == nil {
+			return fmt.Errorf("active %s prompt %s not found", kind, route.Active)
+		}
+		if route.Experiment != nil {
+			if templates[kind][route.Experiment.Version] == nil {
+				return fmt.Errorf("experiment %s prompt %s not found", kind, route.Experiment.Version)
+			}
+			if route.Experiment.Percent < 0 || route.Experiment.Percent > 100 {
+				return fmt.Errorf("experiment percent for %s must be between 0 and 100", kind)
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.templates = templates
+	s.routing = routing
+	s.mu.Unlock()
+	return nil
+}
+
+// Select returns the template version for a request. Requests are bucketed by
+// hashing the request id so the same request always lands on the same side.
+func (s *PromptStore) Select(kind, requestID string) *PromptTemplate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	route := s.routing[kind]
+	if route.Experiment != nil && route.Experiment.Percent > 0 {
+		hash := fnv.New32a()
+		hash.Write([]byte(kind + ":" + requestID))
+		if int(hash.Sum32()%100) < route.Experiment.Percent {
+			return s.templates[kind][route.Experiment.Version]
+		}
+	}
+	return s.templates[kind][route.Active]
+}
+
+func (s *PromptStore) Describe() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	versions := make(map[string][]string)
+	for kind, byVersion := range s.templates {
+		for version := range byVersion {
+			versions[kind] = append(versions[kind], version)
+		}
+		sort.Strings(versions[kind])
+	}
+	return map[string]interface{}{
+		"versions": versions,
+		"routing":  s.routing,
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePromptDir writes a template of each given version for every prompt
+// kind, plus the routing file.
+func writePromptDir(t *testing.T, dir, routing string, versions ...string) {
+	t.Helper()
+	for _, kind := range []string{promptKindCypher, promptKindAnswer, promptKindAgent, promptKindRepair} {
+		for _, version := range versions {
+			content := kind + " " + version + ": {{.Question}}"
+			if err := os.WriteFile(filepath.Join(dir, kind+"."+version+".tmpl"), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "routing.json"), []byte(routing), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func routingWithCypher(cypher string) string {
+	return `{"cypher": ` + cypher + `, "answer": {"active": "v1"}, "agent": {"active": "v1"}, "repair": {"active": "v1"}}`
+}
+
+func TestPromptStoreSelect(t *testing.T) {
+	tests := []struct {
+		name        string
+		cypherRoute string
+		// wantShare is the percentage of requests expected on v2
+		wantShare int
+	}{
+		{name: "no experiment", cypherRoute: `{"active": "v1"}`, wantShare: 0},
+		{name: "zero percent", cypherRoute: `{"active": "v1", "experiment": {"version": "v2", "percent": 0}}`, wantShare: 0},
+		{name: "split", cypherRoute: `{"active": "v1", "experiment": {"version": "v2", "percent": 30}}`, wantShare: 30},
+		{name: "everything", cypherRoute: `{"active": "v1", "experiment": {"version": "v2", "percent": 100}}`, wantShare: 100},
+		{name: "idle experiment", cypherRoute: `{"active": "v2", "experiment": {"version": "v1", "percent": 0}}`, wantShare: 100},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePromptDir(t, dir, routingWithCypher(tc.cypherRoute), "v1", "v2")
+			store, err := loadPromptStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const requests = 2000
+			onV2 := 0
+			for i := 0; i < requests; i++ {
+				id := fmt.Sprintf("request-%d", i)
+				selected := store.Select(promptKindCypher, id)
+				if selected.Kind != promptKindCypher {
+					t.Fatalf("selected a %s template", selected.Kind)
+				}
+				// The same request always lands on the same side
+				if again := store.Select(promptKindCypher, id); again != selected {
+					t.Fatalf("%s: selected %s, then %s", id, selected.Version, again.Version)
+				}
+				if selected.Version == "v2" {
+					onV2++
+				}
+				// Kinds without an experiment stay on their active version
+				if answer := store.Select(promptKindAnswer, id); answer.Version != "v1" {
+					t.Fatalf("answer prompt %s selected", answer.Version)
+				}
+			}
+			share := onV2 * 100 / requests
+			if share < tc.wantShare-3 || share > tc.wantShare+3 {
+				t.Errorf("%d%% of requests on v2, want about %d%%", share, tc.wantShare)
+			}
+		})
+	}
+}
+
+func TestPromptStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	writePromptDir(t, dir, routingWithCypher(`{"active": "v1"}`), "v1", "v2")
+	store, err := loadPromptStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A valid change takes effect on reload
+	writePromptDir(t, dir, routingWithCypher(`{"active": "v2"}`))
+	if err := store.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := store.Select(promptKindCypher, "r1").Version; got != "v2" {
+		t.Fatalf("after reload cypher version = %s, want v2", got)
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(dir string)
+		wantErr string
+	}{
+		{
+			name:    "unknown active version",
+			setup:   func(dir string) { writePromptDir(t, dir, routingWithCypher(`{"active": "v9"}`)) },
+			wantErr: "active cypher prompt v9 not found",
+		},
+		{
+			name: "unknown experiment version",
+			setup: func(dir string) {
+				writePromptDir(t, dir, routingWithCypher(`{"active": "v1", "experiment": {"version": "v9", "percent": 10}}`))
+			},
+			wantErr: "experiment cypher prompt v9 not found",
+		},
+		{
+			name: "percent out of range",
+			setup: func(dir string) {
+				writePromptDir(t, dir, routingWithCypher(`{"active": "v1", "experiment": {"version": "v2", "percent": 150}}`))
+			},
+			wantErr: "between 0 and 100",
+		},
+		{
+			name: "kind without routing",
+			setup: func(dir string) {
+				writePromptDir(t, dir, `{"cypher": {"active": "v1"}, "answer": {"active": "v1"}, "agent": {"active": "v1"}}`)
+			},
+			wantErr: "no entry for repair",
+		},
+		{
+			name:    "invalid routing",
+			setup:   func(dir string) { writePromptDir(t, dir, `{"cypher": `) },
+			wantErr: "invalid prompt routing",
+		},
+		{
+			name: "template that does not parse",
+			setup: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "answer.v3.tmpl"), []byte("{{.Question"), 0644)
+			},
+			wantErr: "failed to parse prompt template",
+		},
+		{
+			name: "template without a version",
+			setup: func(dir string) {
+				os.WriteFile(filepath.Join(dir, "answer.tmpl"), []byte("{{.Question}}"), 0644)
+			},
+			wantErr: "must be named <kind>.<version>.tmpl",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writePromptDir(t, dir, routingWithCypher(`{"active": "v2"}`), "v1", "v2")
+			store, err := loadPromptStore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tc.setup(dir)
+			if err := store.Reload(); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tc.wantErr)
+			}
+			// The previous set stays active
+			selected := store.Select(promptKindCypher, "r1")
+			if selected.Version != "v2" {
+				t.Errorf("cypher version = %s after a failed reload, want v2", selected.Version)
+			}
+			if prompt, err := selected.Render(map[string]string{"Question": "Who is Thor?"}); err != nil || prompt != "cypher v2: Who is Thor?" {
+				t.Errorf("prompt = %q, %v", prompt, err)
+			}
+		})
+	}
+}
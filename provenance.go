@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Every node and relationship the loader writes records where it came from.
+// The dataset, file and row are set when it is created, so when two files
+// define the same node the first one loaded is its source; import_id is
+// set by every load that touches it.
+const (
+	provenanceDataset = "source_dataset"
+	provenanceFile    = "source_file"
+	provenanceRow     = "source_row"
+	provenanceImport  = "import_id"
+
+	provenanceRelationshipLimit = 100
+)
+
+// Provenance is where one node or relationship came from.
+type Provenance struct {
+	Dataset  string `json:"dataset"`
+	File     string `json:"file"`
+	Row      int64  `json:"row"`
+	ImportID string `json:"import_id"`
+}
+
+type RelationshipProvenance struct {
+	Relationship string     `json:"relationship"`
+	Direction    string     `json:"direction"`
+	Label        string     `json:"label"`
+	ID           string     `json:"id"`
+	Source       Provenance `json:"source"`
+}
+
+type EntityProvenance struct {
+	Label         string                   `json:"label"`
+	ID            string                   `json:"id"`
+	Placeholder   bool                     `json:"placeholder"`
+	Source        Provenance               `json:"source"`
+	Relationships []RelationshipProvenance `json:"relationships"`
+}
+
+// newImportID names one load; it sorts by load time.
+func newImportID() string {
+	return time.Now().UTC().Format("20060102T150405Z")
+}
+
+// datasetSource names a dataset file the way provenance records it: the
+// file relative to the datasets folder dir (marvel_teams/teams.csv) and the
+// dataset it belongs to, which is its folder, or the file name for
+// top-level files.
+func datasetSource(dir, path string) (dataset, file string) {
+	file = path
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	file = filepath.ToSlash(file)
+	if i := strings.Index(file, "/"); i >= 0 {
+		return file[:i], file
+	}
+	return strings.TrimSuffix(file, filepath.Ext(file)), file
+}
+
+// provenanceSet is the SET clause stamping a batch row's source on v.
+func provenanceSet(v string) string {
+	return fmt.Sprintf("%[1]s.%[2]s = row.dataset, %[1]s.%[3]s = row.file, %[1]s.%[4]s = row.row",
+		v, provenanceDataset, provenanceFile, provenanceRow)
+}
+
+func provenanceFrom(record map[string]interface{}) Provenance {
+	source := Provenance{}
+	source.Dataset, _ = record["dataset"].(string)
+	source.File, _ = record["file"].(string)
+	source.Row, _ = record["row"].(int64)
+	source.ImportID, _ = record["import_id"].(string)
+	return source
+}
+
+// entityProvenance returns where a node and up to provenanceRelationshipLimit
+// of its relationships came from. The bool is false when no such node exists.
+func entityProvenance(query GraphRecords, label, id string) (EntityProvenance, bool, error) {
+	if !labelPattern.MatchString(label) {
+		return EntityProvenance{}, false, fmt.Errorf("invalid label %q", label)
+	}
+
+	records, err := query(`MATCH (n:`+label+` {id: $id})
+RETURN n.`+provenanceDataset+` AS dataset, n.`+provenanceFile+` AS file, n.`+provenanceRow+` AS row,
+       n.`+provenanceImport+` AS import_id, n:Placeholder AS placeholder
+LIMIT 1`, map[string]interface{}{"id": id})
+	if err != nil || len(records) == 0 {
+		return EntityProvenance{}, false, err
+	}
+	entity := EntityProvenance{Label: label, ID: id, Source: provenanceFrom(records[0])}
+	entity.Placeholder, _ = records[0]["placeholder"].(bool)
+
+	records, err = query(`MATCH (n:`+label+` {id: $id})-[r]-(m)
+RETURN type(r) AS relationship, CASE WHEN startNode(r) = n THEN 'out' ELSE 'in' END AS direction,
+       labels(m)[0] AS label, m.id AS id, r.`+provenanceDataset+` AS dataset, r.`+provenanceFile+` AS file,
+       r.`+provenanceRow+` AS row, r.`+provenanceImport+` AS import_id
+ORDER BY file, row
+LIMIT $limit`, map[string]interface{}{"id": id, "limit": provenanceRelationshipLimit})
+	if err != nil {
+		return EntityProvenance{}, false, err
+	}
+	entity.Relationships = []RelationshipProvenance{}
+	for _, record := range records {
+		relationship := RelationshipProvenance{Source: provenanceFrom(record)}
+		relationship.Relationship, _ = record["relationship"].(string)
+		relationship.Direction, _ = record["direction"].(string)
+		relationship.Label, _ = record["label"].(string)
+		relationship.ID, _ = record["id"].(string)
+		entity.Relationships = append(entity.Relationships, relationship)
+	}
+	return entity, true, nil
+}
+
+// loadGraphSources lists the files each label and relationship type in the
+// graph was loaded from.
+func loadGraphSources(query GraphRecords) (map[string][]string, error) {
+	records, err := query(`MATCH (n) WHERE n.`+provenanceFile+` IS NOT NULL
+RETURN DISTINCT labels(n)[0] AS name, n.`+provenanceFile+` AS file
+UNION
+MATCH ()-[r]->() WHERE r.`+provenanceFile+` IS NOT NULL
+RETURN DISTINCT type(r) AS name, r.`+provenanceFile+` AS file`, nil)
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string][]string)
+	for _, record := range records {
+		name, _ := record["name"].(string)
+		file, _ := record["file"].(string)
+		sources[name] = append(sources[name], file)
+	}
+	for _, files := range sources {
+		sort.Strings(files)
+	}
+	return sources, nil
+}
+
+var (
+	cypherNamePattern   = regexp.MustCompile(`[:|]\s*([A-Za-z][A-Za-z0-9_]*)`)
+	cypherStringPattern = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+)
+
+// querySources returns the files behind the labels and relationship types a
+// query names, sorted.
+func querySources(cypherQuery string, sources map[string][]string) []string {
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, match := range cypherNamePattern.FindAllStringSubmatch(stripCypherStrings(cypherQuery), -1) {
+		for _, file := range sources[match[1]] {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// stripCypherStrings blanks out quoted literals so a name inside one, like
+// 'Spider-Man: Homecoming', is not read as a label.
+func stripCypherStrings(cypherQuery string) string {
+	return cypherStringPattern.ReplaceAllString(cypherQuery, "''")
+}
+
+// formatSources is the Sources line given to the answer prompt.
+func formatSources(files []string) string {
+	if len(files) == 0 {
+		return "unknown"
+	}
+	return strings.Join(files, ", ")
+}
+
+func handleEntityProvenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entity, found, err := entityProvenance(fetchGraphRecords(ns.Name), r.PathValue("label"), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !found {
+		http.Error(w, "Entity not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity)
+}
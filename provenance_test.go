@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDatasetSource(t *testing.T) {
+	tests := []struct {
+		dir, path, dataset, file string
+	}{
+		{datasetDir, "dataset/marvel_characters_partnerships/edges.csv", "marvel_characters_partnerships", "marvel_characters_partnerships/edges.csv"},
+		{datasetDir, "dataset/extra.jsonl", "extra", "extra.jsonl"},
+		{namespaceDatasetDir("experiments"), "namespaces/experiments/lab/edges.csv", "lab", "lab/edges.csv"},
+		{datasetDir, "elsewhere/people.csv", "elsewhere", "elsewhere/people.csv"},
+	}
+	for _, tc := range tests {
+		dataset, file := datasetSource(tc.dir, tc.path)
+		if dataset != tc.dataset || file != tc.file {
+			t.Errorf("%s: got %q, %q", tc.path, dataset, file)
+		}
+	}
+}
+
+func TestGraphWriterProvenance(t *testing.T) {
+	var statements []string
+	var params []map[string]interface{}
+	writer := newGraphWriter(func(cypherQuery string, p map[string]interface{}) error {
+		statements = append(statements, cypherQuery)
+		params = append(params, p)
+		return nil
+	})
+	writer.importID = "20261018T120000Z"
+	writer.dataset, writer.source = datasetSource(datasetDir, "dataset/marvel_characters_partnerships/edges.csv")
+	writer.dangling = danglingPlaceholder
+
+	writer.phase = importPhaseNodes
+	writer.Node(ImportNode{Label: "Character", ID: "Thor", Row: 4})
+	writer.phase = importPhaseEdges
+	writer.Edge(ImportEdge{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Thor", TargetLabel: "Character", Target: "Sif", Row: 7})
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statements) != 3 {
+		t.Fatalf("statements = %q", statements)
+	}
+	for i, statement := range statements {
+		if !strings.Contains(statement, "ON CREATE SET") || !strings.Contains(statement, ".source_file = row.file") || params[i]["import_id"] != "20261018T120000Z" {
+			t.Errorf("statement %d = %q, params %v", i, statement, params[i])
+		}
+	}
+	wantRows := []map[string]interface{}{
+		{"dataset": "marvel_characters_partnerships", "file": "marvel_characters_partnerships/edges.csv", "row": 4},
+		// The placeholder is stamped with the edge that needed it
+		{"dataset": "marvel_characters_partnerships", "file": "marvel_characters_partnerships/edges.csv", "row": 7},
+		{"dataset": "marvel_characters_partnerships", "file": "marvel_characters_partnerships/edges.csv", "row": 7},
+	}
+	for i, want := range wantRows {
+		row := params[i]["rows"].([]interface{})[0].(map[string]interface{})
+		for key, value := range want {
+			if row[key] != value {
+				t.Errorf("statement %d: row[%s] = %v, want %v", i, key, row[key], value)
+			}
+		}
+	}
+}
+
+func TestQuerySources(t *testing.T) {
+	sources := map[string][]string{
+		"Character":        {"marvel_characters_partnerships/nodes.csv"},
+		"PARTNERS_WITH":    {"marvel_characters_partnerships/edges.csv"},
+		"Movie":            {"marvel_movies/movies.csv"},
+		"APPEARS_IN_MOVIE": {"marvel_movies/appearances.csv"},
+		"Team":             {"marvel_teams/teams.csv"},
+	}
+	tests := []struct {
+		cypher string
+		want   []string
+	}{
+		{
+			cypher: "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]-(p:Character) RETURN p.id",
+			want:   []string{"marvel_characters_partnerships/edges.csv", "marvel_characters_partnerships/nodes.csv"},
+		},
+		{
+			// A colon inside a string is not a label
+			cypher: "MATCH (c:Character)-[:APPEARS_IN_MOVIE]->(m {id: 'Spider-Man: Homecoming'}) RETURN c.id",
+			want:   []string{"marvel_characters_partnerships/nodes.csv", "marvel_movies/appearances.csv"},
+		},
+		{cypher: "MATCH (n) RETURN count(n)", want: []string{}},
+	}
+	for _, tc := range tests {
+		if got := querySources(tc.cypher, sources); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.cypher, got, tc.want)
+		}
+	}
+}
+
+func TestEntityProvenance(t *testing.T) {
+	graph := &stubGraph{rows: map[string][]map[string]interface{}{
+		"n:Placeholder AS placeholder": {{"dataset": "marvel_characters_partnerships", "file": "marvel_characters_partnerships/nodes.csv", "row": int64(12), "import_id": "20261018T120000Z", "placeholder": false}},
+		"-[r]-(m)": {{"relationship": "PARTNERS_WITH", "direction": "out", "label": "Character", "id": "Hulk",
+			"dataset": "marvel_characters_partnerships", "file": "marvel_characters_partnerships/edges.csv", "row": int64(40), "import_id": "20261018T120000Z"}},
+	}}
+	entity, found, err := entityProvenance(graph.query, "Character", "Thor")
+	if err != nil || !found {
+		t.Fatalf("found = %v, err = %v", found, err)
+	}
+	if entity.Source != (Provenance{Dataset: "marvel_characters_partnerships", File: "marvel_characters_partnerships/nodes.csv", Row: 12, ImportID: "20261018T120000Z"}) {
+		t.Errorf("source = %+v", entity.Source)
+	}
+	if len(entity.Relationships) != 1 || entity.Relationships[0].ID != "Hulk" || entity.Relationships[0].Source.Row != 40 {
+		t.Errorf("relationships = %+v", entity.Relationships)
+	}
+
+	if _, _, err := entityProvenance(graph.query, "Bad Label", "Thor"); err == nil {
+		t.Error("expected an invalid label error")
+	}
+}
@@ -3,14 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
 )
 
 func startRAGChatbot() {
@@ -22,13 +24,29 @@ func startRAGChatbot() {
 	defer driver.Close()
 
 	// Initialize LLM for query generation
-	llm, err := ollama.New(ollama.WithModel("llama3.2"))
+	llm, err := newLLM(llmConfigFromEnv(llmRoleCypher))
 	if err != nil {
 		log.Fatalf("Failed to create LLM: %v", err)
 	}
 
 	// Get graph schema for context
-	schema := getGraphSchema(driver)
+	symmetric, err := loadSymmetricRelationships(datasetDir)
+	if err != nil {
+		log.Fatalf("Failed to read dataset manifest: %v", err)
+	}
+	ns := &GraphNamespace{Symmetric: symmetric, Schema: getGraphSchema(driver, "", symmetric) + namespaceNotes("")}
+
+	// Load few-shot example library
+	library, err := loadExampleLibrary(exampleLibraryPath)
+	if err != nil {
+		log.Fatalf("Failed to load example library: %v", err)
+	}
+
+	// Load prompt templates
+	prompts, err := loadPromptStore(promptDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
 
 	// Interactive chat loop
 	fmt.Println("🤖 Marvel Comics RAG Chatbot (LLM-Powered)")
@@ -49,22 +67,31 @@ func startRAGChatbot() {
 		}
 
 		// Generate Cypher query using LLM
-		cypherQuery, err := generateCypherQuery(llm, userInput, schema)
+		examples := library.TopK(userInput, fewShotExampleCount)
+		cypherQuery, _, err := generateCypherQuery(llm, prompts.Select(promptKindCypher, ""), userInput, ns, examples)
 		if err != nil {
 			fmt.Printf("❌ Error generating query: %v\n", err)
 			continue
 		}
 
-		fmt.Printf("🔍 Generated Cypher query:\n%s\n", cypherQuery)
+		fmt.Printf("🔍 Generated Cypher query:\n%s\n", cypherQuery.Cypher)
+		if len(cypherQuery.Params) > 0 {
+			fmt.Printf("🔧 Parameters: %v\n", cypherQuery.Params)
+		}
 
 		// Execute query and get results
-		results := executeQuery(driver, cypherQuery)
+		results := executeQuery(driver, "", cypherQuery.Cypher, cypherQuery.Params)
 		fmt.Printf("📊 Results:\n%s\n\n", results)
 	}
 }
 
-func getGraphSchema(driver neo4j.Driver) string {
-	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+const graphSchemaUnavailable = "Graph schema unavailable"
+
+// getGraphSchema describes a namespace's database for the generator.
+func getGraphSchema(driver neo4j.Driver, database string, symmetric map[string]bool) string {
+	// On a shared database the procedures list every namespace's names
+	scope := namespaceScope(driver, database)
+	session := namespaceSession(driver, database, neo4j.AccessModeRead)
 	defer session.Close()
 
 	// Get node labels and their properties
@@ -73,7 +100,7 @@ func getGraphSchema(driver neo4j.Driver) string {
 		RETURN collect(label) as labels
 	`, nil)
 	if err != nil {
-		return "Graph schema unavailable"
+		return graphSchemaUnavailable
 	}
 
 	var labels []string
@@ -82,7 +109,10 @@ func getGraphSchema(driver neo4j.Driver) string {
 		if len(record.Values) > 0 {
 			labelsInterface := record.Values[0].([]interface{})
 			for _, label := range labelsInterface {
-				labels = append(labels, label.(string))
+				// The migration log is not part of the graph
+				if name, own := scope.ownName(label.(string)); own && name != "SchemaMigration" {
+					labels = append(labels, name)
+				}
 			}
 		}
 	}
@@ -93,7 +123,7 @@ func getGraphSchema(driver neo4j.Driver) string {
 		RETURN collect(relationshipType) as relationships
 	`, nil)
 	if err != nil {
-		return "Graph schema unavailable"
+		return graphSchemaUnavailable
 	}
 
 	var relationships []string
@@ -102,94 +132,248 @@ func getGraphSchema(driver neo4j.Driver) string {
 		if len(record.Values) > 0 {
 			relationshipsInterface := record.Values[0].([]interface{})
 			for _, rel := range relationshipsInterface {
-				relationships = append(relationships, rel.(string))
+				if name, own := scope.ownName(rel.(string)); own {
+					relationships = append(relationships, name)
+				}
 			}
 		}
 	}
+	// A namespace without labels of its own on a shared database was never
+	// loaded, as one without a database
+	if scope.Prefix != "" && len(labels) == 0 {
+		return graphSchemaUnavailable
+	}
 
-	return fmt.Sprintf("Node labels: %v, Relationship types: %v", labels, relationships)
+	// Date and year properties, so questions can be filtered by time
+	temporal, err := temporalSchema(func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+		records, err := queryRecords(driver, database, cypherQuery, params, graphRecordLimit)
+		for _, record := range records {
+			record["owners"] = scope.ownOwners(record["owners"])
+		}
+		return records, err
+	})
+	if err != nil {
+		log.Printf("Could not read temporal properties: %v", err)
+	}
+
+	return fmt.Sprintf("Node labels: %v, Relationship types: %v", labels, relationships) + describeSymmetric(symmetric) + describeTemporal(temporal)
 }
 
-func generateCypherQuery(llm llms.Model, userQuery, schema string) (string, error) {
-	prompt := fmt.Sprintf(`You are a Cypher query generator for a Neo4j Marvel Comics knowledge graph.
+// describeSymmetric tells the generator which relationship types must be
+// matched without an arrow.
+func describeSymmetric(symmetric map[string]bool) string {
+	if len(symmetric) == 0 {
+		return ""
+	}
+	types := make([]string, 0, len(symmetric))
+	for relType := range symmetric {
+		types = append(types, relType)
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("\nSymmetric relationship types: %v. Each pair is stored once in either direction, so ALWAYS match them without an arrow, e.g. (a)-[:%s]-(b)", types, types[0])
+}
 
-Graph Schema:
-%s
+type CypherQuery struct {
+	Cypher string                 `json:"cypher"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
 
-CRITICAL DATA STRUCTURE:
-- Character nodes: (c:Character {id: string, name: string, group: string, size: int})
-- Hero nodes: (h:Hero {id: string, name: string})
-- Comic nodes: (c:Comic {id: string, title: string})
-- Relationships: (c1:Character)-[:PARTNERS_WITH]->(c2:Character), (h1:Hero)-[:KNOWS]->(h2:Hero), (h:Hero)-[:APPEARS_IN]->(c:Comic)
+// generateCypherQuery also returns the model's completion, so a query that
+// fails validation can be sent back for repair; it is empty when the model
+// could not be reached.
+func generateCypherQuery(llm llms.Model, promptTemplate *PromptTemplate, userQuery string, ns *GraphNamespace, examples []CypherExample) (CypherQuery, string, error) {
+	prompt, err := promptTemplate.Render(map[string]interface{}{
+		"Schema":   ns.Schema,
+		"Question": userQuery,
+		"Examples": formatExamples(examples),
+	})
+	if err != nil {
+		return CypherQuery{}, "", err
+	}
+	return completeCypher(llm, prompt, ns.Symmetric)
+}
 
-MANDATORY RULES - FOLLOW EXACTLY:
-1. ALWAYS use c.id, h.id, c.id for ALL property access
-2. NEVER use c.name, h.name, c.title
-3. Use single quotes for strings: 'Iron Man'
-4. Use EXACT matches: {id: 'Character Name'} or WHERE c.id IN ['Name1', 'Name2']
-5. NEVER use toLower() or CONTAINS - only exact matches
-6. Always include LIMIT 10
-7. Return a single string column named 'result'
-8. Keep queries SIMPLE - avoid complex logic
-9. For counting: use WITH count(*) as count, then toString(count) in RETURN
-10. NEVER use colons in RETURN strings - use + for concatenation
+// repairCypherQuery asks the model to fix a query that failed validation or
+// execution, given the query and what was wrong with it.
+func repairCypherQuery(llm llms.Model, promptTemplate *PromptTemplate, userQuery string, ns *GraphNamespace, failed, problem string) (CypherQuery, string, error) {
+	prompt, err := promptTemplate.Render(map[string]interface{}{
+		"Schema":   ns.Schema,
+		"Question": userQuery,
+		"Failed":   failed,
+		"Problem":  problem,
+	})
+	if err != nil {
+		return CypherQuery{}, "", err
+	}
+	return completeCypher(llm, prompt, ns.Symmetric)
+}
 
-User Question: "%s"
+func completeCypher(llm llms.Model, prompt string, symmetric map[string]bool) (CypherQuery, string, error) {
+	ctx := context.Background()
+	response, err := llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	})
+	if err != nil {
+		return CypherQuery{}, "", fmt.Errorf("LLM generation failed: %v", err)
+	}
 
-Choose the appropriate pattern and return ONLY the Cypher query:
+	if len(response.Choices) == 0 {
+		return CypherQuery{}, "", fmt.Errorf("empty response from LLM")
+	}
 
-For character partnerships (like "who are spider-man's partners?"):
-MATCH (c:Character {id: 'Spider-Man'}) OPTIONAL MATCH (c)-[:PARTNERS_WITH]->(partner:Character) WITH c, collect(DISTINCT partner.id) as partners RETURN 'Character: ' + c.id + ', Partners: ' + partners as result LIMIT 10
+	completion := response.Choices[0].Content
+	query, err := parseGeneratedCypher(completion, symmetric)
+	return query, completion, err
+}
 
-For Avengers teammates (like "which avengers have fought together?"):
-MATCH (c1:Character)-[:PARTNERS_WITH]->(c2:Character) WHERE c1.id IN ['Iron Man', 'Captain America', 'Thor', 'Hulk', 'Black Widow', 'Hawkeye'] AND c2.id IN ['Iron Man', 'Captain America', 'Thor', 'Hulk', 'Black Widow', 'Hawkeye'] RETURN 'Avengers teammates: ' + c1.id + ' and ' + c2.id as result LIMIT 10
+// parseGeneratedCypher accepts either a {"cypher": ..., "params": ...} object
+// or a bare query, and checks that every $placeholder has a value and that
+// symmetric relationship types are matched without an arrow.
+func parseGeneratedCypher(completion string, symmetric map[string]bool) (CypherQuery, error) {
+	completion = stripCodeFences(completion)
+
+	query := CypherQuery{Cypher: completion}
+	if strings.HasPrefix(completion, "{") {
+		decoder := json.NewDecoder(strings.NewReader(completion))
+		decoder.UseNumber()
+		if err := decoder.Decode(&query); err != nil {
+			return CypherQuery{}, fmt.Errorf("generated query is not valid JSON: %v", err)
+		}
+		query.Cypher = strings.TrimSpace(query.Cypher)
+		for name, value := range query.Params {
+			query.Params[name] = normalizeParamValue(value)
+		}
+	}
 
-For exact character match (like "who are iron man's partners?"):
-MATCH (c:Character {id: 'Iron Man'}) OPTIONAL MATCH (c)-[:PARTNERS_WITH]->(partner:Character) WITH c, collect(DISTINCT partner.id) as partners RETURN 'Character: ' + c.id + ', Partners: ' + partners as result LIMIT 10
+	// Basic validation - ensure it's a Cypher query
+	if !strings.Contains(strings.ToUpper(query.Cypher), "MATCH") {
+		return CypherQuery{}, fmt.Errorf("generated query doesn't contain MATCH clause")
+	}
 
-For character search (like "find spider-man"):
-MATCH (c:Character {id: 'Spider-Man'}) RETURN 'Character: ' + c.id + ', Group: ' + c.group as result LIMIT 10
+	if err := validateCypherParams(query.Cypher, query.Params); err != nil {
+		return CypherQuery{}, err
+	}
+	if err := validateRelationshipDirections(query.Cypher, symmetric); err != nil {
+		return CypherQuery{}, err
+	}
 
-For counting relationships (like "how many does X know?"):
-MATCH (h:Hero {id: 'Human Robot'})-[:KNOWS]->(other:Hero) WITH count(other) as count RETURN 'Human Robot knows ' + toString(count) + ' heroes' as result LIMIT 10
+	return query, nil
+}
 
-For counting partnerships (like "how many avengers partnerships?"):
-MATCH (c1:Character)-[:PARTNERS_WITH]->(c2:Character) WHERE c1.id IN ['Iron Man', 'Captain America', 'Thor', 'Hulk', 'Black Widow', 'Hawkeye'] AND c2.id IN ['Iron Man', 'Captain America', 'Thor', 'Hulk', 'Black Widow', 'Hawkeye'] WITH count(*) as count RETURN 'There are ' + toString(count) + ' Avengers partnerships' as result LIMIT 10
+func stripCodeFences(completion string) string {
+	completion = strings.TrimSpace(completion)
+	completion = strings.TrimPrefix(completion, "```json")
+	completion = strings.TrimPrefix(completion, "```cypher")
+	completion = strings.TrimPrefix(completion, "```")
+	return strings.TrimSpace(strings.TrimSuffix(completion, "```"))
+}
 
-For cross-team partnerships (like "how many avengers are partners with Spider-Man?"):
-MATCH (c1:Character)-[:PARTNERS_WITH]->(c2:Character) WHERE c1.id IN ['Iron Man', 'Captain America', 'Thor', 'Hulk', 'Black Widow', 'Hawkeye'] AND c2.id = 'Spider-Man' WITH count(c1) as count RETURN 'There are ' + toString(count) + ' Avengers partnered with Spider-Man' as result LIMIT 10
+// normalizeParamValue turns JSON numbers into int64 or float64 so Neo4j gets
+// integers where the query expects them (LIMIT $n, list indexes).
+func normalizeParamValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeParamValue(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeParamValue(v[key])
+		}
+		return v
+	}
+	return value
+}
 
-Only return the Cypher query, nothing else.`, schema, userQuery)
+var (
+	directedRelationshipPattern = regexp.MustCompile(`<-\[([^\]]*)\]-|-\[([^\]]*)\]->`)
+	relationshipTypesPattern    = regexp.MustCompile(`:\s*([A-Za-z_][A-Za-z0-9_]*(?:\s*\|\s*:?\s*[A-Za-z_][A-Za-z0-9_]*)*)`)
+	cypherLiteralPattern        = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"|` + "`[^`]*`" + `|//[^\n]*`)
+	cypherPlaceholderPattern    = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+)
 
-	ctx := context.Background()
-	response, err := llm.GenerateContent(ctx, []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
-	})
-	if err != nil {
-		return "", fmt.Errorf("LLM generation failed: %v", err)
+// cypherPlaceholders lists the $parameters used outside string literals,
+// quoted identifiers and comments.
+func cypherPlaceholders(cypherQuery string) []string {
+	stripped := cypherLiteralPattern.ReplaceAllString(cypherQuery, " ")
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range cypherPlaceholderPattern.FindAllStringSubmatch(stripped, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
 	}
+	return names
+}
 
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("empty response from LLM")
+func validateCypherParams(cypherQuery string, params map[string]interface{}) error {
+	var unbound []string
+	for _, name := range cypherPlaceholders(cypherQuery) {
+		if _, ok := params[name]; !ok {
+			unbound = append(unbound, "$"+name)
+		}
 	}
+	if len(unbound) > 0 {
+		return fmt.Errorf("generated query has unbound parameters: %s", strings.Join(unbound, ", "))
+	}
+	return nil
+}
 
-	cypherQuery := strings.TrimSpace(response.Choices[0].Content)
-
-	// Basic validation - ensure it's a Cypher query
-	if !strings.Contains(strings.ToUpper(cypherQuery), "MATCH") {
-		return "", fmt.Errorf("generated query doesn't contain MATCH clause")
+// validateRelationshipDirections rejects arrows on symmetric relationship
+// types, which would only find the pairs stored in that direction.
+func validateRelationshipDirections(cypherQuery string, symmetric map[string]bool) error {
+	if len(symmetric) == 0 {
+		return nil
 	}
+	stripped := cypherLiteralPattern.ReplaceAllString(cypherQuery, " ")
+	for _, match := range directedRelationshipPattern.FindAllStringSubmatch(stripped, -1) {
+		types := relationshipTypesPattern.FindStringSubmatch(match[1] + match[2])
+		if types == nil {
+			continue
+		}
+		for _, relType := range strings.Split(types[1], "|") {
+			relType = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(relType), ":"))
+			if symmetric[relType] {
+				return fmt.Errorf("generated query gives a direction to symmetric relationship %s: match it without an arrow, as -[:%s]-", relType, relType)
+			}
+		}
+	}
+	return nil
+}
 
-	return cypherQuery, nil
+// describeCypher renders a query with its parameter values for prompts and logs.
+func describeCypher(query CypherQuery) string {
+	if len(query.Params) == 0 {
+		return query.Cypher
+	}
+	params, _ := json.Marshal(query.Params)
+	return fmt.Sprintf("%s (parameters: %s)", query.Cypher, params)
 }
 
-func executeQuery(driver neo4j.Driver, cypherQuery string) string {
-	session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+const (
+	queryErrorPrefix = "❌ Query execution error"
+	noResultsMessage = "❌ No results found."
+
+	// A question's rejected queries are sent back to the model at most this
+	// many times in all
+	maxRepairAttempts = 2
+)
+
+func executeQuery(driver neo4j.Driver, database, cypherQuery string, params map[string]interface{}) string {
+	session := namespaceSession(driver, database, neo4j.AccessModeRead)
 	defer session.Close()
 
-	result, err := session.Run(cypherQuery, nil)
+	result, err := session.Run(cypherQuery, params)
 	if err != nil {
-		return fmt.Sprintf("❌ Query execution error: %v", err)
+		return fmt.Sprintf("%s: %v", queryErrorPrefix, err)
 	}
 
 	var results []string
@@ -202,8 +386,96 @@ func executeQuery(driver neo4j.Driver, cypherQuery string) string {
 	}
 
 	if len(results) == 0 {
-		return "❌ No results found."
+		return noResultsMessage
 	}
 
 	return strings.Join(results, "\n")
 }
+
+// queryRecords runs a read query in database ("" for the home database) and
+// returns up to maxRows records as maps, with temporal values as ISO strings.
+func queryRecords(driver neo4j.Driver, database, cypherQuery string, params map[string]interface{}, maxRows int) ([]map[string]interface{}, error) {
+	session := namespaceSession(driver, database, neo4j.AccessModeRead)
+	defer session.Close()
+
+	result, err := session.Run(cypherQuery, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]interface{}
+	for len(records) < maxRows && result.Next() {
+		records = append(records, graphValue(result.Record().AsMap()).(map[string]interface{}))
+	}
+	return records, result.Err()
+}
+
+// streamRecords runs a read query and hands each record to emit as it is
+// read, stopping at the first error emit returns.
+func streamRecords(driver neo4j.Driver, database, cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+	return streamDriverRecords(driver, database, cypherQuery, params, func(keys []string, values []interface{}) error {
+		return emit(keys, graphValue(values).([]interface{}))
+	})
+}
+
+// streamDriverRecords is streamRecords with values left as the driver
+// returns them, for writers that keep temporal types.
+func streamDriverRecords(driver neo4j.Driver, database, cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+	session := namespaceSession(driver, database, neo4j.AccessModeRead)
+	defer session.Close()
+
+	result, err := session.Run(cypherQuery, params)
+	if err != nil {
+		return err
+	}
+	for result.Next() {
+		record := result.Record()
+		if err := emit(record.Keys, record.Values); err != nil {
+			return err
+		}
+	}
+	return result.Err()
+}
+
+var (
+	cypherWordPattern       = regexp.MustCompile(`[$.:]?[A-Za-z_][A-Za-z0-9_]*`)
+	cypherWhitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// cypherKeywords are the case-insensitive words normalizeCypher folds.
+var cypherKeywords = map[string]bool{
+	"MATCH": true, "OPTIONAL": true, "WHERE": true, "RETURN": true, "WITH": true, "AS": true,
+	"DISTINCT": true, "ORDER": true, "BY": true, "ASC": true, "ASCENDING": true, "DESC": true,
+	"DESCENDING": true, "SKIP": true, "LIMIT": true, "UNWIND": true, "UNION": true, "ALL": true,
+	"CALL": true, "YIELD": true, "AND": true, "OR": true, "XOR": true, "NOT": true, "IN": true,
+	"IS": true, "NULL": true, "TRUE": true, "FALSE": true, "STARTS": true, "ENDS": true,
+	"CONTAINS": true, "CASE": true, "WHEN": true, "THEN": true, "ELSE": true, "END": true,
+	"EXISTS": true,
+}
+
+// normalizeCypher collapses whitespace, keyword case and a trailing semicolon
+// so equivalent spellings of a query compare equal. String literals, quoted
+// identifiers, labels, properties and parameters are kept as written, since
+// 'Hulk' and 'hulk' match different nodes.
+func normalizeCypher(cypher string) string {
+	cypher = strings.TrimSuffix(strings.TrimSpace(cypher), ";")
+	var sb strings.Builder
+	last := 0
+	for _, loc := range cypherLiteralPattern.FindAllStringIndex(cypher, -1) {
+		sb.WriteString(normalizeCypherWords(cypher[last:loc[0]]))
+		sb.WriteString(cypher[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	sb.WriteString(normalizeCypherWords(cypher[last:]))
+	return strings.TrimSpace(sb.String())
+}
+
+func normalizeCypherWords(segment string) string {
+	segment = cypherWhitespacePattern.ReplaceAllString(segment, " ")
+	return cypherWordPattern.ReplaceAllStringFunc(segment, func(word string) string {
+		if cypherKeywords[strings.ToUpper(word)] {
+			return strings.ToUpper(word)
+		}
+		return word
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCypherPlaceholders(t *testing.T) {
+	tests := []struct {
+		cypher string
+		want   []string
+	}{
+		{"MATCH (c {id: $name}) WHERE c.size > $min RETURN c LIMIT $limit", []string{"name", "min", "limit"}},
+		{"MATCH (c {id: $name}) WHERE c.id <> $name RETURN c", []string{"name"}},
+		{"MATCH (c {id: 'costs $5'}) RETURN \"$notParam\" // $comment", nil},
+		{"MATCH (c {id: 'S\\'ym'}) RETURN c.id + $suffix", []string{"suffix"}},
+	}
+	for _, tc := range tests {
+		if got := cypherPlaceholders(tc.cypher); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("cypherPlaceholders(%q) = %v, want %v", tc.cypher, got, tc.want)
+		}
+	}
+}
+
+func TestValidateRelationshipDirections(t *testing.T) {
+	symmetric := map[string]bool{"PARTNERS_WITH": true, "KNOWS": true}
+	tests := []struct {
+		cypher  string
+		wantErr string
+	}{
+		{"MATCH (c {id: $name})-[:PARTNERS_WITH]-(p) RETURN p.id", ""},
+		{"MATCH (h:Hero)-[:APPEARS_IN]->(c:Comic) RETURN c.id", ""},
+		{"MATCH (c {id: $name})-[r:PARTNERS_WITH]->(p) RETURN p.id", "symmetric relationship PARTNERS_WITH"},
+		{"MATCH (a)<-[:APPEARS_IN|:KNOWS*1..2]-(b) RETURN b.id", "symmetric relationship KNOWS"},
+		{"MATCH (c {id: '-[:KNOWS]->'})-[]->(p) RETURN p.id", ""},
+	}
+	for _, tc := range tests {
+		err := validateRelationshipDirections(tc.cypher, symmetric)
+		if tc.wantErr == "" && err != nil || tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("validateRelationshipDirections(%q) = %v, want %q", tc.cypher, err, tc.wantErr)
+		}
+	}
+
+	if got := describeSymmetric(symmetric); !strings.Contains(got, "[KNOWS PARTNERS_WITH]") || !strings.Contains(got, "(a)-[:KNOWS]-(b)") {
+		t.Errorf("describeSymmetric = %q", got)
+	}
+}
+
+func testExamples(t *testing.T) []CypherExample {
+	t.Helper()
+	library, err := loadExampleLibrary("testdata/examples.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return library.All()
+}
+
+func testPromptStore(t *testing.T) *PromptStore {
+	t.Helper()
+	store, err := loadPromptStore(promptDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestGenerateCypherQuery(t *testing.T) {
+	prompts := testPromptStore(t)
+
+	tests := []struct {
+		name       string
+		question   string
+		completion string
+		want       CypherQuery
+		wantErr    string
+	}{
+		{
+			name:       "parameterized",
+			question:   "Who are Thor's partners?",
+			completion: "```json\n{\"cypher\": \"MATCH (c:Character {id: $name}) OPTIONAL MATCH (c)-[:PARTNERS_WITH]->(p:Character) RETURN p.id as result LIMIT $limit\", \"params\": {\"name\": \"Thor\", \"limit\": 10}}\n```",
+			want: CypherQuery{
+				Cypher: "MATCH (c:Character {id: $name}) OPTIONAL MATCH (c)-[:PARTNERS_WITH]->(p:Character) RETURN p.id as result LIMIT $limit",
+				Params: map[string]interface{}{"name": "Thor", "limit": int64(10)},
+			},
+		},
+		{
+			name:       "quoted name stays out of the query",
+			question:   "Who is N'astirh partnered with?",
+			completion: `{"cypher": "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10", "params": {"name": "N'astirh"}}`,
+			want: CypherQuery{
+				Cypher: "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10",
+				Params: map[string]interface{}{"name": "N'astirh"},
+			},
+		},
+		{
+			name:       "bare query without parameters",
+			question:   "Find Spider-Man",
+			completion: "  MATCH (c:Character {id: 'Spider-Man'}) RETURN c.id as result LIMIT 10\n",
+			want:       CypherQuery{Cypher: "MATCH (c:Character {id: 'Spider-Man'}) RETURN c.id as result LIMIT 10"},
+		},
+		{
+			name:       "unbound placeholder",
+			question:   "Who are Hulk's partners?",
+			completion: `{"cypher": "MATCH (c:Character {id: $name}) RETURN c.id as result LIMIT $limit", "params": {"name": "Hulk"}}`,
+			wantErr:    "unbound parameters: $limit",
+		},
+		{
+			name:       "not cypher",
+			question:   "Tell me a joke",
+			completion: "Why did Deadpool cross the road?",
+			wantErr:    "doesn't contain MATCH",
+		},
+		{
+			name:     "model unavailable",
+			question: "Who knows Wolverine?",
+			wantErr:  "LLM generation failed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			model := fixtureModel(t, tc.completion)
+			got, _, err := generateCypherQuery(model, prompts.Select(promptKindCypher, ""), tc.question, &GraphNamespace{Schema: "test schema"}, testExamples(t))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,883 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const defaultRDFMappingPath = "rdf/mapping.json"
+
+const (
+	rdfType     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+	xsdPrefix   = "http://www.w3.org/2001/XMLSchema#"
+	xsdString   = xsdPrefix + "string"
+	xsdInteger  = xsdPrefix + "integer"
+	xsdDecimal  = xsdPrefix + "decimal"
+	xsdDouble   = xsdPrefix + "double"
+	xsdBoolean  = xsdPrefix + "boolean"
+	xsdDate     = xsdPrefix + "date"
+	xsdDateTime = xsdPrefix + "dateTime"
+	xsdTime     = xsdPrefix + "time"
+)
+
+// RDFMapping ties RDF classes and predicates to labels, relationship types
+// and properties. Each list is ordered: on export the first IRI mapped to a
+// label, type or property is the one written; on import all of them are
+// recognized.
+type RDFMapping struct {
+	// Nodes get the IRI <base><lowercase label>/<escaped id>
+	Base          string            `json:"base"`
+	Prefixes      map[string]string `json:"prefixes"`
+	Classes       []RDFTerm         `json:"classes"`
+	Relationships []RDFTerm         `json:"relationships"`
+	Properties    []RDFTerm         `json:"properties"`
+
+	// IRIs with prefixes expanded
+	classes, relationships, properties map[string]string
+}
+
+type RDFTerm struct {
+	IRI  string `json:"iri"`
+	Name string `json:"name"`
+}
+
+func loadRDFMapping(path string) (*RDFMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RDF mapping: %v", err)
+	}
+	mapping := &RDFMapping{}
+	if err := json.Unmarshal(content, mapping); err != nil {
+		return nil, fmt.Errorf("invalid RDF mapping %s: %v", path, err)
+	}
+	if mapping.Base == "" {
+		return nil, fmt.Errorf("RDF mapping %s has no base IRI", path)
+	}
+
+	index := func(terms []RDFTerm, pattern *regexp.Regexp, kind string) (map[string]string, error) {
+		names := make(map[string]string)
+		for _, term := range terms {
+			if !pattern.MatchString(term.Name) {
+				return nil, fmt.Errorf("RDF mapping %s: invalid %s %q", path, kind, term.Name)
+			}
+			names[mapping.expand(term.IRI)] = term.Name
+		}
+		return names, nil
+	}
+	if mapping.classes, err = index(mapping.Classes, labelPattern, "label"); err != nil {
+		return nil, err
+	}
+	if mapping.relationships, err = index(mapping.Relationships, relationshipTypePattern, "relationship type"); err != nil {
+		return nil, err
+	}
+	if mapping.properties, err = index(mapping.Properties, labelPattern, "property"); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// expand turns a prefixed name such as schema:name into a full IRI.
+func (m *RDFMapping) expand(name string) string {
+	if prefix, local, ok := strings.Cut(name, ":"); ok {
+		if namespace, ok := m.Prefixes[prefix]; ok {
+			return namespace + local
+		}
+	}
+	return name
+}
+
+// nodeIRI is a node's stable IRI, derived from its label and id.
+func (m *RDFMapping) nodeIRI(label, id string) string {
+	return m.Base + strings.ToLower(label) + "/" + url.PathEscape(id)
+}
+
+// nodeID recovers the id from an IRI made by nodeIRI; any other IRI is its
+// own id.
+func (m *RDFMapping) nodeID(iri string) string {
+	if rest, ok := strings.CutPrefix(iri, m.Base); ok {
+		if _, escaped, ok := strings.Cut(rest, "/"); ok {
+			if id, err := url.PathUnescape(escaped); err == nil {
+				return id
+			}
+		}
+	}
+	return iri
+}
+
+func (m *RDFMapping) hasProperty(name string) bool {
+	for _, term := range m.Properties {
+		if term.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// exportIRI is the IRI written for a label, relationship type or property:
+// the first mapped one, or one in the base vocabulary.
+func (m *RDFMapping) exportIRI(terms []RDFTerm, name string) string {
+	for _, term := range terms {
+		if term.Name == name {
+			return m.expand(term.IRI)
+		}
+	}
+	return m.Base + "vocab/" + name
+}
+
+// importName is the label, relationship type or property an IRI stands for:
+// the mapped name, or, for an IRI in the base vocabulary, its local name, so
+// names exportIRI wrote without a mapping read back as they were.
+func (m *RDFMapping) importName(mapped map[string]string, pattern *regexp.Regexp, iri string) (string, bool) {
+	if name, ok := mapped[iri]; ok {
+		return name, true
+	}
+	name, ok := strings.CutPrefix(iri, m.Base+"vocab/")
+	return name, ok && pattern.MatchString(name)
+}
+
+// exportLabels orders a node's labels for export: Placeholder, which marks a
+// stand-in rather than saying what the node is, never comes first.
+func exportLabels(labels []string) []string {
+	ordered := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label != "Placeholder" {
+			ordered = append(ordered, label)
+		}
+	}
+	if len(ordered) < len(labels) {
+		ordered = append(ordered, "Placeholder")
+	}
+	return ordered
+}
+
+// RDF terms and triples as parsed from Turtle
+const (
+	termIRI = iota
+	termBlank
+	termLiteral
+)
+
+type rdfTerm struct {
+	Kind     int
+	Value    string
+	Datatype string
+	Lang     string
+}
+
+type rdfTriple struct {
+	Subject, Predicate, Object rdfTerm
+}
+
+// turtleParser reads Turtle, and therefore N-Triples: prefixes, base,
+// predicate and object lists, "a", typed and language-tagged literals,
+// numbers, booleans, blank node labels and [ ... ] property lists.
+// Collections are not supported.
+type turtleParser struct {
+	src      string
+	pos      int
+	base     string
+	prefixes map[string]string
+	blanks   int
+	triples  []rdfTriple
+}
+
+var turtleNumberPattern = regexp.MustCompile(`^[+-]?(\d*\.\d+([eE][+-]?\d+)?|\d+[eE][+-]?\d+|\d+)`)
+
+func parseTurtle(src string) ([]rdfTriple, error) {
+	p := &turtleParser{src: src, prefixes: make(map[string]string)}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return p.triples, nil
+		}
+		if err := p.statement(); err != nil {
+			return nil, fmt.Errorf("line %d: %v", strings.Count(p.src[:p.pos], "\n")+1, err)
+		}
+	}
+}
+
+func (p *turtleParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *turtleParser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *turtleParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return fmt.Errorf("expected %q at %q", c, p.context())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *turtleParser) context() string {
+	end := p.pos + 20
+	if end > len(p.src) {
+		end = len(p.src)
+	}
+	return p.src[p.pos:end]
+}
+
+// keyword consumes a case-insensitive directive keyword followed by space.
+func (p *turtleParser) keyword(word string) bool {
+	end := p.pos + len(word)
+	if end < len(p.src) && strings.EqualFold(p.src[p.pos:end], word) && strings.ContainsRune(" \t\r\n<", rune(p.src[end])) {
+		p.pos = end
+		return true
+	}
+	return false
+}
+
+func (p *turtleParser) statement() error {
+	// SPARQL-style PREFIX and BASE have no closing full stop
+	sparql := p.peek() != '@'
+	switch {
+	case p.keyword("@prefix"), p.keyword("PREFIX"):
+		p.skipSpace()
+		colon := strings.IndexByte(p.src[p.pos:], ':')
+		if colon < 0 {
+			return fmt.Errorf("invalid prefix declaration")
+		}
+		prefix := strings.TrimSpace(p.src[p.pos : p.pos+colon])
+		p.pos += colon + 1
+		p.skipSpace()
+		iri, err := p.iri()
+		if err != nil {
+			return err
+		}
+		p.prefixes[prefix] = iri
+		if !sparql {
+			return p.expect('.')
+		}
+		return nil
+	case p.keyword("@base"), p.keyword("BASE"):
+		p.skipSpace()
+		iri, err := p.iri()
+		if err != nil {
+			return err
+		}
+		p.base = iri
+		if !sparql {
+			return p.expect('.')
+		}
+		return nil
+	}
+
+	subject, err := p.subject()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	// A bare [ ... ] is a complete statement
+	if p.peek() != '.' || subject.Kind != termBlank {
+		if err := p.predicateObjectList(subject); err != nil {
+			return err
+		}
+	}
+	return p.expect('.')
+}
+
+func (p *turtleParser) predicateObjectList(subject rdfTerm) error {
+	for {
+		p.skipSpace()
+		predicate, err := p.verb()
+		if err != nil {
+			return err
+		}
+		for {
+			object, err := p.object()
+			if err != nil {
+				return err
+			}
+			p.triples = append(p.triples, rdfTriple{subject, predicate, object})
+			p.skipSpace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		if p.peek() != ';' {
+			return nil
+		}
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpace()
+		}
+		if c := p.peek(); c == '.' || c == ']' {
+			return nil
+		}
+	}
+}
+
+func (p *turtleParser) verb() (rdfTerm, error) {
+	if p.peek() == 'a' && p.pos+1 < len(p.src) && strings.ContainsRune(" \t\r\n<[\"", rune(p.src[p.pos+1])) {
+		p.pos++
+		return rdfTerm{Kind: termIRI, Value: rdfType}, nil
+	}
+	return p.resource()
+}
+
+func (p *turtleParser) subject() (rdfTerm, error) {
+	if p.peek() == '[' {
+		return p.blankPropertyList()
+	}
+	return p.resource()
+}
+
+func (p *turtleParser) object() (rdfTerm, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '[':
+		return p.blankPropertyList()
+	case c == '(':
+		return rdfTerm{}, fmt.Errorf("RDF collections are not supported")
+	case c == '"' || c == '\'':
+		return p.literal()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		number := turtleNumberPattern.FindString(p.src[p.pos:])
+		if number == "" {
+			return rdfTerm{}, fmt.Errorf("invalid number at %q", p.context())
+		}
+		p.pos += len(number)
+		datatype := xsdInteger
+		if strings.ContainsAny(number, "eE") {
+			datatype = xsdDouble
+		} else if strings.Contains(number, ".") {
+			datatype = xsdDecimal
+		}
+		return rdfTerm{Kind: termLiteral, Value: number, Datatype: datatype}, nil
+	}
+	for _, value := range []string{"true", "false"} {
+		end := p.pos + len(value)
+		if strings.HasPrefix(p.src[p.pos:], value) && (end == len(p.src) || strings.ContainsRune(" \t\r\n.;,])", rune(p.src[end]))) {
+			p.pos = end
+			return rdfTerm{Kind: termLiteral, Value: value, Datatype: xsdBoolean}, nil
+		}
+	}
+	return p.resource()
+}
+
+func (p *turtleParser) blankPropertyList() (rdfTerm, error) {
+	p.pos++
+	p.blanks++
+	node := rdfTerm{Kind: termBlank, Value: fmt.Sprintf("_:b%d", p.blanks)}
+	p.skipSpace()
+	if p.peek() != ']' {
+		if err := p.predicateObjectList(node); err != nil {
+			return node, err
+		}
+	}
+	return node, p.expect(']')
+}
+
+// resource reads an <IRI>, a prefixed name or a _:blank label.
+func (p *turtleParser) resource() (rdfTerm, error) {
+	p.skipSpace()
+	if p.peek() == '<' {
+		iri, err := p.iri()
+		return rdfTerm{Kind: termIRI, Value: iri}, err
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && !strings.ContainsRune(" \t\r\n<>\"{}|^`;,()[]", rune(p.src[p.pos])) {
+		p.pos++
+	}
+	// A name cannot end with the statement's full stop
+	for p.pos > start && p.src[p.pos-1] == '.' {
+		p.pos--
+	}
+	name := p.src[start:p.pos]
+	if strings.HasPrefix(name, "_:") {
+		return rdfTerm{Kind: termBlank, Value: name}, nil
+	}
+	prefix, local, ok := strings.Cut(name, ":")
+	if !ok {
+		return rdfTerm{}, fmt.Errorf("expected an IRI or prefixed name at %q", p.context())
+	}
+	namespace, ok := p.prefixes[prefix]
+	if !ok {
+		return rdfTerm{}, fmt.Errorf("undeclared prefix %q", prefix)
+	}
+	return rdfTerm{Kind: termIRI, Value: namespace + strings.ReplaceAll(local, `\`, "")}, nil
+}
+
+func (p *turtleParser) iri() (string, error) {
+	if p.peek() != '<' {
+		return "", fmt.Errorf("expected an IRI at %q", p.context())
+	}
+	end := strings.IndexByte(p.src[p.pos:], '>')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated IRI")
+	}
+	iri := p.src[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	if !strings.Contains(iri, ":") {
+		iri = p.base + iri
+	}
+	return unescapeTurtle(iri)
+}
+
+func (p *turtleParser) literal() (rdfTerm, error) {
+	quote := p.src[p.pos : p.pos+1]
+	if strings.HasPrefix(p.src[p.pos:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	p.pos += len(quote)
+
+	// Find the closing quote, skipping escaped characters
+	end := p.pos
+	for {
+		if end >= len(p.src) {
+			return rdfTerm{}, fmt.Errorf("unterminated string")
+		}
+		if p.src[end] == '\\' {
+			end += 2
+			continue
+		}
+		if strings.HasPrefix(p.src[end:], quote) {
+			break
+		}
+		if len(quote) == 1 && p.src[end] == '\n' {
+			return rdfTerm{}, fmt.Errorf("newline in string")
+		}
+		end++
+	}
+	value, err := unescapeTurtle(p.src[p.pos:end])
+	if err != nil {
+		return rdfTerm{}, err
+	}
+	p.pos = end + len(quote)
+
+	term := rdfTerm{Kind: termLiteral, Value: value, Datatype: xsdString}
+	if p.peek() == '@' {
+		start := p.pos + 1
+		for p.pos++; p.pos < len(p.src) && (isAlphaNumeric(p.src[p.pos]) || p.src[p.pos] == '-'); p.pos++ {
+		}
+		term.Lang = p.src[start:p.pos]
+	} else if strings.HasPrefix(p.src[p.pos:], "^^") {
+		p.pos += 2
+		datatype, err := p.resource()
+		if err != nil {
+			return rdfTerm{}, err
+		}
+		term.Datatype = datatype.Value
+	}
+	return term, nil
+}
+
+func isAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func unescapeTurtle(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u', 'U':
+			size := 4
+			if s[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in %q", s)
+			}
+			sb.WriteRune(rune(code))
+			i += size
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String(), nil
+}
+
+// literalValue converts a literal to the Go value stored in Neo4j.
+func literalValue(term rdfTerm) interface{} {
+	switch term.Datatype {
+	case xsdInteger, xsdPrefix + "int", xsdPrefix + "long":
+		if i, err := strconv.ParseInt(term.Value, 10, 64); err == nil {
+			return i
+		}
+	case xsdDecimal, xsdDouble, xsdPrefix + "float":
+		if f, err := strconv.ParseFloat(term.Value, 64); err == nil {
+			return f
+		}
+	case xsdBoolean:
+		if b, err := strconv.ParseBool(term.Value); err == nil {
+			return b
+		}
+	case xsdDate:
+		if t, err := time.Parse("2006-01-02", term.Value); err == nil {
+			return neo4j.DateOf(t)
+		}
+	case xsdDateTime:
+		// Without an offset it is a local date and time
+		if t, err := time.Parse(time.RFC3339Nano, term.Value); err == nil {
+			return t
+		}
+		if t, err := time.Parse("2006-01-02T15:04:05.999999999", term.Value); err == nil {
+			return neo4j.LocalDateTimeOf(t)
+		}
+	case xsdTime:
+		if t, err := time.Parse("15:04:05.999999999Z07:00", term.Value); err == nil {
+			return neo4j.OffsetTimeOf(t)
+		}
+		if t, err := time.Parse("15:04:05.999999999", term.Value); err == nil {
+			return neo4j.LocalTimeOf(t)
+		}
+	}
+	return term.Value
+}
+
+// importRDF maps a Turtle or N-Triples file onto the graph. Subjects with a
+// mapped rdf:type become nodes, mapped literal predicates their properties
+// and mapped predicates between two such nodes relationships; everything
+// else is counted and skipped. Classes and predicates in the base vocabulary
+// count as mapped to their local names.
+func importRDF(r io.Reader, spec DatasetSpec, sink importSink) error {
+	mappingPath := spec.Mapping
+	if mappingPath == "" {
+		mappingPath = defaultRDFMappingPath
+	}
+	mapping, err := loadRDFMapping(mappingPath)
+	if err != nil {
+		return err
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	triples, err := parseTurtle(string(content))
+	if err != nil {
+		return fmt.Errorf("invalid Turtle: %v", err)
+	}
+
+	// Types first, since they may follow a subject's other triples. The
+	// first type is the node's label and any others are added to it.
+	var subjects []string
+	nodes := make(map[string]*ImportNode)
+	for _, triple := range triples {
+		if triple.Predicate.Value != rdfType {
+			continue
+		}
+		label, ok := mapping.importName(mapping.classes, labelPattern, triple.Object.Value)
+		if !ok {
+			continue
+		}
+		node := nodes[triple.Subject.Value]
+		if node == nil {
+			nodes[triple.Subject.Value] = &ImportNode{Label: label, ID: mapping.nodeID(triple.Subject.Value), Properties: map[string]interface{}{}}
+			subjects = append(subjects, triple.Subject.Value)
+		} else if label != node.Label && !slices.Contains(node.Labels, label) {
+			node.Labels = append(node.Labels, label)
+		}
+	}
+
+	var edges []ImportEdge
+	skipped := 0
+	for _, triple := range triples {
+		subject := triple.Subject.Value
+		node, typed := nodes[subject]
+		if triple.Predicate.Value == rdfType && typed {
+			continue
+		}
+		if !typed {
+			skipped++
+			continue
+		}
+
+		if triple.Object.Kind == termLiteral {
+			name, ok := mapping.importName(mapping.properties, labelPattern, triple.Predicate.Value)
+			if !ok {
+				skipped++
+				continue
+			}
+			value := literalValue(triple.Object)
+			if name == "id" {
+				node.ID = fmt.Sprint(value)
+				continue
+			}
+			// Repeated predicates become lists
+			switch existing := node.Properties[name].(type) {
+			case nil:
+				node.Properties[name] = value
+			case []interface{}:
+				node.Properties[name] = append(existing, value)
+			default:
+				node.Properties[name] = []interface{}{existing, value}
+			}
+			continue
+		}
+
+		relationshipType, ok := mapping.importName(mapping.relationships, relationshipTypePattern, triple.Predicate.Value)
+		target, targetTyped := nodes[triple.Object.Value]
+		if !ok || !targetTyped {
+			skipped++
+			continue
+		}
+		edges = append(edges, ImportEdge{Type: relationshipType, SourceLabel: node.Label, Source: subject, TargetLabel: target.Label, Target: triple.Object.Value})
+	}
+
+	for _, subject := range subjects {
+		if err := sink.Node(*nodes[subject]); err != nil {
+			return err
+		}
+	}
+	for _, edge := range edges {
+		// Ids may come from an id property, so resolve them last
+		edge.Source = nodes[edge.Source].ID
+		edge.Target = nodes[edge.Target].ID
+		if err := sink.Edge(edge); err != nil {
+			return err
+		}
+	}
+	if skipped > 0 && spec.Path != "" {
+		fmt.Printf("⚠️ Skipped %d triples in %s with untyped subjects or unmapped predicates\n", skipped, spec.Path)
+	}
+	return nil
+}
+
+// writeTurtle writes every node and relationship as Turtle. Relationship
+// properties have no place in plain triples and are left out.
+func writeTurtle(w io.Writer, mapping *RDFMapping, stream RecordStream) error {
+	out := bufio.NewWriter(w)
+	prefixes := make([]string, 0, len(mapping.Prefixes))
+	for prefix := range mapping.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		fmt.Fprintf(out, "@prefix %s: <%s> .\n", prefix, mapping.Prefixes[prefix])
+	}
+	out.WriteString("\n")
+
+	err := stream(`MATCH (n) WHERE n.id IS NOT NULL RETURN labels(n) AS labels, n.id AS id, properties(n) AS properties ORDER BY id`, nil,
+		func(_ []string, values []interface{}) error {
+			var labels []string
+			for _, label := range asList(values[0]) {
+				if name, ok := label.(string); ok {
+					labels = append(labels, name)
+				}
+			}
+			if len(labels) == 0 {
+				return nil
+			}
+			labels = exportLabels(labels)
+			id := fmt.Sprint(values[1])
+			fmt.Fprintf(out, "%s", turtleIRI(mapping, mapping.nodeIRI(labels[0], id)))
+			for i, label := range labels {
+				separator := " ,"
+				if i == 0 {
+					separator = " a"
+				}
+				fmt.Fprintf(out, "%s %s", separator, turtleIRI(mapping, mapping.exportIRI(mapping.Classes, label)))
+			}
+
+			properties, _ := values[2].(map[string]interface{})
+			// The id is already in the IRI unless the mapping names a predicate for it
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				if name != "id" || mapping.hasProperty("id") {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				var objects []string
+				for _, value := range flattenList(properties[name]) {
+					if literal, ok := turtleLiteral(mapping, value); ok {
+						objects = append(objects, literal)
+					}
+				}
+				if len(objects) > 0 {
+					fmt.Fprintf(out, " ;\n    %s %s", turtleIRI(mapping, mapping.exportIRI(mapping.Properties, name)), strings.Join(objects, ", "))
+				}
+			}
+			_, err := out.WriteString(" .\n")
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("failed to read nodes: %v", err)
+	}
+	out.WriteString("\n")
+
+	// Endpoint IRIs use the same first label as the nodes, Placeholder last
+	err = stream(`MATCH (a)-[r]->(b) WHERE a.id IS NOT NULL AND b.id IS NOT NULL
+RETURN head([l IN labels(a) WHERE l <> 'Placeholder'] + labels(a)) AS source_label, a.id AS source, type(r) AS type,
+       head([l IN labels(b) WHERE l <> 'Placeholder'] + labels(b)) AS target_label, b.id AS target`, nil,
+		func(_ []string, values []interface{}) error {
+			sourceLabel, _ := values[0].(string)
+			relationshipType, _ := values[2].(string)
+			targetLabel, _ := values[3].(string)
+			_, err := fmt.Fprintf(out, "%s %s %s .\n",
+				turtleIRI(mapping, mapping.nodeIRI(sourceLabel, fmt.Sprint(values[1]))),
+				turtleIRI(mapping, mapping.exportIRI(mapping.Relationships, relationshipType)),
+				turtleIRI(mapping, mapping.nodeIRI(targetLabel, fmt.Sprint(values[4]))))
+			return err
+		})
+	if err != nil {
+		return fmt.Errorf("failed to read relationships: %v", err)
+	}
+	return out.Flush()
+}
+
+func flattenList(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	return []interface{}{value}
+}
+
+var turtleLocalNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// turtleIRI writes an IRI as a prefixed name when a prefix covers it and the
+// rest is a plain name, and in angle brackets otherwise.
+func turtleIRI(mapping *RDFMapping, iri string) string {
+	best := ""
+	for prefix, namespace := range mapping.Prefixes {
+		if local, ok := strings.CutPrefix(iri, namespace); ok && turtleLocalNamePattern.MatchString(local) {
+			if candidate := prefix + ":" + local; best == "" || len(candidate) < len(best) || (len(candidate) == len(best) && candidate < best) {
+				best = candidate
+			}
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return "<" + strings.NewReplacer(">", "%3E", " ", "%20", "\"", "%22").Replace(iri) + ">"
+}
+
+func turtleLiteral(mapping *RDFMapping, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return turtleString(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		return turtleString(strconv.FormatFloat(v, 'g', -1, 64)) + "^^" + turtleIRI(mapping, xsdDouble), true
+	case neo4j.Date:
+		return turtleString(v.String()) + "^^" + turtleIRI(mapping, xsdDate), true
+	case neo4j.LocalDateTime:
+		return turtleString(v.String()) + "^^" + turtleIRI(mapping, xsdDateTime), true
+	case time.Time:
+		return turtleString(v.Format(time.RFC3339Nano)) + "^^" + turtleIRI(mapping, xsdDateTime), true
+	case neo4j.LocalTime, neo4j.Time:
+		return turtleString(fmt.Sprint(v)) + "^^" + turtleIRI(mapping, xsdTime), true
+	}
+	return turtleString(fmt.Sprint(value)), true
+}
+
+func turtleString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func runRDFExportCommand(args []string) {
+	flags := flag.NewFlagSet("rdf-export", flag.ExitOnError)
+	outPath := flags.String("out", "data/graph.ttl", "Turtle file to write")
+	mappingPath := flags.String("mapping", defaultRDFMappingPath, "RDF mapping of labels, relationship types and properties")
+	namespace := namespaceFlag(flags)
+	flags.Parse(args)
+	checkNamespaceFlag(*namespace)
+
+	mapping, err := loadRDFMapping(*mappingPath)
+	if err != nil {
+		log.Fatalf("Failed to load RDF mapping: %v", err)
+	}
+	driver, err := neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "", ""))
+	if err != nil {
+		log.Fatalf("Failed to create Neo4j driver: %v", err)
+	}
+	defer driver.Close()
+
+	if err := os.MkdirAll(filepath.Dir(*outPath), 0755); err != nil {
+		log.Fatalf("Failed to create export directory: %v", err)
+	}
+	file, err := os.Create(*outPath)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *outPath, err)
+	}
+	defer file.Close()
+	stream := func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+		return streamDriverRecords(driver, *namespace, cypherQuery, params, emit)
+	}
+	if err := writeTurtle(file, mapping, stream); err != nil {
+		log.Fatalf("RDF export failed: %v", err)
+	}
+	fmt.Printf("🔗 Graph exported as Turtle to %s\n", *outPath)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestParseTurtle(t *testing.T) {
+	src := `@prefix ex: <http://example.org/> .
+PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+@base <http://example.org/people/> .
+
+# Predicate and object lists
+<thor> a foaf:Person ;
+    foaf:name "Thor"@en, 'Donald Blake' ;
+    ex:debut 1962 ; ex:rating 4.5 ; ex:worthy true .
+ex:loki foaf:knows <thor> ; ex:quote """Kneel
+before "me".""" ; ex:alias "Loki \u00d0\"Trickster\"" .
+_:b1 ex:weight "2.5"^^<http://www.w3.org/2001/XMLSchema#double> .
+ex:odin foaf:knows [ foaf:name "Frigga" ] .
+<http://example.org/n/x> <http://example.org/p> <http://example.org/n/y> .
+`
+	triples, err := parseTurtle(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type flat struct{ S, P, O, Datatype, Lang string }
+	var got []flat
+	for _, triple := range triples {
+		got = append(got, flat{triple.Subject.Value, triple.Predicate.Value, triple.Object.Value, triple.Object.Datatype, triple.Object.Lang})
+	}
+	foaf := "http://xmlns.com/foaf/0.1/"
+	want := []flat{
+		{"http://example.org/people/thor", rdfType, foaf + "Person", "", ""},
+		{"http://example.org/people/thor", foaf + "name", "Thor", xsdString, "en"},
+		{"http://example.org/people/thor", foaf + "name", "Donald Blake", xsdString, ""},
+		{"http://example.org/people/thor", "http://example.org/debut", "1962", xsdInteger, ""},
+		{"http://example.org/people/thor", "http://example.org/rating", "4.5", xsdDecimal, ""},
+		{"http://example.org/people/thor", "http://example.org/worthy", "true", xsdBoolean, ""},
+		{"http://example.org/loki", foaf + "knows", "http://example.org/people/thor", "", ""},
+		{"http://example.org/loki", "http://example.org/quote", "Kneel\nbefore \"me\".", xsdString, ""},
+		{"http://example.org/loki", "http://example.org/alias", "Loki Ð\"Trickster\"", xsdString, ""},
+		{"_:b1", "http://example.org/weight", "2.5", xsdDouble, ""},
+		{"_:b1", foaf + "name", "Frigga", xsdString, ""},
+		{"http://example.org/odin", foaf + "knows", "_:b1", "", ""},
+		{"http://example.org/n/x", "http://example.org/p", "http://example.org/n/y", "", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("triples:\n%v\nwant:\n%v", got, want)
+	}
+
+	for _, bad := range []string{
+		`ex:a ex:b ex:c .`,
+		`<a> <b> "unterminated .`,
+		`<a> <b> ( <c> ) .`,
+		`<a> <b> <c>`,
+	} {
+		if _, err := parseTurtle(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestImportRDF(t *testing.T) {
+	src := `@prefix marvel: <https://example.org/marvel/vocab/> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+@prefix schema: <http://schema.org/> .
+
+<https://example.org/marvel/hero/SPIDER-MAN%2FPETER%20PARKER> foaf:knows <https://example.org/marvel/hero/WATSON-PARKER%2C%20MARY%20JANE> ;
+    marvel:appearsIn <http://comics.example/asm-1> ;
+    a marvel:Hero ;
+    rdfs:label "Spider-Man" ;
+    foaf:nick "Spidey" .
+<https://example.org/marvel/hero/WATSON-PARKER%2C%20MARY%20JANE> a marvel:Hero .
+<http://comics.example/asm-1> a schema:ComicIssue ; schema:issueNumber 1 .
+<http://comics.example/unknown> foaf:knows <https://example.org/marvel/hero/SPIDER-MAN%2FPETER%20PARKER> .
+`
+	sink := &recordingSink{}
+	if err := importRDF(strings.NewReader(src), DatasetSpec{Mapping: defaultRDFMappingPath}, sink); err != nil {
+		t.Fatal(err)
+	}
+	wantNodes := []ImportNode{
+		{Label: "Hero", ID: "SPIDER-MAN/PETER PARKER", Properties: map[string]interface{}{"name": "Spider-Man"}},
+		{Label: "Hero", ID: "WATSON-PARKER, MARY JANE", Properties: map[string]interface{}{}},
+		{Label: "Comic", ID: "http://comics.example/asm-1", Properties: map[string]interface{}{}},
+	}
+	wantEdges := []ImportEdge{
+		{Type: "KNOWS", SourceLabel: "Hero", Source: "SPIDER-MAN/PETER PARKER", TargetLabel: "Hero", Target: "WATSON-PARKER, MARY JANE"},
+		{Type: "APPEARS_IN", SourceLabel: "Hero", Source: "SPIDER-MAN/PETER PARKER", TargetLabel: "Comic", Target: "http://comics.example/asm-1"},
+	}
+	if !reflect.DeepEqual(sink.nodes, wantNodes) {
+		t.Errorf("nodes = %+v", sink.nodes)
+	}
+	if !reflect.DeepEqual(sink.edges, wantEdges) {
+		t.Errorf("edges = %+v", sink.edges)
+	}
+}
+
+func TestTurtleRoundTrip(t *testing.T) {
+	mapping, err := loadRDFMapping(defaultRDFMappingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := [][]interface{}{
+		{[]interface{}{"Character"}, "N'astirh", map[string]interface{}{"id": "N'astirh", "name": "N'astirh", "group": "1", "size": int64(3)}},
+		{[]interface{}{"Character"}, "Baron Zemo", map[string]interface{}{"id": "Baron Zemo", "name": "Baron Zemo", "aliases": []interface{}{"Heinrich", "Helmut"}, "score": 2.0,
+			"source_file": "marvel_characters_partnerships/nodes.csv", "source_row": int64(12)}},
+		{[]interface{}{"Comic"}, "AVF 4", map[string]interface{}{"id": "AVF 4", "title": "AVF 4 \"Special\"\n",
+			"release_date": neo4j.DateOf(time.Date(1963, 3, 1, 0, 0, 0, 0, time.UTC)), "updated_at": time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}},
+		// Neo4j may list Placeholder first; it must not become the label
+		{[]interface{}{"Placeholder", "Character"}, "Ghost", map[string]interface{}{"id": "Ghost", "import_id": "20261018T120000Z"}},
+	}
+	relationships := [][]interface{}{
+		{"Character", "Baron Zemo", "PARTNERS_WITH", "Character", "N'astirh"},
+		{"Character", "N'astirh", "FEATURED_IN", "Comic", "AVF 4"},
+		{"Character", "Ghost", "PARTNERS_WITH", "Character", "Baron Zemo"},
+	}
+	stream := func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+		rows := nodes
+		if strings.Contains(cypherQuery, "-[r]->") {
+			rows = relationships
+		}
+		for _, row := range rows {
+			if err := emit(nil, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var sb strings.Builder
+	if err := writeTurtle(&sb, mapping, stream); err != nil {
+		t.Fatal(err)
+	}
+	turtle := sb.String()
+	for _, fragment := range []string{
+		"@prefix marvel: <https://example.org/marvel/vocab/> .",
+		`<https://example.org/marvel/character/N%27astirh> a marvel:Character ;`,
+		`<https://example.org/marvel/character/Ghost> a marvel:Character , marvel:Placeholder`,
+		`rdfs:label "N'astirh"`,
+		`marvel:aliases "Heinrich", "Helmut"`,
+		`schema:datePublished "1963-03-01"^^xsd:date`,
+		`marvel:updated_at "2024-01-02T15:04:05Z"^^xsd:dateTime`,
+		`marvel:partnersWith <https://example.org/marvel/character/N%27astirh> .`,
+	} {
+		if !strings.Contains(turtle, fragment) {
+			t.Errorf("export should contain %q:\n%s", fragment, turtle)
+		}
+	}
+
+	// Names without a mapping were written in the base vocabulary and read
+	// back by their local names
+	sink := &recordingSink{}
+	if err := importRDF(strings.NewReader(turtle), DatasetSpec{Mapping: defaultRDFMappingPath}, sink); err != nil {
+		t.Fatalf("export does not import: %v\n%s", err, turtle)
+	}
+	wantNodes := []ImportNode{
+		{Label: "Comic", ID: "AVF 4", Properties: map[string]interface{}{"title": "AVF 4 \"Special\"\n",
+			"release_date": neo4j.DateOf(time.Date(1963, 3, 1, 0, 0, 0, 0, time.UTC)), "updated_at": time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)}},
+		{Label: "Character", ID: "Baron Zemo", Properties: map[string]interface{}{"name": "Baron Zemo", "aliases": []interface{}{"Heinrich", "Helmut"}, "score": 2.0,
+			"source_file": "marvel_characters_partnerships/nodes.csv", "source_row": int64(12)}},
+		{Label: "Character", Labels: []string{"Placeholder"}, ID: "Ghost", Properties: map[string]interface{}{"import_id": "20261018T120000Z"}},
+		{Label: "Character", ID: "N'astirh", Properties: map[string]interface{}{"name": "N'astirh", "group": "1", "size": int64(3)}},
+	}
+	wantEdges := []ImportEdge{
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Baron Zemo", TargetLabel: "Character", Target: "N'astirh"},
+		{Type: "FEATURED_IN", SourceLabel: "Character", Source: "N'astirh", TargetLabel: "Comic", Target: "AVF 4"},
+		{Type: "PARTNERS_WITH", SourceLabel: "Character", Source: "Ghost", TargetLabel: "Character", Target: "Baron Zemo"},
+	}
+	sort.Slice(sink.nodes, func(i, j int) bool { return sink.nodes[i].ID < sink.nodes[j].ID })
+	if !reflect.DeepEqual(sink.nodes, wantNodes) {
+		t.Errorf("nodes = %+v\nwant %+v", sink.nodes, wantNodes)
+	}
+	if !reflect.DeepEqual(sink.edges, wantEdges) {
+		t.Errorf("edges = %+v\nwant %+v", sink.edges, wantEdges)
+	}
+}
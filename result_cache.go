@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const (
+	resultCacheCapacity = 500
+	// Results larger than this are executed every time rather than cached
+	resultCacheMaxBytes = 256 * 1024
+	// Loads and restores run by another process (the load and restore
+	// commands) cannot bump this server's graph generation, so entries also
+	// expire
+	resultCacheTTL = 5 * time.Minute
+)
+
+// ResultCache memoizes executeQuery output for read queries. Keys include the
+// graph generation, so everything cached before a reload through this server
+// simply stops matching; a load by another process is picked up once its
+// entries expire.
+type ResultCache struct {
+	lru *lruCache[string]
+}
+
+func newResultCache(capacity int, ttl time.Duration) *ResultCache {
+	return &ResultCache{lru: newLRUCache[string](capacity, ttl)}
+}
+
+// resultCacheKey collapses whitespace but keeps case, since string literals
+// in the query are case-sensitive. The same query in another namespace's
+// database is a different key.
+func resultCacheKey(database, cypherQuery string, params map[string]interface{}) string {
+	normalized := strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(cypherQuery), ";")), " ")
+	encodedParams, _ := json.Marshal(params)
+	return fmt.Sprintf("%d\x00%s\x00%s\x00%s", graphGeneration.Load(), database, normalized, encodedParams)
+}
+
+func (c *ResultCache) Execute(driver neo4j.Driver, database, cypherQuery string, params map[string]interface{}) (string, bool) {
+	key := resultCacheKey(database, cypherQuery, params)
+	if results, ok := c.lru.Get(key); ok {
+		return results, true
+	}
+
+	results := executeQuery(driver, database, cypherQuery, params)
+	if _, ok := parseResultRows(results); ok && len(results) <= resultCacheMaxBytes {
+		c.lru.Put(key, results)
+	}
+	return results, false
+}
+
+func (c *ResultCache) Clear() {
+	c.lru.Clear()
+}
+
+func (c *ResultCache) Stats() cacheStats {
+	return c.lru.Stats()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResultCacheKey(t *testing.T) {
+	base := resultCacheKey("", "MATCH (c:Character {id: 'Thor'}) RETURN c.id AS result", nil)
+
+	if got := resultCacheKey("", "  MATCH (c:Character {id: 'Thor'})\n\tRETURN c.id AS result; ", nil); got != base {
+		t.Errorf("whitespace and trailing semicolon should not change the key")
+	}
+	if got := resultCacheKey("", "MATCH (c:Character {id: 'thor'}) RETURN c.id AS result", nil); got == base {
+		t.Errorf("string literals are case-sensitive and must change the key")
+	}
+	if got := resultCacheKey("", "MATCH (c:Character {id: 'Thor'}) RETURN c.id AS result", map[string]interface{}{"id": "Thor"}); got == base {
+		t.Errorf("parameters must change the key")
+	}
+	if got := resultCacheKey("experiments", "MATCH (c:Character {id: 'Thor'}) RETURN c.id AS result", nil); got == base {
+		t.Errorf("another namespace must change the key")
+	}
+
+	graphGeneration.Add(1)
+	if got := resultCacheKey("", "MATCH (c:Character {id: 'Thor'}) RETURN c.id AS result", nil); got == base {
+		t.Errorf("a new graph generation must change the key")
+	}
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	// Loads by another process leave the generation alone; entries expire
+	cache := newResultCache(10, time.Millisecond)
+	key := resultCacheKey("", "MATCH (n) RETURN count(n) AS result", nil)
+	cache.lru.Put(key, "[1] 42")
+	time.Sleep(5 * time.Millisecond)
+	if results, ok := cache.lru.Get(key); ok {
+		t.Errorf("expired entry served: %q", results)
+	}
+}
+
+func TestCacheTTLFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: resultCacheTTL},
+		{value: "30s", want: 30 * time.Second},
+		{value: "0", want: 0},
+		{value: "soon", want: resultCacheTTL},
+	}
+	for _, tc := range tests {
+		t.Setenv("RESULT_CACHE_TTL", tc.value)
+		if got := cacheTTLFromEnv("RESULT_CACHE_TTL", resultCacheTTL); got != tc.want {
+			t.Errorf("%q: ttl = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
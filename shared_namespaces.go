@@ -0,0 +1,613 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// A server with a single database (Neo4j Community Edition) cannot give each
+// namespace its own, so there every namespace shares the home database.
+// Namespace lab's labels, relationship types and constraint and index names
+// are stored prefixed, as lab__Character, and its nodes also carry the labels
+// lab__ and Namespaced__. Every statement run for a namespace goes through
+// graphScope.Cypher, which prefixes the names it uses and confines its
+// unlabelled node patterns to the namespace; those of the default namespace
+// skip Namespaced__ nodes instead, and its labels stay as they are.
+const sharedNamespaceLabel = "Namespaced__"
+
+var (
+	namespaceStorageMu sync.Mutex
+	// sharedNamespaces is nil until the server's edition has been read
+	sharedNamespaces *bool
+
+	// detectSharedNamespaces tells whether namespaces must share the home
+	// database; tests swap it out to run without Neo4j.
+	detectSharedNamespaces = func(driver neo4j.Driver) (bool, error) {
+		session := driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+		defer session.Close()
+		result, err := session.Run("CALL dbms.components() YIELD edition RETURN edition", nil)
+		if err != nil {
+			return false, err
+		}
+		shared := false
+		for result.Next() {
+			if edition, _ := result.Record().Values[0].(string); edition == "community" {
+				shared = true
+			}
+		}
+		return shared, result.Err()
+	}
+)
+
+// namespacesShared tells whether the server keeps every namespace in its home
+// database, reading its edition the first time.
+func namespacesShared(driver neo4j.Driver) bool {
+	namespaceStorageMu.Lock()
+	defer namespaceStorageMu.Unlock()
+	if sharedNamespaces != nil {
+		return *sharedNamespaces
+	}
+	shared, err := detectSharedNamespaces(driver)
+	if err != nil {
+		// Not remembered, so the next statement asks again
+		log.Printf("Could not read the Neo4j edition, assuming a database per namespace: %v", err)
+		return false
+	}
+	sharedNamespaces = &shared
+	if shared {
+		fmt.Println("🗂️ Single-database Neo4j server: namespaces share the home database.")
+	}
+	return shared
+}
+
+// graphScope is where a namespace's statements run: its database, or on a
+// shared database the prefix its names carry there ("" for the default
+// namespace).
+type graphScope struct {
+	Database string
+	Shared   bool
+	Prefix   string
+}
+
+func namespaceScope(driver neo4j.Driver, name string) graphScope {
+	if !namespacesShared(driver) {
+		return graphScope{Database: name}
+	}
+	if name == "" {
+		return graphScope{Shared: true}
+	}
+	// Dashes are not allowed in unquoted names; underscores are not allowed
+	// in namespaces, so prefixes stay distinct
+	return graphScope{Shared: true, Prefix: strings.ReplaceAll(name, "-", "_") + "__"}
+}
+
+// namespaceSession opens a session on a namespace's graph. On a shared
+// database it rewrites the statements it runs and the labels and types of
+// the nodes, relationships and paths it returns.
+func namespaceSession(driver neo4j.Driver, name string, mode neo4j.AccessMode) neo4j.Session {
+	scope := namespaceScope(driver, name)
+	session := driver.NewSession(neo4j.SessionConfig{AccessMode: mode, DatabaseName: scope.Database})
+	if !scope.Shared {
+		return session
+	}
+	return scopedSession{Session: session, scope: scope}
+}
+
+type scopedSession struct {
+	neo4j.Session
+	scope graphScope
+}
+
+func (s scopedSession) Run(cypherQuery string, params map[string]any, configurers ...func(*neo4j.TransactionConfig)) (neo4j.Result, error) {
+	result, err := s.Session.Run(s.scope.Cypher(cypherQuery), params, configurers...)
+	if err != nil {
+		return nil, err
+	}
+	return scopedResult{Result: result, scope: s.scope}, nil
+}
+
+// scopedResult rewrites values as Record hands them out, which is how this
+// project reads results.
+type scopedResult struct {
+	neo4j.Result
+	scope graphScope
+}
+
+func (r scopedResult) Record() *neo4j.Record {
+	record := r.Result.Record()
+	if record != nil {
+		for i := range record.Values {
+			record.Values[i] = r.scope.value(record.Values[i])
+		}
+	}
+	return record
+}
+
+// ownName is a stored label, relationship type or schema name as the scope's
+// namespace knows it, and whether it belongs to the namespace at all.
+func (s graphScope) ownName(name string) (string, bool) {
+	if !s.Shared {
+		return name, true
+	}
+	if s.Prefix == "" {
+		return name, !strings.Contains(name, "__")
+	}
+	own, ok := strings.CutPrefix(name, s.Prefix)
+	return own, ok && own != ""
+}
+
+func (s graphScope) ownLabels(labels []string) []string {
+	own := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if name, ok := s.ownName(label); ok {
+			own = append(own, name)
+		}
+	}
+	return own
+}
+
+// ownOwners filters the owners column of the schema procedures, where
+// relationship types come back as :`TYPE`.
+func (s graphScope) ownOwners(owners interface{}) interface{} {
+	if !s.Shared {
+		return owners
+	}
+	var own []interface{}
+	for _, owner := range asList(owners) {
+		if name, ok := s.ownName(strings.Trim(fmt.Sprint(owner), ":`")); ok {
+			own = append(own, name)
+		}
+	}
+	return own
+}
+
+// value strips the prefix from the labels and types of graph values.
+func (s graphScope) value(value interface{}) interface{} {
+	if s.Prefix == "" {
+		return value
+	}
+	switch v := value.(type) {
+	case neo4j.Node:
+		v.Labels = s.ownLabels(v.Labels)
+		return v
+	case neo4j.Relationship:
+		v.Type = strings.TrimPrefix(v.Type, s.Prefix)
+		return v
+	case neo4j.Path:
+		for i := range v.Nodes {
+			v.Nodes[i].Labels = s.ownLabels(v.Nodes[i].Labels)
+		}
+		for i := range v.Relationships {
+			v.Relationships[i].Type = strings.TrimPrefix(v.Relationships[i].Type, s.Prefix)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = s.value(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = s.value(v[key])
+		}
+	}
+	return value
+}
+
+// constraintOwnerPattern finds the label or relationship type a constraint
+// statement is for.
+var constraintOwnerPattern = regexp.MustCompile(`(?is)\bFOR\s*(?:\(\s*\)\s*<?-\s*\[|\()[^:]*:\s*(` + "`(?:[^`]|``)*`" + `|[A-Za-z_][A-Za-z0-9_]*)`)
+
+// ownConstraint is a SHOW CONSTRAINTS createStatement as the scope's
+// namespace would have written it, and whether the constraint is the
+// namespace's.
+func (s graphScope) ownConstraint(statement string) (string, bool) {
+	if !s.Shared {
+		return statement, true
+	}
+	owner := constraintOwnerPattern.FindStringSubmatchIndex(statement)
+	if owner == nil {
+		return statement, s.Prefix == ""
+	}
+	name := statement[owner[2]:owner[3]]
+	quoted := strings.HasPrefix(name, "`")
+	if quoted {
+		name = strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	own, ok := s.ownName(name)
+	if !ok {
+		return "", false
+	}
+	if quoted {
+		own = "`" + strings.ReplaceAll(own, "`", "``") + "`"
+	}
+	head := statement[:owner[2]]
+	if s.Prefix != "" {
+		head = regexp.MustCompile("(?is)^(\\s*CREATE\\s+CONSTRAINT\\s+`?)"+regexp.QuoteMeta(s.Prefix)).ReplaceAllString(head, "${1}")
+	}
+	return head + own + statement[owner[3]:], true
+}
+
+// Cypher rewrites a statement for the scope's namespace: see the top of this
+// file.
+func (s graphScope) Cypher(cypherQuery string) string {
+	if !s.Shared {
+		return cypherQuery
+	}
+	scoper := &cypherScoper{scope: s, in: cypherQuery}
+	return scoper.rewrite()
+}
+
+// Words that may come right before a parenthesis that is not a function call
+var patternKeywords = map[string]bool{
+	"MATCH": true, "MERGE": true, "CREATE": true, "WHERE": true, "AND": true, "OR": true, "XOR": true,
+	"NOT": true, "RETURN": true, "WITH": true, "IN": true, "DELETE": true, "DISTINCT": true, "UNWIND": true,
+	"ON": true, "FOR": true, "REQUIRE": true, "WHEN": true, "THEN": true, "ELSE": true, "BY": true, "AS": true,
+}
+
+// Words that open a subquery rather than a map with their brace
+var subqueryKeywords = map[string]bool{"CALL": true, "EXISTS": true, "COUNT": true, "COLLECT": true}
+
+var clauseKeywords = map[string]bool{
+	"MATCH": true, "MERGE": true, "CREATE": true, "DROP": true, "WITH": true, "RETURN": true, "WHERE": true,
+	"SET": true, "REMOVE": true, "DELETE": true, "UNWIND": true, "CALL": true, "ORDER": true, "YIELD": true,
+	"FOREACH": true, "UNION": true, "SHOW": true, "USING": true, "LIMIT": true, "SKIP": true,
+}
+
+// Words after CREATE that make it a schema command rather than a write
+var schemaKeywords = map[string]bool{
+	"CONSTRAINT": true, "INDEX": true, "RANGE": true, "TEXT": true, "POINT": true, "FULLTEXT": true,
+	"LOOKUP": true, "VECTOR": true, "BTREE": true, "OR": true, "DATABASE": true,
+}
+
+// cypherScoper is a single pass over a statement. It tracks just enough
+// context to tell labels from map keys, node patterns from parenthesized
+// expressions and writes from reads.
+type cypherScoper struct {
+	scope graphScope
+	in    string
+	out   strings.Builder
+	// open brackets: ( and [, m for a map brace and q for a subquery brace
+	brackets []byte
+	// the last clause keyword, with CREATE and DROP of schema as SCHEMA
+	clause string
+	// the last word, uppercased, while it is the last token
+	word string
+	// the last two significant characters; a word counts as 'a'
+	last, beforeLast byte
+	// set for a labelled node pattern a write creates in a namespace
+	markLabels bool
+}
+
+func (r *cypherScoper) rewrite() string {
+	in := r.in
+	for i := 0; i < len(in); {
+		c := in[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := skipCypherQuoted(in, i)
+			r.out.WriteString(in[i:j])
+			r.setLast('\'')
+			i = j
+		case c == '/' && i+1 < len(in) && (in[i+1] == '/' || in[i+1] == '*'):
+			j := skipCypherComment(in, i)
+			r.out.WriteString(in[i:j])
+			i = j
+		case isCypherSpace(c):
+			r.out.WriteByte(c)
+			i++
+		case c == '`':
+			j := skipCypherQuoted(in, i)
+			r.out.WriteString(in[i:j])
+			r.setLast('a')
+			i = j
+		case isCypherWordStart(c):
+			i = r.readWord(i)
+		case c == ':':
+			i = r.readLabels(i)
+		case c == '(':
+			i = r.openParen(i)
+		case c == '[':
+			r.brackets = append(r.brackets, '[')
+			r.out.WriteByte(c)
+			r.setLast(c)
+			i++
+		case c == '{':
+			kind := byte('m')
+			if r.last == 'a' && subqueryKeywords[r.word] {
+				kind = 'q'
+			}
+			r.brackets = append(r.brackets, kind)
+			r.out.WriteByte(c)
+			r.setLast(c)
+			i++
+		case c == ')' || c == ']' || c == '}':
+			if len(r.brackets) > 0 {
+				r.brackets = r.brackets[:len(r.brackets)-1]
+			}
+			r.out.WriteByte(c)
+			r.setLast(c)
+			i++
+		default:
+			r.out.WriteByte(c)
+			r.setLast(c)
+			i++
+		}
+	}
+	return r.out.String()
+}
+
+func (r *cypherScoper) setLast(c byte) {
+	r.beforeLast, r.last = r.last, c
+	r.word = ""
+}
+
+func (r *cypherScoper) inMap() bool {
+	return len(r.brackets) > 0 && r.brackets[len(r.brackets)-1] == 'm'
+}
+
+func (r *cypherScoper) writing() bool {
+	return r.clause == "MERGE" || r.clause == "CREATE"
+}
+
+func (r *cypherScoper) readWord(i int) int {
+	in := r.in
+	j := endOfCypherWord(in, i)
+	word := in[i:j]
+	upper := strings.ToUpper(word)
+	next := skipCypherSpace(in, j)
+	// Property keys, parameters and map keys are never keywords
+	if r.last == '.' || r.last == '$' || (r.inMap() && next < len(in) && in[next] == ':') {
+		r.out.WriteString(word)
+		r.setLast('a')
+		return j
+	}
+
+	if r.scope.Prefix != "" && (upper == "LABELS" || upper == "TYPE") && next < len(in) && in[next] == '(' {
+		end := closingCypherBracket(in, next)
+		argument := r.scope.Cypher(in[next+1 : end])
+		quoted := "'" + r.scope.Prefix + "'"
+		if upper == "LABELS" {
+			fmt.Fprintf(&r.out, "[__label IN labels(%s) WHERE __label STARTS WITH %s AND __label <> %s | substring(__label, %d)]",
+				argument, quoted, quoted, len(r.scope.Prefix))
+		} else {
+			fmt.Fprintf(&r.out, "substring(type(%s), %d)", argument, len(r.scope.Prefix))
+		}
+		r.setLast(')')
+		return min(end+1, len(in))
+	}
+
+	switch {
+	case upper == "CREATE":
+		r.clause = "CREATE"
+		if following := in[next:endOfCypherWord(in, next)]; schemaKeywords[strings.ToUpper(following)] {
+			r.clause = "SCHEMA"
+		}
+	case upper == "DROP":
+		r.clause = "SCHEMA"
+	case clauseKeywords[upper]:
+		r.clause = upper
+	}
+	r.out.WriteString(word)
+	r.setLast('a')
+	r.word = upper
+
+	// Constraint and index names are per database, so a namespace's get its
+	// prefix
+	if r.scope.Prefix != "" && r.clause == "SCHEMA" && (upper == "CONSTRAINT" || upper == "INDEX") && next < len(in) {
+		if in[next] == '`' {
+			end := skipCypherQuoted(in, next)
+			r.out.WriteString(in[j:next] + "`" + r.scope.Prefix + in[next+1:end])
+			return end
+		}
+		following := in[next:endOfCypherWord(in, next)]
+		switch strings.ToUpper(following) {
+		case "", "IF", "FOR", "ON":
+		default:
+			r.out.WriteString(in[j:next] + r.scope.Prefix + following)
+			r.setLast('a')
+			return next + len(following)
+		}
+	}
+	return j
+}
+
+// readLabels prefixes a label expression, such as :Character:Hero or
+// :KNOWS|ALLY, and marks the nodes a namespace creates.
+func (r *cypherScoper) readLabels(i int) int {
+	in := r.in
+	if i+1 < len(in) && in[i+1] == ':' {
+		r.out.WriteString("::")
+		r.setLast(':')
+		return i + 2
+	}
+	r.out.WriteByte(':')
+	if r.inMap() {
+		r.setLast(':')
+		return i + 1
+	}
+	i++
+	for {
+		j := skipCypherSpace(in, i)
+		for j < len(in) && (in[j] == '!' || in[j] == ':') {
+			j = skipCypherSpace(in, j+1)
+		}
+		r.out.WriteString(in[i:j])
+		i = j
+		if i >= len(in) {
+			break
+		}
+		if in[i] == '`' {
+			j = skipCypherQuoted(in, i)
+			r.out.WriteString("`" + r.scope.Prefix + in[i+1:j])
+		} else if isCypherWordStart(in[i]) {
+			j = endOfCypherWord(in, i)
+			r.out.WriteString(r.scope.Prefix + in[i:j])
+		} else {
+			break
+		}
+		i = j
+		j = skipCypherSpace(in, i)
+		if j < len(in) && (in[j] == '|' || in[j] == '&' || (in[j] == ':' && (j+1 >= len(in) || in[j+1] != ':'))) {
+			r.out.WriteString(in[i : j+1])
+			i = j + 1
+			continue
+		}
+		break
+	}
+	if r.markLabels {
+		r.out.WriteString(":" + r.scope.Prefix + ":" + sharedNamespaceLabel)
+		r.markLabels = false
+	}
+	r.setLast('a')
+	return i
+}
+
+// openParen decides whether a parenthesis opens a node pattern, and gives an
+// unlabelled one the label that confines it to the namespace.
+func (r *cypherScoper) openParen(i int) int {
+	in := r.in
+	last, beforeLast, word := r.last, r.beforeLast, r.word
+	r.brackets = append(r.brackets, '(')
+	r.out.WriteByte('(')
+	r.setLast('(')
+	if last == 'a' && !patternKeywords[word] {
+		// A function call
+		return i + 1
+	}
+
+	// The optional variable, then a label, a property map, WHERE or the end
+	start := skipCypherSpace(in, i+1)
+	end := start
+	if end < len(in) && in[end] == '`' {
+		end = skipCypherQuoted(in, end)
+	} else if end < len(in) && isCypherWordStart(in[end]) {
+		end = endOfCypherWord(in, end)
+	}
+	next := skipCypherSpace(in, end)
+	if next >= len(in) {
+		return i + 1
+	}
+	switch {
+	case in[next] == ':' && (next+1 >= len(in) || in[next+1] != ':'):
+		r.markLabels = r.scope.Prefix != "" && r.writing()
+		return i + 1
+	case in[next] == ')' || in[next] == '{':
+	case end > start && strings.EqualFold(in[next:endOfCypherWord(in, next)], "WHERE"):
+	default:
+		return i + 1
+	}
+
+	// Unlabelled parentheses are a node pattern where a pattern starts or
+	// next to a relationship
+	after := skipCypherSpace(in, closingCypherBracket(in, i)+1)
+	following := cypherCharAt(in, skipCypherSpace(in, after+1))
+	pattern := (last == 'a' && (word == "MATCH" || word == "MERGE" || word == "CREATE")) ||
+		(last == ',' && (r.clause == "MATCH" || r.clause == "MERGE" || r.clause == "CREATE")) ||
+		(last == '-' && strings.IndexByte("-]<)", beforeLast) >= 0) ||
+		(last == '>' && beforeLast == '-') ||
+		(cypherCharAt(in, after) == '-' && following != 0 && strings.IndexByte("-[>", following) >= 0) ||
+		(cypherCharAt(in, after) == '<' && following == '-')
+	if !pattern {
+		return i + 1
+	}
+
+	marker := ""
+	switch {
+	case r.scope.Prefix == "" && !r.writing():
+		marker = ":!" + sharedNamespaceLabel
+	case r.scope.Prefix != "" && !r.writing():
+		marker = ":" + r.scope.Prefix
+	case r.scope.Prefix != "" && in[next] != ')':
+		// A node the write creates; a bare variable is one already bound
+		marker = ":" + r.scope.Prefix + ":" + sharedNamespaceLabel
+	}
+	r.out.WriteString(in[i+1:end] + marker)
+	if end > start {
+		r.setLast('a')
+	}
+	return end
+}
+
+func isCypherSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isCypherWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func endOfCypherWord(in string, i int) int {
+	for i < len(in) && (isCypherWordStart(in[i]) || (in[i] >= '0' && in[i] <= '9')) {
+		i++
+	}
+	return i
+}
+
+// cypherCharAt is the character at i, or 0 past the end.
+func cypherCharAt(in string, i int) byte {
+	if i < len(in) {
+		return in[i]
+	}
+	return 0
+}
+
+func skipCypherSpace(in string, i int) int {
+	for i < len(in) && isCypherSpace(in[i]) {
+		i++
+	}
+	return i
+}
+
+// skipCypherQuoted returns the end of the string or quoted name at i.
+func skipCypherQuoted(in string, i int) int {
+	quote := in[i]
+	for j := i + 1; j < len(in); j++ {
+		switch {
+		case in[j] == '\\' && quote != '`':
+			j++
+		case in[j] == quote && quote == '`' && j+1 < len(in) && in[j+1] == '`':
+			j++
+		case in[j] == quote:
+			return j + 1
+		}
+	}
+	return len(in)
+}
+
+func skipCypherComment(in string, i int) int {
+	if in[i+1] == '/' {
+		if end := strings.IndexByte(in[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(in)
+	}
+	if end := strings.Index(in[i+2:], "*/"); end >= 0 {
+		return i + 2 + end + 2
+	}
+	return len(in)
+}
+
+// closingCypherBracket returns the index of the bracket closing the one at
+// open, or the end of the statement.
+func closingCypherBracket(in string, open int) int {
+	depth := 0
+	for i := open; i < len(in); i++ {
+		switch c := in[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipCypherQuoted(in, i) - 1
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(in)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// withNamespaceStorage makes the server look like one edition or the other
+// for the rest of the test.
+func withNamespaceStorage(t *testing.T, shared bool) {
+	detect := detectSharedNamespaces
+	t.Cleanup(func() {
+		detectSharedNamespaces = detect
+		sharedNamespaces = nil
+	})
+	sharedNamespaces = nil
+	detectSharedNamespaces = func(neo4j.Driver) (bool, error) { return shared, nil }
+}
+
+func TestNamespaceScope(t *testing.T) {
+	// Enterprise Edition: a database per namespace, statements as written
+	withNamespaceStorage(t, false)
+	scope := namespaceScope(nil, "my-lab")
+	if scope != (graphScope{Database: "my-lab"}) {
+		t.Errorf("multi-database scope = %+v", scope)
+	}
+	if got := scope.Cypher("MATCH (n) RETURN n"); got != "MATCH (n) RETURN n" {
+		t.Errorf("multi-database statement rewritten: %q", got)
+	}
+
+	// Community Edition: the home database with prefixed names, and no
+	// database to create
+	withNamespaceStorage(t, true)
+	if scope := namespaceScope(nil, "my-lab"); scope != (graphScope{Shared: true, Prefix: "my_lab__"}) {
+		t.Errorf("shared scope = %+v", scope)
+	}
+	if scope := namespaceScope(nil, ""); scope != (graphScope{Shared: true}) {
+		t.Errorf("shared default scope = %+v", scope)
+	}
+	if err := createNamespaceDatabase(nil, "my-lab"); err != nil {
+		t.Errorf("shared namespace database: %v", err)
+	}
+}
+
+func TestScopeCypher(t *testing.T) {
+	lab := graphScope{Shared: true, Prefix: "lab__"}
+	home := graphScope{Shared: true}
+	tests := []struct {
+		scope graphScope
+		in    string
+		want  string
+	}{
+		// Clearing a namespace keeps to its nodes, and the default's skip
+		// every namespace's
+		{lab, "MATCH (n) WHERE NOT n:SchemaMigration DETACH DELETE n",
+			"MATCH (n:lab__) WHERE NOT n:lab__SchemaMigration DETACH DELETE n"},
+		{home, "MATCH (n) WHERE NOT n:SchemaMigration DETACH DELETE n",
+			"MATCH (n:!Namespaced__) WHERE NOT n:SchemaMigration DETACH DELETE n"},
+		// Created nodes carry the markers; bound variables are left alone
+		{lab, "MERGE (n:Character {id: row.id}) SET n:Hero",
+			"MERGE (n:lab__Character:lab__:Namespaced__ {id: row.id}) SET n:lab__Hero"},
+		{lab, "MATCH (a:Hero {id: row.source}) MATCH (b:Comic {id: row.target}) MERGE (a)-[r:APPEARS_IN]->(b)",
+			"MATCH (a:lab__Hero {id: row.source}) MATCH (b:lab__Comic {id: row.target}) MERGE (a)-[r:lab__APPEARS_IN]->(b)"},
+		{home, "MERGE (n:Character {id: row.id}) SET n:Hero", "MERGE (n:Character {id: row.id}) SET n:Hero"},
+		// Labels and types read back without the prefix
+		{lab, "MATCH (n {id: $id})-[r]-(m) RETURN labels(m)[0] AS label, type(r) AS type, size([(n)--() | 1]) AS degree",
+			"MATCH (n:lab__ {id: $id})-[r]-(m:lab__) RETURN [__label IN labels(m) WHERE __label STARTS WITH 'lab__' AND __label <> 'lab__' | substring(__label, 5)][0] AS label, substring(type(r), 5) AS type, size([(n:lab__)--(:lab__) | 1]) AS degree"},
+		{home, "MATCH (n {id: $id})-[r]-(m) RETURN labels(m)[0] AS label, type(r) AS type",
+			"MATCH (n:!Namespaced__ {id: $id})-[r]-(m:!Namespaced__) RETURN labels(m)[0] AS label, type(r) AS type"},
+		// Strings, map keys, property keys, function calls and type
+		// predicates are not labels or node patterns
+		{lab, "MATCH (h:Hero)-[:KNOWS|:ALLY]->(c:`Big Comic`) WHERE c.title CONTAINS 'X:Men (1963)' AND c.year IS :: INTEGER RETURN h {.id, type: h.type}, count(c) AS n",
+			"MATCH (h:lab__Hero)-[:lab__KNOWS|:lab__ALLY]->(c:`lab__Big Comic`) WHERE c.title CONTAINS 'X:Men (1963)' AND c.year IS :: INTEGER RETURN h {.id, type: h.type}, count(c) AS n"},
+		{lab, "MATCH (h:Hero) WHERE COUNT { (h)-->() } > 2 RETURN h.id",
+			"MATCH (h:lab__Hero) WHERE COUNT { (h:lab__)-->(:lab__) } > 2 RETURN h.id"},
+		// Constraint and index names are per namespace
+		{lab, "CREATE CONSTRAINT `character_id` IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE",
+			"CREATE CONSTRAINT `lab__character_id` IF NOT EXISTS FOR (c:lab__Character) REQUIRE c.id IS UNIQUE"},
+		{lab, "CREATE CONSTRAINT IF NOT EXISTS FOR (c:Character) REQUIRE c.id IS UNIQUE",
+			"CREATE CONSTRAINT IF NOT EXISTS FOR (c:lab__Character) REQUIRE c.id IS UNIQUE"},
+		{lab, "CREATE INDEX snapshot_key IF NOT EXISTS FOR (n:SnapshotNode) ON (n.snapshot_key)",
+			"CREATE INDEX lab__snapshot_key IF NOT EXISTS FOR (n:lab__SnapshotNode) ON (n.snapshot_key)"},
+		{lab, "MATCH (n:SnapshotNode) CALL { WITH n REMOVE n:SnapshotNode } IN TRANSACTIONS OF 10000 ROWS",
+			"MATCH (n:lab__SnapshotNode) CALL { WITH n REMOVE n:lab__SnapshotNode } IN TRANSACTIONS OF 10000 ROWS"},
+		{lab, "DROP INDEX snapshot_key IF EXISTS", "DROP INDEX lab__snapshot_key IF EXISTS"},
+	}
+	for _, tc := range tests {
+		if got := tc.scope.Cypher(tc.in); got != tc.want {
+			t.Errorf("%q in %q:\n got %s\nwant %s", tc.in, tc.scope.Prefix, got, tc.want)
+		}
+	}
+}
+
+func TestLoadDatasetsIntoSharedNamespace(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, datasetManifest), []byte(`{"datasets": [{"path": "heroes.jsonl", "relationship_type": "KNOWS"}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "heroes.jsonl"), []byte(`{"kind": "node", "label": "Hero", "id": "THOR"}
+{"kind": "node", "label": "Hero", "id": "LOKI"}
+{"kind": "edge", "source_label": "Hero", "source": "THOR", "target_label": "Hero", "target": "LOKI"}
+`), 0644)
+	specs, err := discoverDatasets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lab := graphScope{Shared: true, Prefix: "lab__"}
+	var written []string
+	_, err = loadDatasets(dir, specs, graphLoad{
+		clear: func() error {
+			written = append(written, lab.Cypher("MATCH (n) WHERE NOT n:SchemaMigration DETACH DELETE n"))
+			return nil
+		},
+		migrate: func() error { return nil },
+		write: func(cypherQuery string, params map[string]interface{}) error {
+			written = append(written, strings.Split(lab.Cypher(cypherQuery), "\n")[1:3]...)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"MATCH (n:lab__) WHERE NOT n:lab__SchemaMigration DETACH DELETE n",
+		"MERGE (n:lab__Hero:lab__:Namespaced__ {id: row.id})", "ON CREATE SET " + provenanceSet("n"),
+		"MATCH (a:lab__Hero {id: row.source})", "MATCH (b:lab__Hero {id: row.target})",
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("shared load wrote %q", written)
+	}
+}
+
+func TestScopeValue(t *testing.T) {
+	lab := graphScope{Shared: true, Prefix: "lab__"}
+	thor := neo4j.Node{Labels: []string{"lab__", "lab__Hero", sharedNamespaceLabel, "lab__Character"}}
+	knows := neo4j.Relationship{Type: "lab__KNOWS"}
+	values := lab.value([]interface{}{thor, knows, neo4j.Path{Nodes: []neo4j.Node{thor}, Relationships: []neo4j.Relationship{knows}}}).([]interface{})
+	if labels := values[0].(neo4j.Node).Labels; !reflect.DeepEqual(labels, []string{"Hero", "Character"}) {
+		t.Errorf("node labels = %q", labels)
+	}
+	if kind := values[1].(neo4j.Relationship).Type; kind != "KNOWS" {
+		t.Errorf("relationship type = %q", kind)
+	}
+	path := values[2].(neo4j.Path)
+	if path.Nodes[0].Labels[0] != "Hero" || path.Relationships[0].Type != "KNOWS" {
+		t.Errorf("path = %+v", path)
+	}
+}
+
+func TestOwnConstraint(t *testing.T) {
+	own := "CREATE CONSTRAINT `lab__character_id` FOR (n:`lab__Character`) REQUIRE (n.`id`) IS UNIQUE"
+	home := "CREATE CONSTRAINT `constraint_1a2b` FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE"
+	other := "CREATE CONSTRAINT `constraint_3c4d` FOR ()-[r:`other__KNOWS`]-() REQUIRE (r.`id`) IS UNIQUE"
+
+	lab := graphScope{Shared: true, Prefix: "lab__"}
+	if statement, ok := lab.ownConstraint(own); !ok || statement != "CREATE CONSTRAINT `character_id` FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE" {
+		t.Errorf("own constraint = %q, %v", statement, ok)
+	}
+	for _, statement := range []string{home, other} {
+		if _, ok := lab.ownConstraint(statement); ok {
+			t.Errorf("%q kept in namespace lab", statement)
+		}
+	}
+
+	defaultScope := graphScope{Shared: true}
+	if statement, ok := defaultScope.ownConstraint(home); !ok || statement != home {
+		t.Errorf("default constraint = %q, %v", statement, ok)
+	}
+	for _, statement := range []string{own, other} {
+		if _, ok := defaultScope.ownConstraint(statement); ok {
+			t.Errorf("%q kept in the default namespace", statement)
+		}
+	}
+}
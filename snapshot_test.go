@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	releaseDate := time.Date(1963, 3, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2024, 1, 2, 15, 4, 5, 6, time.FixedZone("", 3600))
+	nodes := [][]interface{}{
+		{"4:a:1", []interface{}{"Character"}, map[string]interface{}{"id": "Thor", "aliases": []interface{}{"Donald Blake"}}},
+		{"4:a:2", []interface{}{"Character", "Hero"}, map[string]interface{}{"id": "Hulk", "power": 9.5, "rank": 2.0}},
+		{"4:a:3", []interface{}{"Comic"}, map[string]interface{}{"id": "AVF 4", "issue": int64(4),
+			"release_date": neo4j.DateOf(releaseDate), "updated_at": updatedAt, "reprint_date": "2012-05-04"}},
+	}
+	relationships := [][]interface{}{
+		{"4:a:1", "4:a:2", "PARTNERS_WITH", map[string]interface{}{"since": int64(1963)}},
+		{"4:a:2", "4:a:3", "APPEARS_IN", map[string]interface{}{}},
+	}
+	stream := func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+		rows := nodes
+		if strings.Contains(cypherQuery, "-[r]->") {
+			rows = relationships
+		}
+		for _, row := range rows {
+			if err := emit(nil, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	header := SnapshotHeader{Format: snapshotFormat, Version: snapshotVersion, Nodes: 3, Relationships: 2,
+		Constraints: []string{"CREATE CONSTRAINT `character_id` FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE"}}
+	var sb strings.Builder
+	if err := writeSnapshot(&sb, header, stream); err != nil {
+		t.Fatal(err)
+	}
+
+	var nodeGroups, relationshipGroups []string
+	restored := map[int64]snapshotRecord{}
+	var edges []snapshotRecord
+	read, err := readSnapshot(strings.NewReader(sb.String()),
+		func(labels string, batch []snapshotRecord) error {
+			if len(edges) > 0 {
+				t.Error("nodes handed over after relationships")
+			}
+			nodeGroups = append(nodeGroups, labels)
+			for _, record := range batch {
+				restored[record.Key] = record
+			}
+			return nil
+		},
+		func(relationshipType string, batch []snapshotRecord) error {
+			relationshipGroups = append(relationshipGroups, relationshipType)
+			edges = append(edges, batch...)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if read.Nodes != 3 || read.Relationships != 2 {
+		t.Errorf("header = %+v", read)
+	}
+	// The named constraint is recreated without failing if it exists
+	want := "CREATE CONSTRAINT `character_id` IF NOT EXISTS FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE"
+	if len(read.Constraints) != 1 || constraintIfNotExists(read.Constraints[0]) != want {
+		t.Errorf("constraints = %q, want %q", read.Constraints, want)
+	}
+	if !reflect.DeepEqual(nodeGroups, []string{"Character", "Character:Hero", "Comic"}) {
+		t.Errorf("node groups = %q", nodeGroups)
+	}
+	if !reflect.DeepEqual(relationshipGroups, []string{"PARTNERS_WITH", "APPEARS_IN"}) {
+		t.Errorf("relationship groups = %q", relationshipGroups)
+	}
+
+	// Values keep their Go types and relationships point at the right keys
+	if got := restored[3].Properties["issue"]; got != int64(4) {
+		t.Errorf("issue = %#v, want int64(4)", got)
+	}
+	if got := restored[2].Properties["power"]; got != 9.5 {
+		t.Errorf("power = %#v, want 9.5", got)
+	}
+	if got := restored[2].Properties["rank"]; got != 2.0 {
+		t.Errorf("rank = %#v, want float64(2)", got)
+	}
+	if got := restored[1].Properties["aliases"]; !reflect.DeepEqual(got, []interface{}{"Donald Blake"}) {
+		t.Errorf("aliases = %#v", got)
+	}
+	// Temporal values come back as the driver types they were written as,
+	// while a string that only looks like a date stays a string
+	if got := restored[3].Properties["release_date"]; got != neo4j.DateOf(releaseDate) {
+		t.Errorf("release_date = %#v, want a neo4j.Date", got)
+	}
+	if got, ok := restored[3].Properties["updated_at"].(time.Time); !ok || !got.Equal(updatedAt) {
+		t.Errorf("updated_at = %#v, want %v", restored[3].Properties["updated_at"], updatedAt)
+	}
+	if got := restored[3].Properties["reprint_date"]; got != "2012-05-04" {
+		t.Errorf("reprint_date = %#v, want the string", got)
+	}
+	if len(edges) != 2 || restored[edges[0].Start].Properties["id"] != "Thor" || restored[edges[0].End].Properties["id"] != "Hulk" {
+		t.Errorf("edges = %+v", edges)
+	}
+	if got := edges[0].Properties["since"]; got != int64(1963) {
+		t.Errorf("since = %#v, want int64(1963)", got)
+	}
+}
+
+func TestConstraintIfNotExists(t *testing.T) {
+	tests := []struct {
+		statement string
+		want      string
+	}{
+		{
+			statement: "CREATE CONSTRAINT `character_id` FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE",
+			want:      "CREATE CONSTRAINT `character_id` IF NOT EXISTS FOR (n:`Character`) REQUIRE (n.`id`) IS UNIQUE",
+		},
+		{
+			statement: "CREATE CONSTRAINT comic_id FOR (n:Comic) REQUIRE n.id IS UNIQUE",
+			want:      "CREATE CONSTRAINT comic_id IF NOT EXISTS FOR (n:Comic) REQUIRE n.id IS UNIQUE",
+		},
+		{
+			statement: "CREATE CONSTRAINT `odd ``name`` FOR` FOR (n:Team) REQUIRE n.id IS UNIQUE",
+			want:      "CREATE CONSTRAINT `odd ``name`` FOR` IF NOT EXISTS FOR (n:Team) REQUIRE n.id IS UNIQUE",
+		},
+		{
+			statement: "CREATE CONSTRAINT FOR (n:Movie) REQUIRE n.id IS UNIQUE",
+			want:      "CREATE CONSTRAINT IF NOT EXISTS FOR (n:Movie) REQUIRE n.id IS UNIQUE",
+		},
+		{
+			statement: "CREATE CONSTRAINT team_id IF NOT EXISTS FOR (n:Team) REQUIRE n.id IS UNIQUE",
+			want:      "CREATE CONSTRAINT team_id IF NOT EXISTS FOR (n:Team) REQUIRE n.id IS UNIQUE",
+		},
+	}
+	for _, tc := range tests {
+		if got := constraintIfNotExists(tc.statement); got != tc.want {
+			t.Errorf("constraintIfNotExists(%q) = %q, want %q", tc.statement, got, tc.want)
+		}
+	}
+}
+
+func TestReadSnapshotBatches(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString(`{"format":"graph-rag-snapshot","version":1}` + "\n")
+	for i := 1; i <= snapshotBatchSize+1; i++ {
+		fmt.Fprintf(&sb, `{"kind":"node","key":%d,"labels":["Comic"],"properties":{"id":"C%d"}}`+"\n", i, i)
+	}
+
+	var sizes []int
+	_, err := readSnapshot(strings.NewReader(sb.String()),
+		func(labels string, batch []snapshotRecord) error {
+			sizes = append(sizes, len(batch))
+			return nil
+		},
+		func(string, []snapshotRecord) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sizes, []int{snapshotBatchSize, 1}) {
+		t.Errorf("batch sizes = %v", sizes)
+	}
+}
+
+func TestReadSnapshotRejects(t *testing.T) {
+	tests := []struct {
+		snapshot string
+		wantErr  string
+	}{
+		{snapshot: `{"format":"neo4j-dump","version":1}`, wantErr: "unsupported snapshot"},
+		{snapshot: `{"format":"graph-rag-snapshot","version":3}`, wantErr: "unsupported snapshot"},
+		{
+			snapshot: `{"format":"graph-rag-snapshot","version":2}` + "\n" +
+				`{"kind":"node","key":1,"properties":{"release_date":{"$instant":"2012-05-04"}}}`,
+			wantErr: `unknown value type "$instant"`,
+		},
+		{snapshot: `{"format":"graph-rag-snapshot","version":1}` + "\n" + `{"kind":"index"}`, wantErr: `unknown kind "index"`},
+		{
+			snapshot: `{"format":"graph-rag-snapshot","version":1}` + "\n" +
+				`{"kind":"relationship","type":"KNOWS","start":1,"end":2,"properties":{}}` + "\n" +
+				`{"kind":"node","key":3,"properties":{}}`,
+			wantErr: "node after relationships",
+		},
+	}
+	for _, tc := range tests {
+		noop := func(string, []snapshotRecord) error { return nil }
+		_, err := readSnapshot(strings.NewReader(tc.snapshot), noop, noop)
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tc.snapshot, err, tc.wantErr)
+		}
+	}
+}
+
+func TestSnapshotTemporalTags(t *testing.T) {
+	moment := time.Date(2024, 1, 2, 15, 4, 5, 6, time.FixedZone("", -5*3600))
+	values := func() []interface{} {
+		return []interface{}{
+			neo4j.DateOf(moment),
+			neo4j.LocalDateTimeOf(moment),
+			neo4j.LocalTimeOf(moment),
+			neo4j.OffsetTimeOf(moment),
+			neo4j.DurationOf(14, 3, 3600, 5),
+			[]interface{}{neo4j.DateOf(moment), "1963"},
+		}
+	}
+	// snapshotValue rewrites lists in place, so compare against fresh values
+	want := values()
+	for i, value := range values() {
+		encoded, err := json.Marshal(snapshotValue(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := json.NewDecoder(strings.NewReader(string(encoded)))
+		decoder.UseNumber()
+		var decoded interface{}
+		if err := decoder.Decode(&decoded); err != nil {
+			t.Fatal(err)
+		}
+		restored, err := restoreValue(normalizeParamValue(decoded))
+		if err != nil {
+			t.Errorf("%s: %v", encoded, err)
+		} else if !reflect.DeepEqual(restored, want[i]) {
+			t.Errorf("%s restored as %#v, want %#v", encoded, restored, want[i])
+		}
+	}
+}
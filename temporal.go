@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const timelineLimit = 1000
+
+// Temporal columns are recognized by name: date, *_date, year, *_year, since,
+// until and founded. Dates are stored as Neo4j dates and years as integers.
+func isDateProperty(name string) bool {
+	name = strings.ToLower(name)
+	return name == "date" || strings.HasSuffix(name, "_date")
+}
+
+func isYearProperty(name string) bool {
+	switch name = strings.ToLower(name); name {
+	case "year", "since", "until", "founded":
+		return true
+	}
+	return strings.HasSuffix(name, "_year")
+}
+
+// parseDate accepts YYYY-MM-DD, YYYY-MM and YYYY, the last two meaning the
+// first day of the month or year.
+func parseDate(value string) (neo4j.Date, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return neo4j.DateOf(t), nil
+		}
+	}
+	return neo4j.Date{}, fmt.Errorf("invalid date %q", value)
+}
+
+// temporalValue parses a temporal property's text value, reporting false
+// for other properties and for empty or malformed values.
+func temporalValue(name, value string) (interface{}, bool) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return nil, false
+	case isDateProperty(name):
+		date, err := parseDate(value)
+		if err != nil {
+			return nil, false
+		}
+		return date, true
+	case isYearProperty(name):
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, false
+		}
+		return year, true
+	}
+	return nil, false
+}
+
+// temporalColumns reads the temporal columns among header and row into
+// properties, creating the map if needed. Empty and malformed values are
+// left out.
+func temporalColumns(properties map[string]interface{}, header, row []string) map[string]interface{} {
+	for i, name := range header {
+		if i >= len(row) {
+			break
+		}
+		name = strings.TrimSpace(name)
+		parsed, ok := temporalValue(name, row[i])
+		if !ok {
+			continue
+		}
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[name] = parsed
+	}
+	return properties
+}
+
+// temporalProperties converts the string values of temporal properties in
+// place, leaving malformed ones as they are.
+func temporalProperties(properties map[string]interface{}) map[string]interface{} {
+	for name, value := range properties {
+		if text, ok := value.(string); ok {
+			if parsed, ok := temporalValue(name, text); ok {
+				properties[name] = parsed
+			}
+		}
+	}
+	return properties
+}
+
+// graphValue turns driver temporal values into ISO 8601 strings, which the
+// JSON encoder would otherwise write as empty objects.
+func graphValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case neo4j.Date, neo4j.LocalDateTime, neo4j.LocalTime, neo4j.Time, neo4j.Duration:
+		return fmt.Sprint(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		for i := range v {
+			v[i] = graphValue(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = graphValue(v[key])
+		}
+	}
+	return value
+}
+
+type TemporalProperty struct {
+	// Owner is a node label or relationship type
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	// Kind is date or year
+	Kind string `json:"kind"`
+}
+
+// temporalSchema lists the date and year properties the graph holds.
+func temporalSchema(query GraphRecords) ([]TemporalProperty, error) {
+	nodes, err := query(`CALL db.schema.nodeTypeProperties() YIELD nodeLabels, propertyName, propertyTypes
+RETURN nodeLabels AS owners, propertyName AS name, propertyTypes AS types`, nil)
+	if err != nil {
+		return nil, err
+	}
+	relationships, err := query(`CALL db.schema.relTypeProperties() YIELD relType, propertyName, propertyTypes
+RETURN [relType] AS owners, propertyName AS name, propertyTypes AS types`, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[TemporalProperty]bool)
+	var properties []TemporalProperty
+	for _, record := range append(nodes, relationships...) {
+		name, _ := record["name"].(string)
+		kind := ""
+		for _, t := range asList(record["types"]) {
+			switch t {
+			case "Date", "DateTime", "LocalDateTime":
+				kind = "date"
+			case "Long", "Integer":
+				if kind == "" && isYearProperty(name) {
+					kind = "year"
+				}
+			}
+		}
+		if kind == "" {
+			continue
+		}
+		for _, owner := range asList(record["owners"]) {
+			// Relationship types come back as :`TYPE`
+			label := strings.Trim(fmt.Sprint(owner), ":`")
+			property := TemporalProperty{Owner: label, Name: name, Kind: kind}
+			if label != "SchemaMigration" && !seen[property] {
+				seen[property] = true
+				properties = append(properties, property)
+			}
+		}
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		if properties[i].Owner != properties[j].Owner {
+			return properties[i].Owner < properties[j].Owner
+		}
+		return properties[i].Name < properties[j].Name
+	})
+	return properties, nil
+}
+
+// describeTemporal tells the generator which properties hold times and how
+// to filter on them.
+func describeTemporal(properties []TemporalProperty) string {
+	if len(properties) == 0 {
+		return ""
+	}
+	parts := make([]string, len(properties))
+	for i, property := range properties {
+		parts[i] = fmt.Sprintf("%s.%s (%s)", property.Owner, property.Name, property.Kind)
+	}
+	return "\nTemporal properties: " + strings.Join(parts, ", ") +
+		". Filter dates with date values, e.g. WHERE m.release_date >= date($from), and years as integers, e.g. WHERE r.since >= $from AND r.since < $to for a decade"
+}
+
+type TimelineEvent struct {
+	Time         string `json:"time"`
+	Property     string `json:"property"`
+	Relationship string `json:"relationship"`
+	Direction    string `json:"direction"`
+	Label        string `json:"label"`
+	ID           string `json:"id"`
+}
+
+// entityTimeline orders a node's relationships by their own temporal
+// property, or else by the neighbor's, leaving out those with neither.
+func entityTimeline(query GraphRecords, label, id string) ([]TimelineEvent, error) {
+	if !labelPattern.MatchString(label) {
+		return nil, fmt.Errorf("invalid label %q", label)
+	}
+	records, err := query(`MATCH (n:`+label+` {id: $id})-[r]-(m)
+RETURN type(r) AS relationship, CASE WHEN startNode(r) = n THEN 'out' ELSE 'in' END AS direction,
+       labels(m)[0] AS label, m.id AS id, properties(r) AS relationship_properties, properties(m) AS node_properties
+LIMIT $limit`, map[string]interface{}{"id": id, "limit": timelineLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	events := []TimelineEvent{}
+	for _, record := range records {
+		event := TimelineEvent{}
+		event.Relationship, _ = record["relationship"].(string)
+		event.Direction, _ = record["direction"].(string)
+		event.Label, _ = record["label"].(string)
+		event.ID, _ = record["id"].(string)
+		for _, key := range []string{"relationship_properties", "node_properties"} {
+			properties, _ := record[key].(map[string]interface{})
+			if event.Property, event.Time = firstTemporal(properties); event.Time != "" {
+				break
+			}
+		}
+		if event.Time != "" {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Time != events[j].Time {
+			return events[i].Time < events[j].Time
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events, nil
+}
+
+// firstTemporal picks a map's temporal property, preferring dates over years
+// and then names in order.
+func firstTemporal(properties map[string]interface{}) (string, string) {
+	var names []string
+	for name := range properties {
+		if isDateProperty(name) || isYearProperty(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if isDateProperty(names[i]) != isDateProperty(names[j]) {
+			return isDateProperty(names[i])
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		switch value := graphValue(properties[name]).(type) {
+		case string:
+			return name, value
+		case int64:
+			return name, strconv.FormatInt(value, 10)
+		}
+	}
+	return "", ""
+}
+
+func handleEntityTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	label := r.PathValue("label")
+	if !labelPattern.MatchString(label) {
+		http.Error(w, fmt.Sprintf("invalid label %q", label), http.StatusBadRequest)
+		return
+	}
+	events, err := entityTimeline(fetchGraphRecords(ns.Name), label, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+func TestTemporalColumns(t *testing.T) {
+	header := []string{"since", "until", "release_date", "notes", "first_year"}
+	row := []string{"1963", "", "2008-05", "x", "soon"}
+	got := temporalColumns(nil, header, row)
+	want := map[string]interface{}{"since": 1963, "release_date": neo4j.DateOf(time.Date(2008, 5, 1, 0, 0, 0, 0, time.UTC))}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("temporalColumns = %v, want %v", got, want)
+	}
+	if got := temporalColumns(nil, header, nil); got != nil {
+		t.Errorf("no values should give no properties, got %v", got)
+	}
+}
+
+func TestGraphValue(t *testing.T) {
+	value := map[string]interface{}{
+		"release_date": neo4j.DateOf(time.Date(2012, 5, 4, 0, 0, 0, 0, time.UTC)),
+		"dates":        []interface{}{neo4j.DateOf(time.Date(1963, 9, 1, 0, 0, 0, 0, time.UTC)), int64(3)},
+		"title":        "The Avengers",
+	}
+	want := map[string]interface{}{"release_date": "2012-05-04", "dates": []interface{}{"1963-09-01", int64(3)}, "title": "The Avengers"}
+	if got := graphValue(value); !reflect.DeepEqual(got, want) {
+		t.Errorf("graphValue = %#v", got)
+	}
+}
+
+func TestTemporalSchema(t *testing.T) {
+	query := (&stubGraph{rows: map[string][]map[string]interface{}{
+		"nodeTypeProperties": {
+			{"owners": []interface{}{"Movie"}, "name": "release_date", "types": []interface{}{"Date"}},
+			{"owners": []interface{}{"Movie"}, "name": "title", "types": []interface{}{"String"}},
+			{"owners": []interface{}{"Team"}, "name": "founded", "types": []interface{}{"Long"}},
+			{"owners": []interface{}{"Character"}, "name": "size", "types": []interface{}{"Long"}},
+		},
+		"relTypeProperties": {
+			{"owners": []interface{}{":`MEMBER_OF`"}, "name": "since", "types": []interface{}{"Long"}},
+		},
+	}}).query
+	properties, err := temporalSchema(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TemporalProperty{
+		{Owner: "MEMBER_OF", Name: "since", Kind: "year"},
+		{Owner: "Movie", Name: "release_date", Kind: "date"},
+		{Owner: "Team", Name: "founded", Kind: "year"},
+	}
+	if !reflect.DeepEqual(properties, want) {
+		t.Errorf("temporalSchema = %+v", properties)
+	}
+	if described := describeTemporal(properties); !strings.Contains(described, "MEMBER_OF.since (year), Movie.release_date (date)") {
+		t.Errorf("describeTemporal = %q", described)
+	}
+}
+
+func TestEntityTimeline(t *testing.T) {
+	query := (&stubGraph{rows: map[string][]map[string]interface{}{
+		"properties(m)": {
+			{"relationship": "APPEARS_IN_MOVIE", "direction": "out", "label": "Movie", "id": "The Avengers (2012 film)",
+				"relationship_properties": map[string]interface{}{}, "node_properties": map[string]interface{}{"title": "The Avengers (2012 film)", "release_date": "2012-05-04"}},
+			{"relationship": "MEMBER_OF", "direction": "out", "label": "Team", "id": "Avengers",
+				"relationship_properties": map[string]interface{}{"since": int64(1963)}, "node_properties": map[string]interface{}{"founded": int64(1961)}},
+			{"relationship": "PARTNERS_WITH", "direction": "in", "label": "Character", "id": "Pepper Potts",
+				"relationship_properties": map[string]interface{}{}, "node_properties": map[string]interface{}{"name": "Pepper Potts"}},
+		},
+	}}).query
+	events, err := entityTimeline(query, "Character", "Iron Man")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []TimelineEvent{
+		{Time: "1963", Property: "since", Relationship: "MEMBER_OF", Direction: "out", Label: "Team", ID: "Avengers"},
+		{Time: "2012-05-04", Property: "release_date", Relationship: "APPEARS_IN_MOVIE", Direction: "out", Label: "Movie", ID: "The Avengers (2012 film)"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("timeline = %+v", events)
+	}
+
+	if _, err := entityTimeline(query, "Character) DETACH DELETE (n", "x"); err == nil {
+		t.Error("expected an invalid label to be rejected")
+	}
+}
+
+func TestHandleEntityTimeline(t *testing.T) {
+	savedNamespaces, savedFetch := namespaces, fetchGraphRecords
+	t.Cleanup(func() { namespaces, fetchGraphRecords = savedNamespaces, savedFetch })
+	namespaces = map[string]*GraphNamespace{"": {}}
+
+	var backendErr error
+	fetchGraphRecords = func(namespace string) GraphRecords {
+		return func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+			return nil, backendErr
+		}
+	}
+
+	tests := []struct {
+		name    string
+		label   string
+		backend error
+		status  int
+	}{
+		{name: "no events", label: "Character", status: http.StatusOK},
+		{name: "invalid label", label: "Character) DETACH DELETE (n", status: http.StatusBadRequest},
+		{name: "backend error", label: "Character", backend: fmt.Errorf("connection refused"), status: http.StatusInternalServerError},
+	}
+	for _, tc := range tests {
+		backendErr = tc.backend
+		request := httptest.NewRequest(http.MethodGet, "/api/entities/x/Thor/timeline", nil)
+		request.SetPathValue("label", tc.label)
+		request.SetPathValue("id", "Thor")
+		recorder := httptest.NewRecorder()
+		handleEntityTimeline(recorder, request)
+		if recorder.Code != tc.status {
+			t.Errorf("%s: status %d, want %d (%s)", tc.name, recorder.Code, tc.status, recorder.Body)
+		}
+	}
+}
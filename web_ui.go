@@ -6,51 +6,117 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
 )
 
 type QueryRequest struct {
 	Query string `json:"query"`
+	Mode  string `json:"mode,omitempty"`
+	// Namespace picks the graph to ask; empty for the default
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type QueryResponse struct {
-	Query     string `json:"query"`
-	Cypher    string `json:"cypher"`
-	Results   string `json:"results"`
-	Response  string `json:"response"`
-	Error     string `json:"error,omitempty"`
-	Timestamp string `json:"timestamp"`
+	ID        string                 `json:"id"`
+	Query     string                 `json:"query"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Cypher    string                 `json:"cypher"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+	Results   string                 `json:"results"`
+	Response  string                 `json:"response"`
+	Error     string                 `json:"error,omitempty"`
+	Mode      string                 `json:"mode,omitempty"`
+	// RepairAttempts counts the times a rejected query was sent back to
+	// the model to fix
+	RepairAttempts int            `json:"repair_attempts,omitempty"`
+	Trace          []ToolCall     `json:"trace,omitempty"`
+	Grounding      *Grounding     `json:"grounding,omitempty"`
+	Sources        []string       `json:"sources,omitempty"`
+	Models         ModelNames     `json:"models"`
+	Prompts        PromptVersions `json:"prompt_versions"`
+	Cache          CacheHits      `json:"cache"`
+	Latency        QueryLatency   `json:"latency"`
+	Timestamp      string         `json:"timestamp"`
+}
+
+type QueryLatency struct {
+	CypherMs    int64 `json:"cypher_ms"`
+	ExecutionMs int64 `json:"execution_ms"`
+	ResponseMs  int64 `json:"response_ms"`
+	TotalMs     int64 `json:"total_ms"`
+}
+
+type FeedbackRequest struct {
+	ID              string                 `json:"id"`
+	Rating          string                 `json:"rating"`
+	CorrectedCypher string                 `json:"corrected_cypher"`
+	CorrectedParams map[string]interface{} `json:"corrected_params"`
+	Comment         string                 `json:"comment"`
 }
 
 var (
-	driver     neo4j.Driver
-	llm        llms.Model
-	schema     string
-	dataLoaded bool
+	driver       neo4j.Driver
+	cypherLLM    llms.Model
+	answerLLM    llms.Model
+	modelNames   ModelNames
+	dataLoaded   bool
+	historyStore *HistoryStore
+	promptStore  *PromptStore
+	llmCache     *LLMCache
+
+	resultCache = newResultCache(resultCacheCapacity, cacheTTLFromEnv("RESULT_CACHE_TTL", resultCacheTTL))
+
+	// exampleLibraries holds each namespace's few-shot examples, read on
+	// first use (see namespaceExamples)
+	exampleLibrariesMu sync.Mutex
+	exampleLibraries   = make(map[string]*ExampleLibrary)
+
+	// executeGraphQuery runs generated Cypher against a namespace's graph
+	// through the result cache; tests swap it out to run without Neo4j.
+	executeGraphQuery = func(namespace, cypherQuery string, params map[string]interface{}) (string, bool) {
+		return resultCache.Execute(driver, namespace, cypherQuery, params)
+	}
+
+	// fetchGraphRecords backs the agent tools and the graph view and is
+	// stubbed the same way.
+	fetchGraphRecords = func(namespace string) GraphRecords {
+		return func(cypherQuery string, params map[string]interface{}) ([]map[string]interface{}, error) {
+			return queryRecords(driver, namespace, cypherQuery, params, graphRecordLimit)
+		}
+	}
+
+	// streamGraphRecords feeds exports row by row.
+	streamGraphRecords = func(namespace string) RecordStream {
+		return func(cypherQuery string, params map[string]interface{}, emit func(keys []string, values []interface{}) error) error {
+			return streamRecords(driver, namespace, cypherQuery, params, emit)
+		}
+	}
 )
 
 func startWebUI() {
-	// Initialize Neo4j connection
+	initQueryPipeline()
+	defer driver.Close()
+
+	// Open persistent query history
 	var err error
-	driver, err = neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "", ""))
+	historyStore, err = openHistoryStore(historyFilePath)
 	if err != nil {
-		log.Fatalf("Failed to create Neo4j driver: %v", err)
+		log.Fatalf("Failed to open query history: %v", err)
 	}
-	defer driver.Close()
+	defer historyStore.Close()
 
-	// Initialize LLM
-	llm, err = ollama.New(ollama.WithModel("llama3.2"))
+	// Open LLM response cache
+	llmCache, err = openLLMCache(llmCachePath, llmCacheCapacity, cacheTTLFromEnv("LLM_CACHE_TTL", llmCacheTTL))
 	if err != nil {
-		log.Fatalf("Failed to create LLM: %v", err)
+		log.Fatalf("Failed to open LLM cache: %v", err)
 	}
-
-	// Get graph schema
-	schema = getGraphSchema(driver)
+	defer llmCache.Close()
 
 	// Check if data is already loaded
 	dataLoaded = checkIfDataExists(driver)
@@ -60,6 +126,21 @@ func startWebUI() {
 	http.HandleFunc("/api/query", handleQuery)
 	http.HandleFunc("/api/status", handleStatus)
 	http.HandleFunc("/api/load-data", handleLoadData)
+	http.HandleFunc("/api/quality", handleQualityReport)
+	http.HandleFunc("/api/history", handleHistory)
+	http.HandleFunc("/api/history/feedback", handleFeedback)
+	http.HandleFunc("/api/history/promote", handlePromoteHistory)
+	http.HandleFunc("/api/examples", handleExamples)
+	http.HandleFunc("/api/prompts", handlePrompts)
+	http.HandleFunc("/api/prompts/reload", handleReloadPrompts)
+	http.HandleFunc("/api/cache", handleCacheStats)
+	http.HandleFunc("/api/graph", handleQueryGraph)
+	http.HandleFunc("/api/export", handleExport)
+	http.HandleFunc("/api/entities", handleFindEntities)
+	http.HandleFunc("/api/entities/{label}/{id}", handleEntity)
+	http.HandleFunc("/api/entities/{label}/{id}/neighbors", handleEntityNeighbors)
+	http.HandleFunc("/api/entities/{label}/{id}/timeline", handleEntityTimeline)
+	http.HandleFunc("/api/entities/{label}/{id}/provenance", handleEntityProvenance)
 
 	fmt.Println("🌐 Starting Web UI...")
 	fmt.Println("📱 Open your browser and go to: http://localhost:8080")
@@ -288,6 +369,49 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
             border-left: 3px solid #f87171;
         }
 
+        .grounding {
+            margin-top: 10px;
+            font-size: 0.85rem;
+            color: #a0a0a0;
+        }
+
+        .grounding .ungrounded {
+            color: #fbbf24;
+            margin-top: 4px;
+        }
+
+        .grounding .citation {
+            font-family: 'Monaco', 'Menlo', monospace;
+            font-size: 0.8rem;
+            color: #60a5fa;
+            margin-top: 2px;
+        }
+
+        .feedback-bar {
+            display: flex;
+            gap: 8px;
+            align-items: center;
+            margin-top: 10px;
+            font-size: 0.8rem;
+            color: #888;
+        }
+
+        .feedback-button {
+            background: rgba(255, 255, 255, 0.05);
+            border: 1px solid rgba(255, 255, 255, 0.1);
+            border-radius: 6px;
+            padding: 4px 10px;
+            cursor: pointer;
+            color: #e6e6e6;
+            transition: all 0.2s ease;
+        }
+
+        .feedback-button:hover,
+        .feedback-button.selected {
+            background: rgba(102, 126, 234, 0.2);
+            border-color: rgba(102, 126, 234, 0.4);
+        }
+
         .input-container {
             padding: 20px;
             background: rgba(255, 255, 255, 0.02);
@@ -300,6 +424,28 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
             align-items: flex-end;
         }
 
+        .mode-toggle {
+            display: flex;
+            align-items: center;
+            gap: 6px;
+            font-size: 0.85rem;
+            color: #a0a0a0;
+            white-space: nowrap;
+            padding-bottom: 14px;
+            cursor: pointer;
+        }
+
+        .namespace-field {
+            width: 110px;
+            margin-bottom: 14px;
+            background: rgba(255, 255, 255, 0.05);
+            border: 1px solid rgba(255, 255, 255, 0.1);
+            border-radius: 8px;
+            padding: 6px 10px;
+            color: #e6e6e6;
+            font-size: 0.85rem;
+        }
+
         .input-field {
             flex: 1;
             background: rgba(255, 255, 255, 0.05);
@@ -370,6 +516,111 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
             to { opacity: 1; transform: translateY(0); }
         }
 
+        .graph-panel {
+            display: none;
+            position: fixed;
+            inset: 5vh 5vw;
+            flex-direction: column;
+            background: #16162e;
+            border: 1px solid rgba(255, 255, 255, 0.15);
+            border-radius: 12px;
+            box-shadow: 0 10px 40px rgba(0, 0, 0, 0.6);
+            z-index: 10;
+        }
+
+        .graph-header {
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            padding: 12px 20px;
+            border-bottom: 1px solid rgba(255, 255, 255, 0.1);
+            font-size: 0.9rem;
+            color: #a0a0a0;
+        }
+
+        .graph-legend span {
+            margin-right: 12px;
+        }
+
+        .graph-svg {
+            flex: 1;
+            width: 100%;
+        }
+
+        .graph-svg circle {
+            cursor: pointer;
+            stroke: #0f0f23;
+            stroke-width: 1.5;
+        }
+
+        .graph-svg line {
+            stroke: rgba(255, 255, 255, 0.25);
+        }
+
+        .graph-svg text {
+            fill: #e6e6e6;
+            font-size: 10px;
+            pointer-events: none;
+        }
+
+        .entity-panel {
+            inset: 8vh 20vw;
+        }
+
+        .entity-body {
+            flex: 1;
+            overflow-y: auto;
+            padding: 16px 20px;
+            font-size: 0.9rem;
+        }
+
+        .entity-body h4 {
+            margin: 14px 0 6px;
+            color: #a0a0a0;
+        }
+
+        .entity-body table {
+            border-collapse: collapse;
+        }
+
+        .entity-body td {
+            padding: 2px 12px 2px 0;
+            vertical-align: top;
+        }
+
+        .entity-link {
+            color: #a5b4fc;
+            cursor: pointer;
+            text-decoration: underline dotted;
+        }
+
+        .timeline {
+            border-left: 2px solid rgba(102, 126, 234, 0.5);
+            margin-left: 6px;
+            padding-left: 14px;
+        }
+
+        .timeline-event {
+            position: relative;
+            margin: 6px 0;
+        }
+
+        .timeline-event::before {
+            content: '';
+            position: absolute;
+            left: -20px;
+            top: 6px;
+            width: 10px;
+            height: 10px;
+            border-radius: 50%;
+            background: #667eea;
+        }
+
+        .timeline-time {
+            color: #a0a0a0;
+            margin-right: 8px;
+        }
+
         .examples {
             margin-top: 20px;
             padding: 20px;
@@ -447,6 +698,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                     <span>Data Loaded</span>
                 </div>
                 <button class="load-button" id="loadButton" onclick="loadData()">📊 Load Data</button>
+                <button class="load-button" onclick="openQualityReport()">🩺 Data Quality</button>
             </div>
         </div>
 
@@ -474,11 +726,44 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                         rows="1"
                         disabled
                     ></textarea>
+                    <label class="mode-toggle" title="Let the model explore the graph with tools over several steps">
+                        <input type="checkbox" id="agentMode"> 🧭 Agent
+                    </label>
+                    <input type="text" class="namespace-field" id="namespaceInput" placeholder="namespace" title="Graph namespace to query and load; empty for the default">
                     <button type="submit" class="send-button" id="sendButton" disabled>Send</button>
                 </form>
             </div>
         </div>
 
+        <div class="graph-panel" id="graphPanel">
+            <div class="graph-header">
+                <div class="graph-legend">
+                    <span style="color: #667eea">● Character</span>
+                    <span style="color: #f59e0b">● Hero</span>
+                    <span style="color: #10b981">● Comic</span>
+                    <span id="graphInfo"></span>
+                </div>
+                <button class="feedback-button" onclick="closeGraph()">✕ Close</button>
+            </div>
+            <svg class="graph-svg" id="graphSvg"></svg>
+        </div>
+
+        <div class="graph-panel entity-panel" id="entityPanel">
+            <div class="graph-header">
+                <div id="entityTitle"></div>
+                <button class="feedback-button" onclick="entityPanel.style.display = 'none'">✕ Close</button>
+            </div>
+            <div class="entity-body" id="entityBody"></div>
+        </div>
+
+        <div class="graph-panel entity-panel" id="qualityPanel">
+            <div class="graph-header">
+                <div id="qualityTitle"></div>
+                <button class="feedback-button" onclick="qualityPanel.style.display = 'none'">✕ Close</button>
+            </div>
+            <div class="entity-body" id="qualityBody"></div>
+        </div>
+
         <div class="examples">
             <h3>💡 Example Queries</h3>
             <div class="example-queries">
@@ -488,6 +773,8 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                 <div class="example-query disabled" onclick="setQuery('How many Avengers partnerships are there?')">How many Avengers partnerships are there?</div>
                 <div class="example-query disabled" onclick="setQuery('Find Captain America')">Find Captain America</div>
                 <div class="example-query disabled" onclick="setQuery('How many Avengers are partners with Spider-Man?')">How many Avengers are partners with Spider-Man?</div>
+                <div class="example-query disabled" onclick="setQuery('Who are the members of the X-Men?')">Who are the members of the X-Men?</div>
+                <div class="example-query disabled" onclick="setQuery('Which movies does Iron Man appear in?')">Which movies does Iron Man appear in?</div>
             </div>
         </div>
     </div>
@@ -497,6 +784,8 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
         const queryForm = document.getElementById('queryForm');
         const queryInput = document.getElementById('queryInput');
         const sendButton = document.getElementById('sendButton');
+        const agentMode = document.getElementById('agentMode');
+        const namespaceInput = document.getElementById('namespaceInput');
         const loading = document.getElementById('loading');
         const loadButton = document.getElementById('loadButton');
         const neo4jStatus = document.getElementById('neo4jStatus');
@@ -508,6 +797,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
 
         // Check initial status
         checkStatus();
+        loadHistory();
 
         async function checkStatus() {
             try {
@@ -540,7 +830,7 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                 
                 addMessage('system', 'Loading Marvel Comics data into Neo4j database...');
                 
-                const response = await fetch('/api/load-data', {
+                const response = await fetch(withNamespace('/api/load-data'), {
                     method: 'POST'
                 });
                 
@@ -548,6 +838,9 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                 
                 if (data.success) {
                     addMessage('system', '✅ Data loaded successfully! You can now ask questions about Marvel characters.');
+                    if (data.quality_findings > 0) {
+                        addMessage('system', '🩺 The loader found ' + data.quality_findings + ' data-quality issues. Use "Data Quality" above to review them.');
+                    }
                     dataLoaded = true;
                     enableChat();
                     dataStatus.classList.add('connected');
@@ -579,7 +872,657 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
             queryInput.focus();
         }
 
-        function addMessage(role, content, cypher, results, error, naturalResponse) {
+        async function loadHistory() {
+            try {
+                const response = await fetch('/api/history?page_size=20');
+                const data = await response.json();
+                const entries = (data.entries || []).slice().reverse();
+                entries.forEach(entry => {
+                    addMessage('user', entry.query);
+                    addAssistantMessage(entry);
+                });
+            } catch (error) {
+                console.log('History load failed:', error);
+            }
+        }
+
+        function addAssistantMessage(data) {
+            if (data.error) {
+                addMessage('assistant', 'I encountered an error while processing your query.', data.cypher, data.results, data.error, null, data.id, data.feedback);
+            } else {
+                const cached = data.cache && (data.cache.cypher || data.cache.results || data.cache.answer) ? ' ⚡ (cached)' : '';
+                const agent = data.mode === 'agent' ? ' 🧭 (agent, ' + (data.trace || []).length + ' tool calls)' : '';
+                const messageDiv = addMessage('assistant', 'Here\'s what I found in the Marvel knowledge graph:' + cached + agent, data.cypher, data.results, null, data.response, data.id, data.feedback);
+                linkEntities(messageDiv.querySelector('.natural-response'), entityCandidates(data));
+                if (data.grounding) {
+                    addGroundingBar(messageDiv, data.grounding, data.results);
+                }
+                if (data.sources && data.sources.length > 0) {
+                    const sources = document.createElement('div');
+                    sources.className = 'grounding';
+                    sources.textContent = '📄 Sources: ' + data.sources.join(', ');
+                    messageDiv.insertBefore(sources, messageDiv.querySelector('.feedback-bar'));
+                }
+            }
+        }
+
+        function addGroundingBar(messageDiv, grounding, results) {
+            const bar = document.createElement('div');
+            bar.className = 'grounding';
+
+            const score = document.createElement('div');
+            const percent = Math.round(grounding.score * 100);
+            score.textContent = (percent === 100 ? '🛡️' : '⚠️') + ' Grounded in results: ' + percent + '%';
+            bar.appendChild(score);
+
+            // Unsupported claims, sentence by sentence
+            (grounding.sentences || []).forEach(sentence => {
+                if (!sentence.unsupported || sentence.unsupported.length === 0) return;
+                const item = document.createElement('div');
+                item.className = 'ungrounded';
+                item.textContent = '“' + sentence.text + '” - not in results: ' + sentence.unsupported.join(', ');
+                bar.appendChild(item);
+            });
+
+            // Result rows cited by the answer
+            const rows = (results || '').split('\n');
+            const cited = [];
+            (grounding.sentences || []).forEach(sentence => {
+                (sentence.rows || []).forEach(row => {
+                    if (cited.indexOf(row) === -1) cited.push(row);
+                });
+            });
+            cited.sort((a, b) => a - b).forEach(row => {
+                const item = document.createElement('div');
+                item.className = 'citation';
+                item.textContent = '[' + row + '] ' + rows[row - 1];
+                bar.appendChild(item);
+            });
+
+            messageDiv.insertBefore(bar, messageDiv.querySelector('.feedback-bar'));
+        }
+
+        function addFeedbackBar(messageDiv, entryId, feedback) {
+            const bar = document.createElement('div');
+            bar.className = 'feedback-bar';
+
+            const label = document.createElement('span');
+            label.textContent = 'Was this helpful?';
+            bar.appendChild(label);
+
+            const upButton = document.createElement('button');
+            upButton.className = 'feedback-button';
+            upButton.textContent = '👍';
+
+            const downButton = document.createElement('button');
+            downButton.className = 'feedback-button';
+            downButton.textContent = '👎';
+
+            if (feedback && feedback.rating === 'up') upButton.classList.add('selected');
+            if (feedback && feedback.rating === 'down') downButton.classList.add('selected');
+
+            upButton.onclick = () => sendFeedback(entryId, 'up', '', null, upButton, downButton);
+            downButton.onclick = () => {
+                const corrected = prompt('Optional: paste a corrected Cypher query', '');
+                if (corrected === null) return;
+                let params = null;
+                if (corrected.indexOf('$') >= 0) {
+                    const text = prompt('Parameters of the corrected query as JSON, e.g. {"name": "Thor"}', '{}');
+                    if (text === null) return;
+                    try {
+                        params = JSON.parse(text);
+                    } catch (error) {
+                        addMessage('system', '❌ Invalid parameters: ' + error.message);
+                        return;
+                    }
+                }
+                sendFeedback(entryId, 'down', corrected, params, downButton, upButton);
+            };
+
+            const saveButton = document.createElement('button');
+            saveButton.className = 'feedback-button';
+            saveButton.textContent = '⭐ Save as example';
+            saveButton.onclick = () => promoteToExample(entryId, saveButton);
+
+            bar.appendChild(upButton);
+            bar.appendChild(downButton);
+            const graphButton = document.createElement('button');
+            graphButton.className = 'feedback-button';
+            graphButton.textContent = '🕸️ Graph';
+            graphButton.onclick = () => showQueryGraph(entryId);
+
+            bar.appendChild(saveButton);
+            bar.appendChild(graphButton);
+            addExportLinks(bar, '/api/export?id=' + encodeURIComponent(entryId));
+            messageDiv.appendChild(bar);
+        }
+
+        function addExportLinks(container, url) {
+            const label = document.createElement('span');
+            label.textContent = '⬇️';
+            container.appendChild(label);
+            ['csv', 'jsonl', 'graphml'].forEach(format => {
+                const link = document.createElement('a');
+                link.className = 'feedback-button';
+                link.href = url + '&format=' + format;
+                link.textContent = format.toUpperCase();
+                link.style.textDecoration = 'none';
+                container.appendChild(link);
+            });
+        }
+
+        async function sendFeedback(entryId, rating, correctedCypher, correctedParams, selectedButton, otherButton) {
+            try {
+                const response = await fetch('/api/history/feedback', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({ id: entryId, rating: rating, corrected_cypher: correctedCypher, corrected_params: correctedParams })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                selectedButton.classList.add('selected');
+                otherButton.classList.remove('selected');
+            } catch (error) {
+                addMessage('system', '❌ Failed to save feedback: ' + error.message);
+            }
+        }
+
+        async function promoteToExample(entryId, button) {
+            try {
+                const response = await fetch('/api/history/promote', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                    },
+                    body: JSON.stringify({ id: entryId })
+                });
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                button.classList.add('selected');
+                button.disabled = true;
+            } catch (error) {
+                addMessage('system', '❌ Failed to save example: ' + error.message);
+            }
+        }
+
+        const qualityPanel = document.getElementById('qualityPanel');
+
+        // openQualityReport shows the checks from the last data load, with
+        // examples for the ones that found something.
+        async function openQualityReport() {
+            try {
+                const response = await fetch(withNamespace('/api/quality'));
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const report = await response.json();
+                document.getElementById('qualityTitle').textContent = 'Data quality: ' + report.findings + ' findings in ' +
+                    report.nodes + ' nodes and ' + report.relationships + ' relationships (' + report.generated_at + ')';
+                const body = document.getElementById('qualityBody');
+                body.innerHTML = '';
+                if (report.datasets && report.datasets.length > 0) {
+                    const details = document.createElement('details');
+                    const summary = document.createElement('summary');
+                    summary.textContent = '📦 datasets: ' + report.datasets.length + ' files loaded';
+                    details.appendChild(summary);
+                    const list = document.createElement('ul');
+                    report.datasets.forEach(dataset => {
+                        const item = document.createElement('li');
+                        item.textContent = dataset.path + ' (' + dataset.format + '): ' + dataset.nodes + ' nodes, ' +
+                            dataset.relationships + ' relationships, ' + dataset.dangling_edges + ' dangling edges (' +
+                            dataset.dangling_policy + (dataset.placeholders > 0 ? ', ' + dataset.placeholders + ' placeholders' : '') + ')' +
+                            (dataset.reverse_duplicates > 0 ? ', ' + dataset.reverse_duplicates + ' reverse duplicates merged' : '');
+                        list.appendChild(item);
+                    });
+                    details.appendChild(list);
+                    body.appendChild(details);
+                }
+                report.checks.forEach(check => {
+                    const details = document.createElement('details');
+                    const summary = document.createElement('summary');
+                    summary.textContent = (check.count > 0 ? '⚠️ ' : '✅ ') + check.name + ': ' + check.count + ' (' + check.description + ')';
+                    details.appendChild(summary);
+                    const list = document.createElement('ul');
+                    check.examples.forEach(example => {
+                        const item = document.createElement('li');
+                        item.textContent = example;
+                        list.appendChild(item);
+                    });
+                    if (check.count > check.examples.length) {
+                        const item = document.createElement('li');
+                        item.textContent = '… and ' + (check.count - check.examples.length) + ' more';
+                        list.appendChild(item);
+                    }
+                    details.appendChild(list);
+                    body.appendChild(details);
+                });
+                qualityPanel.style.display = 'flex';
+            } catch (error) {
+                addMessage('system', '❌ Failed to load the data-quality report: ' + error.message);
+            }
+        }
+
+        const entityPanel = document.getElementById('entityPanel');
+        const entityTitle = document.getElementById('entityTitle');
+        const entityBody = document.getElementById('entityBody');
+
+        // entityCandidates lists the names an answer may mention: query
+        // parameters and short result rows.
+        function entityCandidates(data) {
+            const names = [];
+            Object.values(data.params || {}).forEach(value => {
+                if (typeof value === 'string') names.push(value);
+            });
+            if (data.mode !== 'agent') {
+                (data.results || '').split('\n').forEach(row => {
+                    if (row.length > 1 && row.length <= 60 && row.indexOf('❌') !== 0) names.push(row);
+                });
+            }
+            return names;
+        }
+
+        // linkEntities turns mentions of the candidate names into links that
+        // open the entity page.
+        function linkEntities(element, names) {
+            if (!element || names.length === 0) return;
+            names = names.slice().sort((a, b) => b.length - a.length);
+            const text = element.textContent;
+            element.textContent = '';
+            let position = 0;
+            while (position < text.length) {
+                let best = -1;
+                let bestName = '';
+                names.forEach(name => {
+                    const index = text.indexOf(name, position);
+                    if (index !== -1 && (best === -1 || index < best)) {
+                        best = index;
+                        bestName = name;
+                    }
+                });
+                if (best === -1) break;
+                element.appendChild(document.createTextNode(text.slice(position, best)));
+                const link = document.createElement('span');
+                link.className = 'entity-link';
+                link.textContent = bestName;
+                link.onclick = () => openEntityById(bestName);
+                element.appendChild(link);
+                position = best + bestName.length;
+            }
+            element.appendChild(document.createTextNode(text.slice(position)));
+        }
+
+        async function openEntityById(id) {
+            try {
+                const response = await fetch(withNamespace('/api/entities?id=' + encodeURIComponent(id)));
+                const data = await response.json();
+                if (!data.entities || data.entities.length === 0) {
+                    throw new Error('no node with id ' + id);
+                }
+                openEntity(data.entities[0].label, data.entities[0].id);
+            } catch (error) {
+                addMessage('system', '❌ Failed to open entity: ' + error.message);
+            }
+        }
+
+        function entityPath(label, id) {
+            return '/api/entities/' + encodeURIComponent(label) + '/' + encodeURIComponent(id);
+        }
+
+        // withNamespace adds the chosen namespace to an API URL
+        function withNamespace(url) {
+            const namespace = namespaceInput.value.trim();
+            if (!namespace) {
+                return url;
+            }
+            return url + (url.indexOf('?') >= 0 ? '&' : '?') + 'namespace=' + encodeURIComponent(namespace);
+        }
+
+        async function openEntity(label, id) {
+            try {
+                const response = await fetch(withNamespace(entityPath(label, id)));
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const entity = await response.json();
+                entityTitle.textContent = entity.label + ': ' + entity.id + ' (' + entity.total_degree + ' relationships)';
+                entityBody.innerHTML = '';
+
+                const propertiesHeader = document.createElement('h4');
+                propertiesHeader.textContent = 'Properties';
+                entityBody.appendChild(propertiesHeader);
+                const properties = document.createElement('table');
+                Object.keys(entity.properties || {}).sort().forEach(key => {
+                    const row = properties.insertRow();
+                    row.insertCell().textContent = key;
+                    row.insertCell().textContent = JSON.stringify(entity.properties[key]);
+                });
+                entityBody.appendChild(properties);
+
+                // Degree per relationship type; click one to filter neighbors
+                const degreeHeader = document.createElement('h4');
+                degreeHeader.textContent = 'Relationships';
+                entityBody.appendChild(degreeHeader);
+                const degrees = document.createElement('table');
+                Object.keys(entity.degree || {}).sort().forEach(type => {
+                    const row = degrees.insertRow();
+                    const link = document.createElement('span');
+                    link.className = 'entity-link';
+                    link.textContent = type;
+                    link.onclick = () => loadNeighbors(entity, type, 1, neighbors);
+                    row.insertCell().appendChild(link);
+                    row.insertCell().textContent = entity.degree[type].out + ' out, ' + entity.degree[type].in + ' in';
+                });
+                entityBody.appendChild(degrees);
+
+                const exports = document.createElement('div');
+                exports.className = 'feedback-bar';
+                addExportLinks(exports, withNamespace('/api/export?node=' + encodeURIComponent(entity.id) + '&limit=1000'));
+                entityBody.appendChild(exports);
+
+                const provenance = document.createElement('div');
+                entityBody.appendChild(provenance);
+                const timeline = document.createElement('div');
+                entityBody.appendChild(timeline);
+                const neighbors = document.createElement('div');
+                entityBody.appendChild(neighbors);
+                entityPanel.style.display = 'flex';
+                loadProvenance(entity, provenance);
+                loadTimeline(entity, timeline);
+                loadNeighbors(entity, '', 1, neighbors);
+            } catch (error) {
+                addMessage('system', '❌ Failed to open entity: ' + error.message);
+            }
+        }
+
+        // loadProvenance shows the file and row an entity was loaded from, and
+        // how many of its relationships each file contributed.
+        async function loadProvenance(entity, container) {
+            const response = await fetch(withNamespace(entityPath(entity.label, entity.id) + '/provenance'));
+            if (!response.ok) return;
+            const data = await response.json();
+
+            const header = document.createElement('h4');
+            header.textContent = 'Provenance';
+            container.appendChild(header);
+            const source = document.createElement('div');
+            source.textContent = data.source.file
+                ? '📄 ' + data.source.file + ', row ' + data.source.row + ' (import ' + data.source.import_id + ')' + (data.placeholder ? ' - placeholder' : '')
+                : '📄 No provenance recorded; reload the data to stamp it';
+            container.appendChild(source);
+
+            const files = {};
+            data.relationships.forEach(relationship => {
+                const file = relationship.source.file || 'unknown';
+                files[file] = (files[file] || 0) + 1;
+            });
+            const table = document.createElement('table');
+            Object.keys(files).sort().forEach(file => {
+                const row = table.insertRow();
+                row.insertCell().textContent = file;
+                row.insertCell().textContent = files[file] + ' relationships';
+            });
+            container.appendChild(table);
+        }
+
+        // loadTimeline lists the dated relationships of an entity, such as
+        // team memberships and movie appearances, oldest first.
+        async function loadTimeline(entity, container) {
+            const response = await fetch(withNamespace(entityPath(entity.label, entity.id) + '/timeline'));
+            if (!response.ok) return;
+            const data = await response.json();
+            if (!data.events || data.events.length === 0) return;
+
+            const header = document.createElement('h4');
+            header.textContent = 'Timeline (' + data.events.length + ')';
+            container.appendChild(header);
+            const list = document.createElement('div');
+            list.className = 'timeline';
+            data.events.forEach(event => {
+                const item = document.createElement('div');
+                item.className = 'timeline-event';
+                const time = document.createElement('span');
+                time.className = 'timeline-time';
+                time.textContent = event.time;
+                item.appendChild(time);
+                item.appendChild(document.createTextNode((event.direction === 'out' ? '→ ' : '← ') + event.relationship + ' '));
+                const link = document.createElement('span');
+                link.className = 'entity-link';
+                link.textContent = event.id;
+                link.onclick = () => openEntity(event.label, event.id);
+                item.appendChild(link);
+                item.title = event.property;
+                list.appendChild(item);
+            });
+            container.appendChild(list);
+        }
+
+        async function loadNeighbors(entity, relationship, page, container) {
+            const response = await fetch(withNamespace(entityPath(entity.label, entity.id) + '/neighbors?rel=' + encodeURIComponent(relationship) + '&page=' + page));
+            const data = await response.json();
+            const pages = Math.max(1, Math.ceil(data.total / data.page_size));
+            container.innerHTML = '';
+
+            const header = document.createElement('h4');
+            header.textContent = 'Neighbors' + (relationship ? ' via ' + relationship : '') + ' (' + data.total + ')';
+            container.appendChild(header);
+
+            const list = document.createElement('table');
+            (data.neighbors || []).forEach(neighbor => {
+                const row = list.insertRow();
+                row.insertCell().textContent = (neighbor.direction === 'out' ? '→ ' : '← ') + neighbor.relationship;
+                const link = document.createElement('span');
+                link.className = 'entity-link';
+                link.textContent = neighbor.id;
+                link.onclick = () => openEntity(neighbor.label, neighbor.id);
+                row.insertCell().appendChild(link);
+                row.insertCell().textContent = neighbor.label;
+            });
+            container.appendChild(list);
+
+            const pager = document.createElement('div');
+            pager.className = 'feedback-bar';
+            const previous = document.createElement('button');
+            previous.className = 'feedback-button';
+            previous.textContent = '← Previous';
+            previous.disabled = page <= 1;
+            previous.onclick = () => loadNeighbors(entity, relationship, page - 1, container);
+            const next = document.createElement('button');
+            next.className = 'feedback-button';
+            next.textContent = 'Next →';
+            next.disabled = page >= pages;
+            next.onclick = () => loadNeighbors(entity, relationship, page + 1, container);
+            const position = document.createElement('span');
+            position.textContent = 'Page ' + page + ' of ' + pages;
+            pager.appendChild(previous);
+            pager.appendChild(position);
+            pager.appendChild(next);
+            container.appendChild(pager);
+        }
+
+        const graphPanel = document.getElementById('graphPanel');
+        const graphSvg = document.getElementById('graphSvg');
+        const graphInfo = document.getElementById('graphInfo');
+        const svgNS = 'http://www.w3.org/2000/svg';
+        const labelColors = { Character: '#667eea', Hero: '#f59e0b', Comic: '#10b981' };
+        const maxRenderedNodes = 150;
+        let graphNodes = [];
+        let graphEdges = [];
+        let graphAnimation = null;
+
+        async function showQueryGraph(entryId) {
+            try {
+                const response = await fetch('/api/graph?id=' + encodeURIComponent(entryId));
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                const data = await response.json();
+                graphSvg.innerHTML = '';
+                graphNodes = [];
+                graphEdges = [];
+                graphPanel.style.display = 'flex';
+                mergeGraph(data, null);
+            } catch (error) {
+                addMessage('system', '❌ Failed to load graph: ' + error.message);
+            }
+        }
+
+        function closeGraph() {
+            cancelAnimationFrame(graphAnimation);
+            graphPanel.style.display = 'none';
+        }
+
+        async function expandNode(node) {
+            try {
+                const response = await fetch(withNamespace('/api/graph?node=' + encodeURIComponent(node.id) + '&limit=25'));
+                if (!response.ok) {
+                    throw new Error(await response.text());
+                }
+                mergeGraph(await response.json(), node);
+            } catch (error) {
+                graphInfo.textContent = '❌ ' + error.message;
+            }
+        }
+
+        // mergeGraph adds new nodes and edges, stopping at maxRenderedNodes so
+        // hubs do not swamp the view.
+        function mergeGraph(data, origin) {
+            const byId = {};
+            graphNodes.forEach(node => byId[node.id] = node);
+            let hidden = 0;
+
+            data.nodes.forEach(item => {
+                if (byId[item.id]) return;
+                if (graphNodes.length >= maxRenderedNodes) {
+                    hidden++;
+                    return;
+                }
+                const node = {
+                    id: item.id,
+                    label: item.label,
+                    degree: item.degree,
+                    x: (origin ? origin.x : 0) + Math.random() * 60 - 30,
+                    y: (origin ? origin.y : 0) + Math.random() * 60 - 30,
+                    vx: 0,
+                    vy: 0
+                };
+                node.circle = document.createElementNS(svgNS, 'circle');
+                node.circle.setAttribute('r', 5 + Math.min(Math.log(item.degree + 1) * 1.5, 10));
+                node.circle.setAttribute('fill', labelColors[item.label] || '#a0a0a0');
+                node.circle.onclick = () => expandNode(node);
+                node.circle.ondblclick = () => openEntity(node.label, node.id);
+                const title = document.createElementNS(svgNS, 'title');
+                title.textContent = item.label + ': ' + item.id + ' (' + item.degree + ' relationships) - click to expand, double-click for details';
+                node.circle.appendChild(title);
+                node.text = document.createElementNS(svgNS, 'text');
+                node.text.textContent = item.id;
+                graphNodes.push(node);
+                byId[item.id] = node;
+            });
+
+            data.edges.forEach(item => {
+                const source = byId[item.source];
+                const target = byId[item.target];
+                if (!source || !target) return;
+                if (graphEdges.some(edge => edge.source === source && edge.target === target && edge.type === item.type)) return;
+                const line = document.createElementNS(svgNS, 'line');
+                const title = document.createElementNS(svgNS, 'title');
+                title.textContent = item.type;
+                line.appendChild(title);
+                graphEdges.push({ source: source, target: target, type: item.type, line: line });
+            });
+
+            // Edges first so nodes are drawn on top
+            graphSvg.innerHTML = '';
+            graphEdges.forEach(edge => graphSvg.appendChild(edge.line));
+            graphNodes.forEach(node => {
+                graphSvg.appendChild(node.circle);
+                graphSvg.appendChild(node.text);
+            });
+
+            let info = graphNodes.length + ' nodes, ' + graphEdges.length + ' relationships. Click a node to expand it.';
+            if (data.truncated || hidden > 0) {
+                info += ' Some neighbors are hidden to keep hubs readable.';
+            }
+            graphInfo.textContent = info;
+            runLayout();
+        }
+
+        function runLayout() {
+            cancelAnimationFrame(graphAnimation);
+            let ticks = 0;
+            const step = () => {
+                layoutTick();
+                drawGraph();
+                if (++ticks < 300) {
+                    graphAnimation = requestAnimationFrame(step);
+                }
+            };
+            step();
+        }
+
+        // layoutTick is one step of a simple force simulation: nodes repel,
+        // edges pull like springs and everything drifts to the center.
+        function layoutTick() {
+            for (let i = 0; i < graphNodes.length; i++) {
+                for (let j = i + 1; j < graphNodes.length; j++) {
+                    const a = graphNodes[i];
+                    const b = graphNodes[j];
+                    const dx = a.x - b.x;
+                    const dy = a.y - b.y;
+                    const distance2 = Math.max(dx * dx + dy * dy, 25);
+                    const force = 800 / distance2;
+                    a.vx += dx * force / Math.sqrt(distance2);
+                    a.vy += dy * force / Math.sqrt(distance2);
+                    b.vx -= dx * force / Math.sqrt(distance2);
+                    b.vy -= dy * force / Math.sqrt(distance2);
+                }
+            }
+            graphEdges.forEach(edge => {
+                const dx = edge.target.x - edge.source.x;
+                const dy = edge.target.y - edge.source.y;
+                const distance = Math.max(Math.sqrt(dx * dx + dy * dy), 1);
+                const force = (distance - 80) * 0.02;
+                edge.source.vx += dx / distance * force;
+                edge.source.vy += dy / distance * force;
+                edge.target.vx -= dx / distance * force;
+                edge.target.vy -= dy / distance * force;
+            });
+            graphNodes.forEach(node => {
+                node.vx = (node.vx - node.x * 0.005) * 0.85;
+                node.vy = (node.vy - node.y * 0.005) * 0.85;
+                node.x += node.vx;
+                node.y += node.vy;
+            });
+        }
+
+        function drawGraph() {
+            if (graphNodes.length === 0) return;
+            let minX = Infinity, minY = Infinity, maxX = -Infinity, maxY = -Infinity;
+            graphNodes.forEach(node => {
+                minX = Math.min(minX, node.x);
+                minY = Math.min(minY, node.y);
+                maxX = Math.max(maxX, node.x);
+                maxY = Math.max(maxY, node.y);
+                node.circle.setAttribute('cx', node.x);
+                node.circle.setAttribute('cy', node.y);
+                node.text.setAttribute('x', node.x + 10);
+                node.text.setAttribute('y', node.y + 4);
+            });
+            graphEdges.forEach(edge => {
+                edge.line.setAttribute('x1', edge.source.x);
+                edge.line.setAttribute('y1', edge.source.y);
+                edge.line.setAttribute('x2', edge.target.x);
+                edge.line.setAttribute('y2', edge.target.y);
+            });
+            const padding = 60;
+            graphSvg.setAttribute('viewBox', (minX - padding) + ' ' + (minY - padding) + ' ' + (maxX - minX + 2 * padding) + ' ' + (maxY - minY + 2 * padding));
+        }
+
+        function addMessage(role, content, cypher, results, error, naturalResponse, entryId, feedback) {
             const messageDiv = document.createElement('div');
             messageDiv.className = 'message ' + role;
             
@@ -643,9 +1586,14 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                 resultsDiv.textContent = results;
                 messageDiv.appendChild(resultsDiv);
             }
+
+            if (entryId) {
+                addFeedbackBar(messageDiv, entryId, feedback);
+            }
             
             chatMessages.appendChild(messageDiv);
             chatMessages.scrollTop = chatMessages.scrollHeight;
+            return messageDiv;
         }
 
         async function sendQuery(query) {
@@ -658,16 +1606,12 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
                     headers: {
                         'Content-Type': 'application/json',
                     },
-                    body: JSON.stringify({ query: query })
+                    body: JSON.stringify({ query: query, mode: agentMode.checked ? 'agent' : '', namespace: namespaceInput.value.trim() })
                 });
                 
                 const data = await response.json();
                 
-                if (data.error) {
-                    addMessage('assistant', 'I encountered an error while processing your query.', data.cypher, null, data.error);
-                } else {
-                    addMessage('assistant', 'Here\'s what I found in the Marvel knowledge graph:', data.cypher, data.results, null, data.response);
-                }
+                addAssistantMessage(data);
             } catch (error) {
                 addMessage('assistant', 'Sorry, I encountered an error while processing your request.', null, null, error.message);
             } finally {
@@ -718,34 +1662,411 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate Cypher query using LLM
-	cypherQuery, err := generateCypherQuery(llm, req.Query, schema)
+	ns, err := lookupNamespace(req.Namespace)
 	if err != nil {
-		response := QueryResponse{
-			Query:     req.Query,
-			Cypher:    "",
-			Results:   "",
-			Error:     fmt.Sprintf("Failed to generate query: %v", err),
-			Timestamp: getCurrentTimestamp(),
-		}
-		json.NewEncoder(w).Encode(response)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	var response QueryResponse
+	switch req.Mode {
+	case "":
+		response = runQueryPipeline(req.Query, ns)
+	case queryModeAgent:
+		response = runAgentPipeline(req.Query, ns)
+	default:
+		http.Error(w, fmt.Sprintf("unknown query mode %q", req.Mode), http.StatusBadRequest)
+		return
+	}
+
+	// Persist to query history
+	if historyStore != nil {
+		if err := historyStore.Append(response); err != nil {
+			log.Printf("Failed to record query history: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// initQueryPipeline connects to Neo4j and sets up the models, examples and
+// prompts used by runQueryPipeline. Callers close driver when done.
+func initQueryPipeline() {
+	// Initialize Neo4j connection
+	var err error
+	driver, err = neo4j.NewDriver("bolt://localhost:7687", neo4j.BasicAuth("neo4j", "", ""))
+	if err != nil {
+		log.Fatalf("Failed to create Neo4j driver: %v", err)
+	}
+
+	// Initialize LLMs for Cypher generation and answers
+	cypherLLM, answerLLM, modelNames, err = newRoleLLMs()
+	if err != nil {
+		log.Fatalf("Failed to create LLM: %v", err)
+	}
+	fmt.Printf("🧠 Cypher model: %s, answer model: %s\n", modelNames.Cypher, modelNames.Answer)
+
+	// Load few-shot example library
+	if _, err := namespaceExamples(""); err != nil {
+		log.Fatalf("Failed to load example library: %v", err)
+	}
+
+	// Load prompt templates
+	promptStore, err = loadPromptStore(promptDir)
+	if err != nil {
+		log.Fatalf("Failed to load prompt templates: %v", err)
+	}
+
+	// Get the default namespace's graph schema; others are read on first use
+	if _, err := refreshNamespace(""); err != nil {
+		log.Printf("Failed to read graph schema: %v", err)
+	}
+}
+
+// runQueryPipeline answers one question against a namespace's graph: Cypher
+// generation, execution and the natural-language answer, with per-stage
+// latencies.
+func runQueryPipeline(question string, ns *GraphNamespace) (response QueryResponse) {
+	start := time.Now()
+	response = QueryResponse{
+		ID:        newHistoryID(),
+		Query:     question,
+		Namespace: ns.Name,
+		Models:    modelNames,
+	}
+	defer func() {
+		response.Latency.TotalMs = time.Since(start).Milliseconds()
+		response.Timestamp = getCurrentTimestamp()
+	}()
+
+	// Pick prompt versions for this request
+	cypherPrompt := promptStore.Select(promptKindCypher, response.ID)
+	answerPrompt := promptStore.Select(promptKindAnswer, response.ID)
+	response.Prompts = PromptVersions{Cypher: cypherPrompt.Version, Answer: answerPrompt.Version}
+
+	// Generate Cypher query using LLM with the closest curated examples
+	library, err := namespaceExamples(ns.Name)
+	if err != nil {
+		response.Error = fmt.Sprintf("Failed to load examples: %v", err)
+		return response
+	}
+	examples := library.TopK(question, fewShotExampleCount)
+	cypherKey := LLMCacheKey{
+		Role:              llmRoleCypher,
+		Model:             modelNames.Cypher,
+		PromptVersion:     cypherPrompt.Version,
+		SchemaFingerprint: ns.Fingerprint,
+		Question:          normalizeQuestion(question),
+		Context:           fingerprint(formatExamples(examples)),
+	}
+	var cypherQuery CypherQuery
+	completion, cached := cachedCompletion(cypherKey)
+	if cached {
+		var err error
+		if cypherQuery, err = parseGeneratedCypher(completion, ns.Symmetric); err != nil {
+			cached = false
+		}
+	}
+	if !cached {
+		var err error
+		cypherQuery, completion, err = generateCypherQuery(cypherLLM, cypherPrompt, question, ns, examples)
+		// An invalid query goes back to the model with what is wrong with it
+		for err != nil && completion != "" && response.RepairAttempts < maxRepairAttempts {
+			cypherQuery, completion, err = repairQuery(&response, question, ns, completion, err.Error())
+		}
+		if err != nil {
+			response.Latency.CypherMs = time.Since(start).Milliseconds()
+			response.Error = fmt.Sprintf("Failed to generate query: %v", err)
+			return response
+		}
+	}
+	response.Cache.Cypher = cached
+	response.Latency.CypherMs = time.Since(start).Milliseconds()
+	response.Cypher = cypherQuery.Cypher
+	response.Params = cypherQuery.Params
+
 	// Execute query and get results
-	results := executeQuery(driver, cypherQuery)
+	stepStart := time.Now()
+	response.Results, response.Cache.Results = executeGraphQuery(ns.Name, cypherQuery.Cypher, cypherQuery.Params)
+	// So does one the database rejects
+	for strings.HasPrefix(response.Results, queryErrorPrefix) && response.RepairAttempts < maxRepairAttempts {
+		repaired, _, err := repairQuery(&response, question, ns, describeCypher(cypherQuery), response.Results)
+		if err != nil {
+			break
+		}
+		cypherQuery = repaired
+		response.Cypher = cypherQuery.Cypher
+		response.Params = cypherQuery.Params
+		response.Results, response.Cache.Results = executeGraphQuery(ns.Name, cypherQuery.Cypher, cypherQuery.Params)
+	}
+	// Only a query that ran is cached, so a cache hit never needs repairing
+	// again; a cached one the database still rejects is dropped.
+	if !strings.HasPrefix(response.Results, queryErrorPrefix) {
+		if !cached || response.RepairAttempts > 0 {
+			encoded, _ := json.Marshal(cypherQuery)
+			storeCompletion(cypherKey, string(encoded))
+		}
+	} else if cached {
+		deleteCompletion(cypherKey)
+	}
+	response.Latency.ExecutionMs = time.Since(stepStart).Milliseconds()
+	response.Sources = querySources(cypherQuery.Cypher, ns.Sources)
 
 	// Generate natural language response
-	naturalResponse := generateNaturalResponse(llm, req.Query, cypherQuery, results)
+	stepStart = time.Now()
+	answerKey := LLMCacheKey{
+		Role:              llmRoleAnswer,
+		Model:             modelNames.Answer,
+		PromptVersion:     answerPrompt.Version,
+		SchemaFingerprint: ns.Fingerprint,
+		Question:          normalizeQuestion(question),
+		Context:           fingerprint(response.Cypher + "\x00" + response.Results + "\x00" + strings.Join(response.Sources, ",")),
+	}
+	answer, cached := cachedCompletion(answerKey)
+	if !cached {
+		answer = generateNaturalResponse(answerLLM, answerPrompt, question, describeCypher(cypherQuery), response.Results, response.Sources)
+		if answer != fallbackResponse(response.Results) {
+			storeCompletion(answerKey, answer)
+		}
+	}
+	response.Cache.Answer = cached
+	response.Response = answer
+	if answer != fallbackResponse(response.Results) {
+		response.Grounding = checkGrounding(answer, response.Results, question)
+	}
+	response.Latency.ResponseMs = time.Since(stepStart).Milliseconds()
+
+	return response
+}
+
+// repairQuery makes one repair attempt for a pipeline response.
+func repairQuery(response *QueryResponse, question string, ns *GraphNamespace, failed, problem string) (CypherQuery, string, error) {
+	repairPrompt := promptStore.Select(promptKindRepair, response.ID)
+	response.Prompts.Repair = repairPrompt.Version
+	response.RepairAttempts++
+	return repairCypherQuery(cypherLLM, repairPrompt, question, ns, failed, problem)
+}
+
+func cachedCompletion(key LLMCacheKey) (string, bool) {
+	if llmCache == nil {
+		return "", false
+	}
+	return llmCache.Get(key)
+}
+
+func storeCompletion(key LLMCacheKey, completion string) {
+	if llmCache != nil {
+		llmCache.Put(key, completion)
+	}
+}
+
+func deleteCompletion(key LLMCacheKey) {
+	if llmCache != nil {
+		llmCache.Delete(key)
+	}
+}
+
+func handleCacheStats(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{}
+	if llmCache != nil {
+		response["llm"] = llmCache.Stats()
+	}
+	response["results"] = resultCache.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	entries, total := historyStore.Search(r.URL.Query().Get("q"), r.URL.Query().Get("rating"), page, pageSize)
+	response := map[string]interface{}{
+		"entries":   entries,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
 
-	response := QueryResponse{
-		Query:     req.Query,
-		Cypher:    cypherQuery,
-		Results:   results,
-		Response:  naturalResponse,
-		Timestamp: getCurrentTimestamp(),
+func handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
+	var req FeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Rating != "up" && req.Rating != "down" {
+		http.Error(w, "rating must be 'up' or 'down'", http.StatusBadRequest)
+		return
+	}
+	correctedCypher := strings.TrimSpace(req.CorrectedCypher)
+	if correctedCypher == "" && len(req.CorrectedParams) > 0 {
+		http.Error(w, "corrected_params need a corrected_cypher", http.StatusBadRequest)
+		return
+	}
+
+	found, err := historyStore.SetFeedback(req.ID, Feedback{
+		Rating:          req.Rating,
+		CorrectedCypher: correctedCypher,
+		CorrectedParams: req.CorrectedParams,
+		Comment:         strings.TrimSpace(req.Comment),
+		Timestamp:       getCurrentTimestamp(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "History entry not found", http.StatusNotFound)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handleExamples(w http.ResponseWriter, r *http.Request) {
+	ns, err := requestNamespace(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, err := namespaceExamples(ns.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(library.All())
+	case http.MethodPost:
+		var example CypherExample
+		if err := json.NewDecoder(r.Body).Decode(&example); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if example.Source == "" {
+			example.Source = "manual"
+		}
+		if err := library.Add(example); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response := map[string]interface{}{
+			"success": true,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handlePromoteHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, ok := historyStore.Get(req.ID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("history entry %s not found", req.ID), http.StatusNotFound)
+		return
+	}
+
+	// Only queries a user vouched for are verified examples: a 👍, or a
+	// correction, which is preferred over the generated query
+	example := CypherExample{Question: entry.Query, Cypher: entry.Cypher, Params: entry.Params, Source: "history:" + entry.ID}
+	switch {
+	case entry.Feedback != nil && entry.Feedback.CorrectedCypher != "":
+		example.Cypher = entry.Feedback.CorrectedCypher
+		example.Params = entry.Feedback.CorrectedParams
+	case entry.Feedback == nil || entry.Feedback.Rating != "up":
+		http.Error(w, "only queries rated 👍 or given a corrected query can be saved as examples", http.StatusBadRequest)
+		return
+	}
+
+	// The example goes to the library of the graph the query ran against
+	if _, err := lookupNamespace(entry.Namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, err := namespaceExamples(entry.Namespace)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := library.Add(example); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"example": example,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func handlePrompts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promptStore.Describe())
+}
+
+func handleReloadPrompts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := promptStore.Reload(); err != nil {
+		response := map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+		"prompts": promptStore.Describe(),
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -753,7 +2074,7 @@ func handleQuery(w http.ResponseWriter, r *http.Request) {
 func handleStatus(w http.ResponseWriter, r *http.Request) {
 	response := map[string]bool{
 		"neo4j_connected": driver != nil,
-		"llm_connected":   llm != nil,
+		"llm_connected":   cypherLLM != nil && answerLLM != nil,
 		"data_loaded":     dataLoaded,
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -766,13 +2087,44 @@ func handleLoadData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	namespace := r.URL.Query().Get("namespace")
+	if err := validateNamespace(namespace); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Load data into Neo4j
-	loadDataToNeo4j()
+	report, err := loadDataToNeo4j(namespace)
+	if err != nil {
+		log.Printf("Data load failed: %v", err)
+		if _, err := refreshNamespace(namespace); err != nil {
+			log.Printf("Failed to read graph schema: %v", err)
+		}
+		resultCache.Clear()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
 
-	dataLoaded = true
+	// The graph changed: refresh the schema and drop stale completions and results
+	if _, err := refreshNamespace(namespace); err != nil {
+		log.Printf("Failed to read graph schema: %v", err)
+	}
+	resultCache.Clear()
+	if llmCache != nil {
+		if err := llmCache.Invalidate(); err != nil {
+			log.Printf("Failed to invalidate LLM cache: %v", err)
+		}
+	}
+
+	if namespace == "" {
+		dataLoaded = true
+	}
 	response := map[string]interface{}{
-		"success": true,
-		"message": "Data loaded successfully",
+		"success":          true,
+		"message":          "Data loaded successfully",
+		"quality_findings": report.Findings,
+		"datasets":         report.Datasets,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -799,38 +2151,38 @@ func checkIfDataExists(driver neo4j.Driver) bool {
 	return false
 }
 
-func generateNaturalResponse(llm llms.Model, userQuery, cypherQuery, results string) string {
-	prompt := fmt.Sprintf(`You are a helpful assistant that explains Marvel Comics knowledge graph results in natural language.
-
-User Question: "%s"
-Cypher Query Executed: %s
-Graph Database Results: %s
-
-Generate a natural, conversational response that:
-1. Directly answers the user's question
-2. Explains the results in a friendly, engaging way
-3. Highlights key relationships and connections
-4. Uses Marvel Comics terminology appropriately
-5. Keeps the response concise but informative
-6. If no results found, explain what the user might try instead
-
-Write a natural response as if you're a knowledgeable Marvel Comics expert:`, userQuery, cypherQuery, results)
+func generateNaturalResponse(llm llms.Model, promptTemplate *PromptTemplate, userQuery, cypherQuery, results string, sources []string) string {
+	prompt, err := promptTemplate.Render(map[string]interface{}{
+		"Question": userQuery,
+		"Cypher":   cypherQuery,
+		"Results":  results,
+		"Rows":     numberRows(results),
+		"Sources":  formatSources(sources),
+	})
+	if err != nil {
+		log.Printf("Prompt error: %v", err)
+		return fallbackResponse(results)
+	}
 
 	ctx := context.Background()
 	response, err := llm.GenerateContent(ctx, []llms.MessageContent{
 		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
 	})
 	if err != nil {
-		return fmt.Sprintf("I found some information in the Marvel knowledge graph, but I couldn't generate a natural response. Here are the raw results: %s", results)
+		return fallbackResponse(results)
 	}
 
 	if len(response.Choices) == 0 {
-		return fmt.Sprintf("I found some information in the Marvel knowledge graph, but I couldn't generate a natural response. Here are the raw results: %s", results)
+		return fallbackResponse(results)
 	}
 
 	return strings.TrimSpace(response.Choices[0].Content)
 }
 
+func fallbackResponse(results string) string {
+	return fmt.Sprintf("I found some information in the Marvel knowledge graph, but I couldn't generate a natural response. Here are the raw results: %s", results)
+}
+
 func getCurrentTimestamp() string {
 	return fmt.Sprintf("%d", time.Now().Unix())
 }
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateNaturalResponse(t *testing.T) {
+	prompts := testPromptStore(t)
+
+	tests := []struct {
+		name       string
+		question   string
+		results    string
+		completion string
+		want       string
+	}{
+		{
+			name:       "answer",
+			question:   "Who are Thor's partners?",
+			results:    "Hulk\nIron Man",
+			completion: "Thor has partnered with Hulk and Iron Man.",
+			want:       "Thor has partnered with Hulk and Iron Man.",
+		},
+		{
+			name:     "model unavailable falls back to raw results",
+			question: "Who are Hulk's partners?",
+			results:  "Thor",
+			want:     "I found some information in the Marvel knowledge graph, but I couldn't generate a natural response. Here are the raw results: Thor",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			model := fixtureModel(t, tc.completion)
+			got := generateNaturalResponse(model, prompts.Select(promptKindAnswer, ""), tc.question, "MATCH (n) RETURN n", tc.results, []string{"marvel_characters_partnerships/edges.csv"})
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleQuery(t *testing.T) {
+	library, err := loadExampleLibrary("testdata/examples.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedPrompts, savedLibraries, savedNamespaces, savedHistory := promptStore, exampleLibraries, namespaces, historyStore
+	savedCypherLLM, savedAnswerLLM, savedExecute := cypherLLM, answerLLM, executeGraphQuery
+	t.Cleanup(func() {
+		promptStore, exampleLibraries, namespaces, historyStore = savedPrompts, savedLibraries, savedNamespaces, savedHistory
+		cypherLLM, answerLLM, executeGraphQuery = savedCypherLLM, savedAnswerLLM, savedExecute
+	})
+
+	promptStore = testPromptStore(t)
+	exampleLibraries = map[string]*ExampleLibrary{"": library, "experiments": library}
+	namespaces = map[string]*GraphNamespace{
+		"":            {Schema: "test schema"},
+		"experiments": {Name: "experiments", Schema: "test schema"},
+	}
+	historyStore = nil
+
+	tests := []struct {
+		name         string
+		question     string
+		namespace    string
+		completion   string
+		repair       string
+		repairs      int
+		cypher       string
+		params       map[string]interface{}
+		results      string
+		answer       string
+		wantError    string
+		wantResponse string
+	}{
+		{
+			name:         "full pipeline",
+			question:     "Who are Spider-Man's partners?",
+			completion:   `{"cypher": "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10", "params": {"name": "Spider-Man"}}`,
+			cypher:       "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10",
+			params:       map[string]interface{}{"name": "Spider-Man"},
+			results:      "Black Cat\nSilver Sable",
+			answer:       "Spider-Man has teamed up with Black Cat and Silver Sable.",
+			wantResponse: "Spider-Man has teamed up with Black Cat and Silver Sable.",
+		},
+		{
+			name:         "namespace",
+			question:     "Who are Spider-Man's partners?",
+			namespace:    "experiments",
+			completion:   `{"cypher": "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10", "params": {"name": "Spider-Man"}}`,
+			cypher:       "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10",
+			params:       map[string]interface{}{"name": "Spider-Man"},
+			results:      "Black Cat\nSilver Sable",
+			answer:       "Spider-Man has teamed up with Black Cat and Silver Sable.",
+			wantResponse: "Spider-Man has teamed up with Black Cat and Silver Sable.",
+		},
+		{
+			name:         "repaired",
+			question:     "Who are Black Cat's partners?",
+			completion:   `{"cypher": "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10", "params": {}}`,
+			repair:       `{"cypher": "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10", "params": {"name": "Black Cat"}}`,
+			repairs:      1,
+			cypher:       "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]->(p) RETURN p.id as result LIMIT 10",
+			params:       map[string]interface{}{"name": "Black Cat"},
+			results:      "Spider-Man",
+			answer:       "Black Cat has partnered with Spider-Man.",
+			wantResponse: "Black Cat has partnered with Spider-Man.",
+		},
+		{
+			name:       "invalid cypher",
+			question:   "Sing me a song",
+			completion: "I can only answer questions about the graph.",
+			wantError:  "Failed to generate query",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cypherLLM = fixtureModel(t, tc.completion, tc.repair)
+			answerLLM = fixtureModel(t, tc.answer)
+			var executed, executedNamespace string
+			var executedParams map[string]interface{}
+			executeGraphQuery = func(namespace, cypherQuery string, params map[string]interface{}) (string, bool) {
+				executed, executedNamespace = cypherQuery, namespace
+				executedParams = params
+				return tc.results, false
+			}
+
+			body, _ := json.Marshal(QueryRequest{Query: tc.question, Namespace: tc.namespace})
+			recorder := httptest.NewRecorder()
+			handleQuery(recorder, httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(string(body))))
+
+			var response QueryResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if response.ID == "" || response.Prompts.Cypher == "" || response.Timestamp == "" {
+				t.Errorf("response missing id, prompt versions or timestamp: %+v", response)
+			}
+			if tc.wantError != "" {
+				if !strings.Contains(response.Error, tc.wantError) {
+					t.Fatalf("error = %q, want it to contain %q", response.Error, tc.wantError)
+				}
+				if executed != "" {
+					t.Errorf("query should not have been executed, ran %q", executed)
+				}
+				if response.RepairAttempts != maxRepairAttempts {
+					t.Errorf("repair attempts = %d, want %d", response.RepairAttempts, maxRepairAttempts)
+				}
+				return
+			}
+			if response.Error != "" {
+				t.Fatalf("unexpected error: %s", response.Error)
+			}
+			if executed != tc.cypher || response.Cypher != tc.cypher {
+				t.Errorf("executed %q, response cypher %q, want %q", executed, response.Cypher, tc.cypher)
+			}
+			if response.RepairAttempts != tc.repairs {
+				t.Errorf("repair attempts = %d, want %d", response.RepairAttempts, tc.repairs)
+			}
+			if executedNamespace != tc.namespace || response.Namespace != tc.namespace {
+				t.Errorf("executed in %q, response namespace %q, want %q", executedNamespace, response.Namespace, tc.namespace)
+			}
+			if !reflect.DeepEqual(executedParams, tc.params) || !reflect.DeepEqual(response.Params, tc.params) {
+				t.Errorf("executed params %v, response params %v, want %v", executedParams, response.Params, tc.params)
+			}
+			if response.Results != tc.results {
+				t.Errorf("results = %q, want %q", response.Results, tc.results)
+			}
+			if response.Response != tc.wantResponse {
+				t.Errorf("response = %q, want %q", response.Response, tc.wantResponse)
+			}
+			if response.Grounding == nil || response.Grounding.Score != 1 {
+				t.Errorf("grounding = %+v, want a fully grounded answer", response.Grounding)
+			}
+		})
+	}
+}
+
+func TestRunQueryPipelineCachesExecutedQuery(t *testing.T) {
+	library, err := loadExampleLibrary("testdata/examples.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedPrompts, savedLibraries, savedCache := promptStore, exampleLibraries, llmCache
+	savedCypherLLM, savedAnswerLLM, savedExecute := cypherLLM, answerLLM, executeGraphQuery
+	t.Cleanup(func() {
+		promptStore, exampleLibraries, llmCache = savedPrompts, savedLibraries, savedCache
+		cypherLLM, answerLLM, executeGraphQuery = savedCypherLLM, savedAnswerLLM, savedExecute
+	})
+
+	promptStore = testPromptStore(t)
+	exampleLibraries = map[string]*ExampleLibrary{"": library}
+	cache, err := openLLMCache(filepath.Join(t.TempDir(), "llm_cache.jsonl"), 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	llmCache = cache
+
+	const (
+		question = "Who has Hulk partnered with?"
+		rejected = "MATCH (c:Character {name: $name})-[:PARTNERS_WITH]-(p) RETURN p.id AS result"
+		repaired = "MATCH (c:Character {id: $name})-[:PARTNERS_WITH]-(p) RETURN p.id AS result"
+	)
+	ns := &GraphNamespace{Schema: "test schema"}
+	accepted := map[string]bool{repaired: true}
+	executeGraphQuery = func(namespace, cypherQuery string, params map[string]interface{}) (string, bool) {
+		if !accepted[cypherQuery] {
+			return queryErrorPrefix + ": Unknown property key: name", false
+		}
+		return "She-Hulk", false
+	}
+	answerLLM = fixtureModel(t, "Hulk has partnered with She-Hulk.")
+
+	// The generated query fails in Neo4j; the repaired one is what gets cached
+	generate := func() QueryResponse {
+		cypherLLM = fixtureModel(t,
+			`{"cypher": "`+rejected+`", "params": {"name": "Hulk"}}`,
+			`{"cypher": "`+repaired+`", "params": {"name": "Hulk"}}`)
+		return runQueryPipeline(question, ns)
+	}
+	if response := generate(); response.Cache.Cypher || response.Cypher != repaired || response.RepairAttempts != 1 {
+		t.Fatalf("first run: cached %v, cypher %q after %d repairs", response.Cache.Cypher, response.Cypher, response.RepairAttempts)
+	}
+	response := runQueryPipeline(question, ns)
+	if !response.Cache.Cypher || response.Cypher != repaired || response.RepairAttempts != 0 {
+		t.Fatalf("second run: cached %v, cypher %q after %d repairs", response.Cache.Cypher, response.Cypher, response.RepairAttempts)
+	}
+
+	// Once the database rejects the cached query and no repair helps, the
+	// entry is dropped so the next run generates afresh
+	accepted = map[string]bool{}
+	cypherLLM = fixtureModel(t, `{"cypher": "`+repaired+`", "params": {"name": "Hulk"}}`)
+	if response := runQueryPipeline(question, ns); !response.Cache.Cypher || response.RepairAttempts != maxRepairAttempts {
+		t.Fatalf("third run: cached %v after %d repairs", response.Cache.Cypher, response.RepairAttempts)
+	}
+	accepted = map[string]bool{repaired: true}
+	if response := generate(); response.Cache.Cypher || response.RepairAttempts != 1 {
+		t.Errorf("a cached query the database rejects should be dropped: cached %v after %d repairs", response.Cache.Cypher, response.RepairAttempts)
+	}
+}
+
+func TestHandlePromoteHistory(t *testing.T) {
+	dir := t.TempDir()
+	savedLibraries, savedNamespaces, savedHistory := exampleLibraries, namespaces, historyStore
+	t.Cleanup(func() {
+		exampleLibraries, namespaces, historyStore = savedLibraries, savedNamespaces, savedHistory
+	})
+
+	exampleLibraries = make(map[string]*ExampleLibrary)
+	for _, name := range []string{"", "experiments"} {
+		library, err := loadExampleLibrary(filepath.Join(dir, "examples-"+name+".jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		library.namespace = name
+		exampleLibraries[name] = library
+	}
+	namespaces = map[string]*GraphNamespace{
+		"":            {Schema: "test schema"},
+		"experiments": {Name: "experiments", Schema: "test schema"},
+	}
+	store, err := openHistoryStore(filepath.Join(dir, "history.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	historyStore = store
+
+	entries := []QueryResponse{
+		{ID: "liked", Query: "Who knows Thor?", Cypher: "MATCH (h:Hero {id: $name})-[:KNOWS]-(o) RETURN o.id AS result LIMIT 10", Params: map[string]interface{}{"name": "THOR"}},
+		{ID: "corrected", Namespace: "experiments", Query: "Which genes interact with TP53?", Cypher: "MATCH (g:Gene) RETURN g.id AS result LIMIT 10"},
+		{ID: "unrated", Query: "Who is Hulk?", Cypher: "MATCH (h:Hero) RETURN h.id AS result LIMIT 10"},
+	}
+	for _, entry := range entries {
+		if err := store.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.SetFeedback("liked", Feedback{Rating: "up"})
+	store.SetFeedback("corrected", Feedback{Rating: "down",
+		CorrectedCypher: "MATCH (:Gene {id: $gene})-[:INTERACTS_WITH]-(g:Gene) RETURN g.id AS result LIMIT 10",
+		CorrectedParams: map[string]interface{}{"gene": "TP53"}})
+
+	tests := []struct {
+		id         string
+		wantStatus int
+	}{
+		{id: "liked", wantStatus: http.StatusOK},
+		{id: "corrected", wantStatus: http.StatusOK},
+		{id: "unrated", wantStatus: http.StatusBadRequest},
+		{id: "missing", wantStatus: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/api/history/promote", strings.NewReader(`{"id": "`+tc.id+`"}`))
+		recorder := httptest.NewRecorder()
+		handlePromoteHistory(recorder, request)
+		if recorder.Code != tc.wantStatus {
+			t.Errorf("%s: status = %d, want %d: %s", tc.id, recorder.Code, tc.wantStatus, recorder.Body.String())
+		}
+	}
+
+	// Each example lands in the library of the namespace its query ran in
+	defaults, experiments := exampleLibraries[""].All(), exampleLibraries["experiments"].All()
+	if len(defaults) != 1 || defaults[0].Source != "history:liked" {
+		t.Errorf("default examples = %+v", defaults)
+	}
+	if len(experiments) != 1 || experiments[0].Source != "history:corrected" || experiments[0].Params["gene"] != "TP53" {
+		t.Errorf("experiments examples = %+v", experiments)
+	}
+	reloaded, err := loadExampleLibrary(filepath.Join(dir, "examples-experiments.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.All()) != 1 {
+		t.Errorf("experiments library file holds %d examples, want 1", len(reloaded.All()))
+	}
+}